@@ -13,6 +13,9 @@ import (
 	"syscall"
 	"time"
 
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+
 	"github.com/aniladanir/ethereum-blockchain-parser/internal/adapters/blockchain"
 	"github.com/aniladanir/ethereum-blockchain-parser/internal/adapters/handlers/httphandler"
 	"github.com/aniladanir/ethereum-blockchain-parser/internal/adapters/repositories"
@@ -46,13 +49,82 @@ func main() {
 	go ListenOsTerminate(parentCancel)
 
 	// create blockchain rpc client
-	ethClient := blockchain.NewEthereumClient(time.Second * 5)
+	rpcEndpoints := make([]blockchain.Endpoint, 0)
+	for _, ep := range cfg.GetRpcEndpoints() {
+		rpcEndpoints = append(rpcEndpoints, blockchain.Endpoint{Url: ep.Url, Headers: ep.Headers})
+	}
+	ethClient := blockchain.NewEthereumClient(blockchain.EthereumClientConfig{
+		Endpoints:         rpcEndpoints,
+		Timeout:           time.Duration(cfg.GetRpcTimeout()) * time.Millisecond,
+		MaxBatchSize:      cfg.GetRpcMaxBatchSize(),
+		MaxRetries:        cfg.GetRpcMaxRetries(),
+		InitialBackoff:    time.Duration(cfg.GetRpcInitialBackoff()) * time.Millisecond,
+		MaxBackoff:        time.Duration(cfg.GetRpcMaxBackoff()) * time.Millisecond,
+		RequestsPerSecond: cfg.GetRpcRequestsPerSecond(),
+		RateLimitBurst:    cfg.GetRpcRateLimitBurst(),
+	})
 
 	// create repositories
-	inMemRepo := repositories.NewInmemTransactionRepository()
+	var repo repositories.Repository
+	switch cfg.GetStorageType() {
+	case "", "memory":
+		repo = repositories.NewInmemTransactionRepository()
+	case "file":
+		fileRepo, err := repositories.NewFileRepository(cfg.GetStorageFilePath(), cfg.GetStorageSnapshotRecords())
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer func() {
+			if err := fileRepo.Close(); err != nil {
+				logger.Error("error closing file repository", slog.Any("error", err))
+			}
+		}()
+		repo = fileRepo
+	case "sql":
+		sqlRepo, err := repositories.NewSqlRepository(parentCtx, cfg.GetStorageSqlDriver(), cfg.GetStorageSqlDsn())
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer func() {
+			if err := sqlRepo.Close(); err != nil {
+				logger.Error("error closing sql repository", slog.Any("error", err))
+			}
+		}()
+		repo = sqlRepo
+	default:
+		log.Fatalf("invalid storage type %q", cfg.GetStorageType())
+	}
 
 	// create services
-	txParser := services.NewTransactionParser(inMemRepo, ethClient, logger)
+	blockTag := blockchain.BlockTagLatest
+	if cfg.GetBlockTag() != "" {
+		blockTag = blockchain.BlockTag(cfg.GetBlockTag())
+	}
+	if !blockTag.Valid() {
+		log.Fatalf("invalid block tag %q", blockTag)
+	}
+	startMode := services.StartModeResume
+	if cfg.GetStartMode() != "" {
+		startMode = services.StartMode(cfg.GetStartMode())
+	}
+	if !startMode.Valid() {
+		log.Fatalf("invalid start mode %q", startMode)
+	}
+	txParserOpts := []services.Option{
+		services.WithMaxReorgDepth(cfg.GetMaxReorgDepth()),
+		services.WithConfirmations(cfg.GetConfirmations()),
+		services.WithBlockTag(blockTag),
+		services.WithStartMode(startMode, cfg.GetStartBlock()),
+		services.WithFetchParallelism(cfg.GetFetchParallelism()),
+		services.WithReceipts(cfg.GetFetchReceipts()),
+		services.WithTokenTransfers(cfg.GetFetchTokenTransfers()),
+		services.WithNFTTransfers(cfg.GetFetchNFTTransfers()),
+	}
+	if wsUrl := cfg.GetRpcWebsocketUrl(); wsUrl != "" {
+		headSubscriber := blockchain.NewWebsocketHeadSubscriber(wsUrl, cfg.GetRpcWebsocketHeaders(), logger)
+		txParserOpts = append(txParserOpts, services.WithHeadSubscriber(headSubscriber))
+	}
+	txParser := services.NewTransactionParser(repo, ethClient, logger, txParserOpts...)
 
 	// create handlers
 	serverAddress := fmt.Sprintf("%s:%d", cfg.GetHttpServerIP(), cfg.GetHttpServerPort())
@@ -63,7 +135,9 @@ func main() {
 	)
 
 	// start processing blockchain
+	processingDone := make(chan struct{})
 	go func() {
+		defer close(processingDone)
 		if err := txParser.ProcessNewBlocks(parentCtx, time.Duration(cfg.GetChainProcessInterval())*time.Millisecond); err != nil {
 			logger.Error("process new blocks failed", slog.Any("error", err))
 		}
@@ -85,6 +159,9 @@ func main() {
 	if err := httpHandler.Shutdown(context.Background()); err != nil {
 		logger.Warn("error while shutting down http handler", slog.Any("error", err))
 	}
+
+	// wait for the running ingestion cycle before the repository is closed
+	<-processingDone
 }
 
 func ListenOsTerminate(onSignal func()) {
@@ -1,3 +1,14 @@
 module github.com/aniladanir/ethereum-blockchain-parser
 
 go 1.22.2
+
+require (
+	github.com/gorilla/websocket v1.5.3
+	github.com/lib/pq v1.10.9
+	github.com/mattn/go-sqlite3 v1.14.33
+)
+
+require (
+	golang.org/x/crypto v0.33.0
+	golang.org/x/sys v0.30.0 // indirect
+)
@@ -2,15 +2,57 @@ package blockchain
 
 import (
 	"context"
+	"fmt"
+	"math"
+	"math/big"
 	"sync"
+	"time"
 
 	"github.com/aniladanir/ethereum-blockchain-parser/internal/core/domain"
+	"github.com/aniladanir/ethereum-blockchain-parser/pkg/errs"
 )
 
+// BlockTag identifies a block by its finality instead of its number
+type BlockTag string
+
+const (
+	// BlockTagLatest is the most recent block, which may still be reorganized
+	BlockTagLatest BlockTag = "latest"
+	// BlockTagSafe is the most recent block that is unlikely to be reorganized
+	BlockTagSafe BlockTag = "safe"
+	// BlockTagFinalized is the most recent block that can not be reorganized
+	BlockTagFinalized BlockTag = "finalized"
+)
+
+// Valid reports whether the tag is one of the supported block tags
+func (t BlockTag) Valid() bool {
+	return t == BlockTagLatest || t == BlockTagSafe || t == BlockTagFinalized
+}
+
 // Client represents client for blockchain networks
 type Client interface {
 	FetchCurrentBlock(ctx context.Context) (int, error)
+	FetchBlockNumberByTag(ctx context.Context, tag BlockTag) (int, error)
+	FetchBlockNumberByTime(ctx context.Context, t time.Time) (int, error)
 	FetchBlockByNumber(ctx context.Context, blockNumber int) (*domain.Block, error)
+	FetchBlocksByRange(ctx context.Context, from, to int) ([]*domain.Block, error)
+	// FetchTransactionReceipt returns errs.ErrorNotFound if the node has no receipt of the transaction
+	FetchTransactionReceipt(ctx context.Context, hash string) (*domain.Receipt, error)
+	// FetchBlockReceipts returns the receipts of all transactions of the block in transaction order
+	FetchBlockReceipts(ctx context.Context, blockNumber int) ([]domain.Receipt, error)
+	// FetchLogs returns the logs matching the filter, ordered by block number and log index
+	FetchLogs(ctx context.Context, filter LogFilter) ([]domain.Log, error)
+	EndpointHealth() []EndpointHealth
+}
+
+// LogFilter selects the logs of a range of blocks by their topics
+type LogFilter struct {
+	// FromBlock and ToBlock are the inclusive block range of the logs
+	FromBlock int
+	ToBlock   int
+	// Topics are matched by position. A log matches a position if its topic is any of the topics of the position,
+	// an empty position matches every topic.
+	Topics [][]string
 }
 
 type rpcRequest struct {
@@ -43,49 +85,210 @@ type blockResponse struct {
 	BaseFeePerGas    string                `json:"baseFeePerGas"`
 }
 
-func (b *blockResponse) toDomain() *domain.Block {
+func (b *blockResponse) toDomain() (*domain.Block, error) {
+	number, err := domain.ParseUint64Quantity(b.Number)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing block number: %w", err)
+	}
 	domainBlock := &domain.Block{
-		Number: b.Number,
+		Number:     number,
+		Hash:       b.Hash,
+		ParentHash: b.ParentHash,
 	}
 
 	for i := range b.Transactions {
-		domainBlock.Transactions = append(domainBlock.Transactions, domain.Transaction{
-			Hash:        b.Transactions[i].Hash,
-			From:        b.Transactions[i].From,
-			To:          b.Transactions[i].To,
-			BlockNumber: b.Transactions[i].BlockNumber,
-			Value:       b.Transactions[i].Value,
-		})
+		transaction, err := b.Transactions[i].toDomain()
+		if err != nil {
+			return nil, fmt.Errorf("error parsing transaction %s of block %d: %w", b.Transactions[i].Hash, number, err)
+		}
+		domainBlock.Transactions = append(domainBlock.Transactions, *transaction)
 	}
 
-	return domainBlock
+	return domainBlock, nil
 }
 
 type transactionResponse struct {
-	BlockHash        string `json:"blockHash"`
-	BlockNumber      string `json:"blockNumber"`
-	From             string `json:"from"`
-	Gas              string `json:"gas"`
-	GasPrice         string `json:"gasPrice"`
-	Hash             string `json:"hash"`
-	Input            string `json:"input"`
-	Nonce            string `json:"nonce"`
-	To               string `json:"to"`
-	TransactionIndex string `json:"transactionIndex"`
-	Value            string `json:"value"`
-	V                string `json:"v"`
-	R                string `json:"r"`
-	S                string `json:"s"`
-}
-
-func (t *transactionResponse) toDomain() *domain.Transaction {
-	return &domain.Transaction{
-		Hash:        t.Hash,
-		From:        t.From,
-		To:          t.To,
-		Value:       t.Value,
-		BlockNumber: t.BlockNumber,
+	BlockHash            string                `json:"blockHash"`
+	BlockNumber          string                `json:"blockNumber"`
+	From                 string                `json:"from"`
+	Gas                  string                `json:"gas"`
+	GasPrice             string                `json:"gasPrice"`
+	MaxFeePerGas         string                `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas string                `json:"maxPriorityFeePerGas"`
+	MaxFeePerBlobGas     string                `json:"maxFeePerBlobGas"`
+	Hash                 string                `json:"hash"`
+	Input                string                `json:"input"`
+	Nonce                string                `json:"nonce"`
+	To                   string                `json:"to"`
+	TransactionIndex     string                `json:"transactionIndex"`
+	Value                string                `json:"value"`
+	Type                 string                `json:"type"`
+	ChainID              string                `json:"chainId"`
+	AccessList           []accessTupleResponse `json:"accessList"`
+	BlobVersionedHashes  []string              `json:"blobVersionedHashes"`
+	V                    string                `json:"v"`
+	R                    string                `json:"r"`
+	S                    string                `json:"s"`
+	YParity              string                `json:"yParity"`
+}
+
+type accessTupleResponse struct {
+	Address     string   `json:"address"`
+	StorageKeys []string `json:"storageKeys"`
+}
+
+func (t *transactionResponse) toDomain() (*domain.Transaction, error) {
+	transaction := &domain.Transaction{
+		Hash:                t.Hash,
+		From:                t.From,
+		To:                  t.To,
+		BlockHash:           t.BlockHash,
+		BlobVersionedHashes: t.BlobVersionedHashes,
+		Input:               t.Input,
+		V:                   t.V,
+		R:                   t.R,
+		S:                   t.S,
+		YParity:             t.YParity,
+	}
+
+	var err error
+	if transaction.Value, err = domain.ParseQuantity(t.Value); err != nil {
+		return nil, fmt.Errorf("error parsing value: %w", err)
+	}
+	if transaction.BlockNumber, err = domain.ParseUint64Quantity(t.BlockNumber); err != nil {
+		return nil, fmt.Errorf("error parsing block number: %w", err)
+	}
+	if transaction.TransactionIndex, err = domain.ParseUint64Quantity(t.TransactionIndex); err != nil {
+		return nil, fmt.Errorf("error parsing transaction index: %w", err)
+	}
+	if transaction.Nonce, err = domain.ParseUint64Quantity(t.Nonce); err != nil {
+		return nil, fmt.Errorf("error parsing nonce: %w", err)
 	}
+	if transaction.Gas, err = domain.ParseUint64Quantity(t.Gas); err != nil {
+		return nil, fmt.Errorf("error parsing gas: %w", err)
+	}
+	// nodes omit the type of legacy transactions and the chain id of transactions without replay protection
+	if t.Type != "" {
+		txType, err := domain.ParseUint64Quantity(t.Type)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing type: %w", err)
+		}
+		if txType > math.MaxUint8 {
+			return nil, fmt.Errorf("transaction type %d out of range", txType)
+		}
+		transaction.Type = uint8(txType)
+	}
+	if t.ChainID != "" {
+		if transaction.ChainID, err = domain.ParseUint64Quantity(t.ChainID); err != nil {
+			return nil, fmt.Errorf("error parsing chain id: %w", err)
+		}
+	}
+	for _, fee := range []struct {
+		name  string
+		value string
+		dest  **big.Int
+	}{
+		{name: "gas price", value: t.GasPrice, dest: &transaction.GasPrice},
+		{name: "max fee per gas", value: t.MaxFeePerGas, dest: &transaction.MaxFeePerGas},
+		{name: "max priority fee per gas", value: t.MaxPriorityFeePerGas, dest: &transaction.MaxPriorityFeePerGas},
+		{name: "max fee per blob gas", value: t.MaxFeePerBlobGas, dest: &transaction.MaxFeePerBlobGas},
+	} {
+		if fee.value == "" {
+			continue
+		}
+		if *fee.dest, err = domain.ParseQuantity(fee.value); err != nil {
+			return nil, fmt.Errorf("error parsing %s: %w", fee.name, err)
+		}
+	}
+
+	for i := range t.AccessList {
+		transaction.AccessList = append(transaction.AccessList, domain.AccessTuple{
+			Address:     t.AccessList[i].Address,
+			StorageKeys: t.AccessList[i].StorageKeys,
+		})
+	}
+	return transaction, nil
+}
+
+type receiptResponse struct {
+	TransactionHash   string        `json:"transactionHash"`
+	TransactionIndex  string        `json:"transactionIndex"`
+	BlockHash         string        `json:"blockHash"`
+	BlockNumber       string        `json:"blockNumber"`
+	From              string        `json:"from"`
+	To                string        `json:"to"`
+	CumulativeGasUsed string        `json:"cumulativeGasUsed"`
+	GasUsed           string        `json:"gasUsed"`
+	EffectiveGasPrice string        `json:"effectiveGasPrice"`
+	ContractAddress   *string       `json:"contractAddress"`
+	Logs              []logResponse `json:"logs"`
+	LogsBloom         string        `json:"logsBloom"`
+	Type              string        `json:"type"`
+	Status            string        `json:"status"`
+	Root              string        `json:"root"`
+}
+
+type logResponse struct {
+	Address          string   `json:"address"`
+	Topics           []string `json:"topics"`
+	Data             string   `json:"data"`
+	BlockNumber      string   `json:"blockNumber"`
+	BlockHash        string   `json:"blockHash"`
+	TransactionHash  string   `json:"transactionHash"`
+	TransactionIndex string   `json:"transactionIndex"`
+	LogIndex         string   `json:"logIndex"`
+	Removed          bool     `json:"removed"`
+}
+
+func (r *receiptResponse) toDomain() (*domain.Receipt, error) {
+	receipt := &domain.Receipt{TransactionHash: r.TransactionHash, BlockHash: r.BlockHash}
+	if r.ContractAddress != nil {
+		receipt.ContractAddress = *r.ContractAddress
+	}
+
+	var err error
+	// receipts before the byzantium fork have a state root instead of a status
+	if receipt.Status, err = domain.ParseReceiptStatus(r.Status); err != nil {
+		return nil, fmt.Errorf("error parsing status: %w", err)
+	}
+	if receipt.GasUsed, err = domain.ParseUint64Quantity(r.GasUsed); err != nil {
+		return nil, fmt.Errorf("error parsing gas used: %w", err)
+	}
+	if receipt.CumulativeGasUsed, err = domain.ParseUint64Quantity(r.CumulativeGasUsed); err != nil {
+		return nil, fmt.Errorf("error parsing cumulative gas used: %w", err)
+	}
+	// nodes omit the effective gas price of receipts before the london fork
+	if r.EffectiveGasPrice != "" {
+		if receipt.EffectiveGasPrice, err = domain.ParseQuantity(r.EffectiveGasPrice); err != nil {
+			return nil, fmt.Errorf("error parsing effective gas price: %w", err)
+		}
+	}
+	for i := range r.Logs {
+		log, err := r.Logs[i].toDomain()
+		if err != nil {
+			return nil, err
+		}
+		receipt.Logs = append(receipt.Logs, *log)
+	}
+	return receipt, nil
+}
+
+func (l *logResponse) toDomain() (*domain.Log, error) {
+	log := &domain.Log{
+		Address:         l.Address,
+		Topics:          l.Topics,
+		Data:            l.Data,
+		BlockHash:       l.BlockHash,
+		TransactionHash: l.TransactionHash,
+	}
+	var err error
+	if log.LogIndex, err = domain.ParseUint64Quantity(l.LogIndex); err != nil {
+		return nil, fmt.Errorf("error parsing log index: %w", err)
+	}
+	if log.BlockNumber, err = domain.ParseUint64Quantity(l.BlockNumber); err != nil {
+		return nil, fmt.Errorf("error parsing log block number: %w", err)
+	}
+	return log, nil
 }
 
 var (
@@ -113,3 +316,15 @@ func putRpcRequest(rpc *rpcRequest) {
 
 	rpcReqPool.Put(rpc)
 }
+
+// parseQuantity parses a hex encoded json-rpc quantity such as a block number or timestamp
+func parseQuantity(quantity string) (int, error) {
+	value, err := domain.ParseUint64Quantity(quantity)
+	if err != nil {
+		return 0, err
+	}
+	if value > math.MaxInt64 {
+		return 0, fmt.Errorf("%w %q: out of range", errs.InvalidQuantityErr(), quantity)
+	}
+	return int(value), nil
+}
@@ -0,0 +1,160 @@
+package blockchain
+
+import (
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// weight of the latest sample in the moving averages
+	healthEwmaWeight = 0.2
+	// number of consecutive failures after which an endpoint is put on cooldown
+	endpointFailureThreshold = 3
+	// duration an unhealthy endpoint is skipped unless no other endpoint is left
+	endpointCooldown = time.Second * 30
+)
+
+// Endpoint represents a single rpc endpoint
+type Endpoint struct {
+	Url     string
+	Headers map[string]string
+}
+
+// EndpointHealth is a snapshot of the health statistics of an rpc endpoint
+type EndpointHealth struct {
+	Url                 string     `json:"url"`
+	Healthy             bool       `json:"healthy"`
+	Score               float64    `json:"score"`
+	LatencyMs           float64    `json:"latencyMs"`
+	ErrorRate           float64    `json:"errorRate"`
+	Requests            uint64     `json:"requests"`
+	Failures            uint64     `json:"failures"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	LastError           string     `json:"lastError,omitempty"`
+	LastErrorAt         *time.Time `json:"lastErrorAt,omitempty"`
+}
+
+type endpoint struct {
+	Endpoint
+	mtx                 sync.Mutex
+	latency             float64
+	errorRate           float64
+	requests            uint64
+	failures            uint64
+	consecutiveFailures int
+	lastError           string
+	lastErrorAt         time.Time
+	cooldownUntil       time.Time
+}
+
+func newEndpoint(ep Endpoint) *endpoint {
+	return &endpoint{Endpoint: ep}
+}
+
+func (e *endpoint) recordSuccess(latency time.Duration) {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	e.requests++
+	e.consecutiveFailures = 0
+	e.cooldownUntil = time.Time{}
+	e.latency = ewma(e.latency, float64(latency.Milliseconds()), e.requests)
+	e.errorRate = ewma(e.errorRate, 0, e.requests)
+}
+
+func (e *endpoint) recordFailure(latency time.Duration, err error) {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	e.requests++
+	e.failures++
+	e.consecutiveFailures++
+	e.latency = ewma(e.latency, float64(latency.Milliseconds()), e.requests)
+	e.errorRate = ewma(e.errorRate, 1, e.requests)
+	e.lastError = err.Error()
+	e.lastErrorAt = time.Now()
+	if e.consecutiveFailures >= endpointFailureThreshold {
+		e.cooldownUntil = e.lastErrorAt.Add(endpointCooldown)
+	}
+}
+
+// score returns a value representing the cost of using the endpoint, lower is better.
+func (e *endpoint) score() float64 {
+	// penalize the latency proportional to the error rate
+	return (e.latency + 1) * (1 + 10*e.errorRate)
+}
+
+func (e *endpoint) healthy(now time.Time) bool {
+	return now.After(e.cooldownUntil)
+}
+
+func (e *endpoint) health() EndpointHealth {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	health := EndpointHealth{
+		Url:                 redactUrl(e.Url),
+		Healthy:             e.healthy(time.Now()),
+		Score:               e.score(),
+		LatencyMs:           e.latency,
+		ErrorRate:           e.errorRate,
+		Requests:            e.requests,
+		Failures:            e.failures,
+		ConsecutiveFailures: e.consecutiveFailures,
+		LastError:           e.lastError,
+	}
+	if !e.lastErrorAt.IsZero() {
+		lastErrorAt := e.lastErrorAt
+		health.LastErrorAt = &lastErrorAt
+	}
+	return health
+}
+
+// rankEndpoints returns the endpoints ordered from the healthiest to the least healthy.
+// Endpoints on cooldown are placed at the end so they are only used as last resort.
+func rankEndpoints(endpoints []*endpoint) []*endpoint {
+	type ranked struct {
+		ep      *endpoint
+		healthy bool
+		score   float64
+	}
+
+	now := time.Now()
+	candidates := make([]ranked, len(endpoints))
+	for i, ep := range endpoints {
+		ep.mtx.Lock()
+		candidates[i] = ranked{ep: ep, healthy: ep.healthy(now), score: ep.score()}
+		ep.mtx.Unlock()
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].healthy != candidates[j].healthy {
+			return candidates[i].healthy
+		}
+		return candidates[i].score < candidates[j].score
+	})
+
+	result := make([]*endpoint, len(candidates))
+	for i := range candidates {
+		result[i] = candidates[i].ep
+	}
+	return result
+}
+
+func ewma(current, sample float64, samples uint64) float64 {
+	if samples <= 1 {
+		return sample
+	}
+	return current*(1-healthEwmaWeight) + sample*healthEwmaWeight
+}
+
+// redactUrl strips path, query and credentials from the url since they may contain api keys
+func redactUrl(rawUrl string) string {
+	u, err := url.Parse(rawUrl)
+	if err != nil || u.Host == "" {
+		return "<invalid url>"
+	}
+	return u.Scheme + "://" + u.Host
+}
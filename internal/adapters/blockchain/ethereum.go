@@ -8,31 +8,104 @@ import (
 	"io"
 	"net/http"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/aniladanir/ethereum-blockchain-parser/internal/core/domain"
+	"github.com/aniladanir/ethereum-blockchain-parser/pkg/errs"
 )
 
 // ethereum rpc methods
 const (
-	ethBlockNumber          = "eth_blockNumber"
-	ethGetBlockByNumber     = "eth_getBlockByNumber"
-	ethGetTransactionByHash = "eth_getTransactionByHash"
+	ethBlockNumber           = "eth_blockNumber"
+	ethGetBlockByNumber      = "eth_getBlockByNumber"
+	ethGetTransactionByHash  = "eth_getTransactionByHash"
+	ethGetTransactionReceipt = "eth_getTransactionReceipt"
+	ethGetBlockReceipts      = "eth_getBlockReceipts"
+	ethGetLogs               = "eth_getLogs"
 )
 
+// EthereumRpcUrl is the default rpc endpoint used when none is configured
 const EthereumRpcUrl = "https://ethereum-rpc.publicnode.com"
 
+const (
+	defaultRpcTimeout     = time.Second * 5
+	defaultMaxBatchSize   = 50
+	defaultInitialBackoff = time.Millisecond * 500
+	defaultMaxBackoff     = time.Second * 30
+)
+
+// EthereumClientConfig holds the settings of the ethereum rpc client
+type EthereumClientConfig struct {
+	// Endpoints are the rpc endpoints to route requests to. If empty, EthereumRpcUrl is used.
+	Endpoints []Endpoint
+	// Timeout is the timeout of a single http request
+	Timeout time.Duration
+	// MaxBatchSize is the maximum number of calls sent in a single json-rpc batch request
+	MaxBatchSize int
+	// MaxRetries is the number of times a request is retried after a retryable failure
+	MaxRetries int
+	// InitialBackoff is the delay before the first retry, doubled on every following retry
+	InitialBackoff time.Duration
+	// MaxBackoff is the upper bound of the delay between retries
+	MaxBackoff time.Duration
+	// RequestsPerSecond limits the rate of http requests sent to the endpoints, zero means unlimited
+	RequestsPerSecond float64
+	// RateLimitBurst is the number of requests that can be sent at once when under the rate limit
+	RateLimitBurst int
+}
+
 type ethereumClient struct {
 	http.Client
+	endpoints      []*endpoint
+	maxBatchSize   int
+	maxRetries     int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	rateLimiter    *rateLimiter
 }
 
-func NewEthereumClient(timeout time.Duration) *ethereumClient {
-	return &ethereumClient{
+// NewEthereumClient creates an ethereum rpc client that routes requests to the healthiest of the
+// configured endpoints and fails over to the others on error.
+func NewEthereumClient(cfg EthereumClientConfig) *ethereumClient {
+	if len(cfg.Endpoints) == 0 {
+		cfg.Endpoints = []Endpoint{{Url: EthereumRpcUrl}}
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultRpcTimeout
+	}
+	if cfg.MaxBatchSize <= 0 {
+		cfg.MaxBatchSize = defaultMaxBatchSize
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = defaultInitialBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = defaultMaxBackoff
+	}
+
+	ec := &ethereumClient{
 		Client: http.Client{
-			Timeout: timeout,
+			Timeout: cfg.Timeout,
 		},
+		maxBatchSize:   cfg.MaxBatchSize,
+		maxRetries:     max(cfg.MaxRetries, 0),
+		initialBackoff: cfg.InitialBackoff,
+		maxBackoff:     max(cfg.MaxBackoff, cfg.InitialBackoff),
+		rateLimiter:    newRateLimiter(cfg.RequestsPerSecond, cfg.RateLimitBurst),
 	}
+	for i := range cfg.Endpoints {
+		ec.endpoints = append(ec.endpoints, newEndpoint(cfg.Endpoints[i]))
+	}
+	return ec
+}
+
+// EndpointHealth returns health statistics of the configured endpoints
+func (ec *ethereumClient) EndpointHealth() []EndpointHealth {
+	health := make([]EndpointHealth, len(ec.endpoints))
+	for i := range ec.endpoints {
+		health[i] = ec.endpoints[i].health()
+	}
+	return health
 }
 
 func (ec *ethereumClient) FetchCurrentBlock(ctx context.Context) (int, error) {
@@ -59,20 +132,123 @@ func (ec *ethereumClient) FetchCurrentBlock(ctx context.Context) (int, error) {
 		return 0, fmt.Errorf("error deserializing response body: %w", err)
 	}
 
-	blockNumberStr := strings.Replace(respPayload.Result, "0x", "", 1)
-	blockNumber, err := strconv.ParseInt(blockNumberStr, 16, 64)
+	blockNumber, err := parseQuantity(respPayload.Result)
 	if err != nil {
 		return 0, fmt.Errorf("error parsing block number: %w", err)
 	}
 
-	return int(blockNumber), nil
+	return blockNumber, nil
+}
+
+func (ec *ethereumClient) FetchBlockNumberByTag(ctx context.Context, tag BlockTag) (int, error) {
+	type responsePayload struct {
+		ID      int    `json:"id"`
+		JsonRpc string `json:"jsonrpc"`
+		Result  *struct {
+			Number string `json:"number"`
+		} `json:"result"`
+	}
+
+	rpcReq := getRpcRequest()
+	defer putRpcRequest(rpcReq)
+
+	rpcReq.JsonRpc = "2.0"
+	rpcReq.ID = 1
+	rpcReq.Method = ethGetBlockByNumber
+	rpcReq.Params = append(rpcReq.Params, string(tag), false)
+
+	body, err := ec.makeRequest(ctx, rpcReq)
+	if err != nil {
+		return 0, err
+	}
+
+	respPayload := new(responsePayload)
+	if err := json.Unmarshal(body, respPayload); err != nil {
+		return 0, fmt.Errorf("error deserializing response body: %w", err)
+	}
+
+	// node returns null if it does not support the tag or no block has reached that finality yet
+	if respPayload.Result == nil {
+		return 0, fmt.Errorf("could not fetch %s block: %w", tag, errs.BlockNotFoundErr())
+	}
+
+	blockNumber, err := parseQuantity(respPayload.Result.Number)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing block number: %w", err)
+	}
+
+	return blockNumber, nil
+}
+
+// FetchBlockNumberByTime returns the number of the first block mined at or after the given time
+// by binary searching block timestamps. If no such block is mined yet, the number after the latest block is returned.
+func (ec *ethereumClient) FetchBlockNumberByTime(ctx context.Context, t time.Time) (int, error) {
+	latest, err := ec.FetchCurrentBlock(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	target := t.Unix()
+	low, high := 0, latest+1
+	for low < high {
+		mid := low + (high-low)/2
+		timestamp, err := ec.fetchBlockTimestamp(ctx, mid)
+		if err != nil {
+			return 0, err
+		}
+		if timestamp < target {
+			low = mid + 1
+		} else {
+			high = mid
+		}
+	}
+
+	return low, nil
+}
+
+func (ec *ethereumClient) fetchBlockTimestamp(ctx context.Context, blockNumber int) (int64, error) {
+	type responsePayload struct {
+		ID      int    `json:"id"`
+		JsonRpc string `json:"jsonrpc"`
+		Result  *struct {
+			Timestamp string `json:"timestamp"`
+		} `json:"result"`
+	}
+
+	rpcReq := getRpcRequest()
+	defer putRpcRequest(rpcReq)
+
+	rpcReq.JsonRpc = "2.0"
+	rpcReq.ID = 1
+	rpcReq.Method = ethGetBlockByNumber
+	rpcReq.Params = append(rpcReq.Params, fmt.Sprintf("0x%x", blockNumber), false)
+
+	body, err := ec.makeRequest(ctx, rpcReq)
+	if err != nil {
+		return 0, err
+	}
+
+	respPayload := new(responsePayload)
+	if err := json.Unmarshal(body, respPayload); err != nil {
+		return 0, fmt.Errorf("error deserializing response body: %w", err)
+	}
+	if respPayload.Result == nil {
+		return 0, fmt.Errorf("could not fetch block %d: %w", blockNumber, errs.BlockNotFoundErr())
+	}
+
+	timestamp, err := parseQuantity(respPayload.Result.Timestamp)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing block timestamp: %w", err)
+	}
+
+	return int64(timestamp), nil
 }
 
 func (ec *ethereumClient) FetchBlockByNumber(ctx context.Context, blockNumber int) (*domain.Block, error) {
 	type responsePayload struct {
-		ID      int           `json:"id"`
-		JsonRpc string        `json:"jsonrpc"`
-		Result  blockResponse `json:"result"`
+		ID      int            `json:"id"`
+		JsonRpc string         `json:"jsonrpc"`
+		Result  *blockResponse `json:"result"`
 	}
 
 	rpcReq := getRpcRequest()
@@ -93,27 +269,320 @@ func (ec *ethereumClient) FetchBlockByNumber(ctx context.Context, blockNumber in
 		return nil, fmt.Errorf("error deserializing response body: %w", err)
 	}
 
-	return respPayload.Result.toDomain(), nil
+	// node returns null for blocks it does not have yet
+	if respPayload.Result == nil {
+		return nil, fmt.Errorf("could not fetch block %d: %w", blockNumber, errs.BlockNotFoundErr())
+	}
+
+	return respPayload.Result.toDomain()
+}
+
+func (ec *ethereumClient) FetchTransactionReceipt(ctx context.Context, hash string) (*domain.Receipt, error) {
+	type responsePayload struct {
+		ID      int              `json:"id"`
+		JsonRpc string           `json:"jsonrpc"`
+		Result  *receiptResponse `json:"result"`
+	}
+
+	rpcReq := getRpcRequest()
+	defer putRpcRequest(rpcReq)
+
+	rpcReq.JsonRpc = "2.0"
+	rpcReq.ID = 1
+	rpcReq.Method = ethGetTransactionReceipt
+	rpcReq.Params = append(rpcReq.Params, hash)
+
+	body, err := ec.makeRequest(ctx, rpcReq)
+	if err != nil {
+		return nil, err
+	}
+
+	respPayload := new(responsePayload)
+	if err := json.Unmarshal(body, respPayload); err != nil {
+		return nil, fmt.Errorf("error deserializing response body: %w", err)
+	}
+
+	// node returns null for unknown and pending transactions
+	if respPayload.Result == nil {
+		return nil, fmt.Errorf("could not fetch receipt of transaction %s: %w", hash, errs.NotFoundErr())
+	}
+
+	receipt, err := respPayload.Result.toDomain()
+	if err != nil {
+		return nil, fmt.Errorf("error parsing receipt of transaction %s: %w", hash, err)
+	}
+	return receipt, nil
 }
 
-func (ec *ethereumClient) makeRequest(ctx context.Context, reqPayload *rpcRequest) ([]byte, error) {
+func (ec *ethereumClient) FetchBlockReceipts(ctx context.Context, blockNumber int) ([]domain.Receipt, error) {
+	type responsePayload struct {
+		ID      int                `json:"id"`
+		JsonRpc string             `json:"jsonrpc"`
+		Result  *[]receiptResponse `json:"result"`
+	}
+
+	rpcReq := getRpcRequest()
+	defer putRpcRequest(rpcReq)
+
+	rpcReq.JsonRpc = "2.0"
+	rpcReq.ID = 1
+	rpcReq.Method = ethGetBlockReceipts
+	rpcReq.Params = append(rpcReq.Params, fmt.Sprintf("0x%x", blockNumber))
+
+	body, err := ec.makeRequest(ctx, rpcReq)
+	if err != nil {
+		return nil, err
+	}
+
+	respPayload := new(responsePayload)
+	if err := json.Unmarshal(body, respPayload); err != nil {
+		return nil, fmt.Errorf("error deserializing response body: %w", err)
+	}
+
+	// node returns null for blocks it does not have yet
+	if respPayload.Result == nil {
+		return nil, fmt.Errorf("could not fetch receipts of block %d: %w", blockNumber, errs.BlockNotFoundErr())
+	}
+
+	receipts := make([]domain.Receipt, 0, len(*respPayload.Result))
+	for i := range *respPayload.Result {
+		receipt, err := (*respPayload.Result)[i].toDomain()
+		if err != nil {
+			return nil, fmt.Errorf("error parsing receipt of block %d: %w", blockNumber, err)
+		}
+		receipts = append(receipts, *receipt)
+	}
+	return receipts, nil
+}
+
+func (ec *ethereumClient) FetchLogs(ctx context.Context, filter LogFilter) ([]domain.Log, error) {
+	type responsePayload struct {
+		ID      int           `json:"id"`
+		JsonRpc string        `json:"jsonrpc"`
+		Result  []logResponse `json:"result"`
+	}
+
+	// empty positions are sent as null, which matches every topic
+	topics := make([]any, len(filter.Topics))
+	for i := range filter.Topics {
+		if len(filter.Topics[i]) > 0 {
+			topics[i] = filter.Topics[i]
+		}
+	}
+
+	rpcReq := getRpcRequest()
+	defer putRpcRequest(rpcReq)
+
+	rpcReq.JsonRpc = "2.0"
+	rpcReq.ID = 1
+	rpcReq.Method = ethGetLogs
+	rpcReq.Params = append(rpcReq.Params, map[string]any{
+		"fromBlock": fmt.Sprintf("0x%x", filter.FromBlock),
+		"toBlock":   fmt.Sprintf("0x%x", filter.ToBlock),
+		"topics":    topics,
+	})
+
+	body, err := ec.makeRequest(ctx, rpcReq)
+	if err != nil {
+		return nil, err
+	}
+
+	respPayload := new(responsePayload)
+	if err := json.Unmarshal(body, respPayload); err != nil {
+		return nil, fmt.Errorf("error deserializing response body: %w", err)
+	}
+
+	logs := make([]domain.Log, 0, len(respPayload.Result))
+	for i := range respPayload.Result {
+		log, err := respPayload.Result[i].toDomain()
+		if err != nil {
+			return nil, fmt.Errorf("error parsing logs of blocks %d-%d: %w", filter.FromBlock, filter.ToBlock, err)
+		}
+		logs = append(logs, *log)
+	}
+	return logs, nil
+}
+
+// FetchBlocksByRange fetches the blocks in the inclusive range [from, to] using json-rpc batch requests
+// of at most maxBatchSize calls each. Returned blocks are ordered by block number.
+func (ec *ethereumClient) FetchBlocksByRange(ctx context.Context, from, to int) ([]*domain.Block, error) {
+	if from > to {
+		return nil, fmt.Errorf("invalid block range [%d, %d]", from, to)
+	}
+
+	blocks := make([]*domain.Block, 0, to-from+1)
+	for batchStart := from; batchStart <= to; batchStart += ec.maxBatchSize {
+		batchEnd := min(batchStart+ec.maxBatchSize-1, to)
+		batch, err := ec.fetchBlockBatch(ctx, batchStart, batchEnd)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, batch...)
+	}
+
+	return blocks, nil
+}
+
+func (ec *ethereumClient) fetchBlockBatch(ctx context.Context, from, to int) ([]*domain.Block, error) {
+	type responsePayload struct {
+		ID      int            `json:"id"`
+		JsonRpc string         `json:"jsonrpc"`
+		Result  *blockResponse `json:"result"`
+		Error   *errs.ErrorRpc `json:"error"`
+	}
+
+	// request ids are the offsets in the range, so responses can be matched regardless of their order
+	rpcReqs := make([]*rpcRequest, 0, to-from+1)
+	defer func() {
+		for i := range rpcReqs {
+			putRpcRequest(rpcReqs[i])
+		}
+	}()
+	for blockNumber := from; blockNumber <= to; blockNumber++ {
+		rpcReq := getRpcRequest()
+		rpcReq.JsonRpc = "2.0"
+		rpcReq.ID = blockNumber - from
+		rpcReq.Method = ethGetBlockByNumber
+		rpcReq.Params = append(rpcReq.Params, fmt.Sprintf("0x%x", blockNumber), true)
+		rpcReqs = append(rpcReqs, rpcReq)
+	}
+
+	body, err := ec.makeRequest(ctx, rpcReqs)
+	if err != nil {
+		return nil, err
+	}
+
+	respPayloads := make([]responsePayload, 0, len(rpcReqs))
+	if err := json.Unmarshal(body, &respPayloads); err != nil {
+		return nil, fmt.Errorf("error deserializing response body: %w", err)
+	}
+
+	blocks := make([]*domain.Block, len(rpcReqs))
+	for i := range respPayloads {
+		id := respPayloads[i].ID
+		if id < 0 || id >= len(blocks) {
+			return nil, fmt.Errorf("received response with unexpected id %d", id)
+		}
+		if respPayloads[i].Error != nil {
+			return nil, fmt.Errorf("could not fetch block %d: %w", from+id, respPayloads[i].Error)
+		}
+		if respPayloads[i].Result == nil {
+			return nil, fmt.Errorf("could not fetch block %d: %w", from+id, errs.BlockNotFoundErr())
+		}
+		block, err := respPayloads[i].Result.toDomain()
+		if err != nil {
+			return nil, err
+		}
+		blocks[id] = block
+	}
+	for i := range blocks {
+		if blocks[i] == nil {
+			return nil, fmt.Errorf("missing response for block %d", from+i)
+		}
+	}
+
+	return blocks, nil
+}
+
+// makeRequest sends the request to the healthiest endpoint, trying the remaining ones in order of health
+// if the request fails. The payload is either a single request or a batch of requests.
+//
+// If every endpoint fails with a retryable error, the whole pass is repeated up to maxRetries times
+// with jittered exponential backoff, or after the delay requested by a Retry-After header if longer,
+// up to the maximum backoff.
+func (ec *ethereumClient) makeRequest(ctx context.Context, reqPayload any) ([]byte, error) {
 	payloadBytes, err := json.Marshal(reqPayload)
 	if err != nil {
 		return nil, fmt.Errorf("could not serialize request payload: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, EthereumRpcUrl, bytes.NewBuffer(payloadBytes))
+	for attempt := 0; ; attempt++ {
+		body, err := ec.tryEndpoints(ctx, payloadBytes)
+		if err == nil {
+			return body, nil
+		}
+		if attempt >= ec.maxRetries || !errs.IsRetryableErr(err) || ctx.Err() != nil {
+			return nil, err
+		}
+
+		// the delay requested by the node is capped so that a misbehaving node cannot stall the caller
+		delay := max(backoff(attempt, ec.initialBackoff, ec.maxBackoff), min(errs.RetryAfter(err), ec.maxBackoff))
+		if err := sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// tryEndpoints sends the payload to every endpoint in order of health until one succeeds or a node rejects
+// the request with a non-retryable json-rpc error. The returned error is the first retryable error, if any,
+// otherwise the last error.
+func (ec *ethereumClient) tryEndpoints(ctx context.Context, payloadBytes []byte) ([]byte, error) {
+	var lastErr, retryableErr error
+	for _, ep := range rankEndpoints(ec.endpoints) {
+		if err := ec.rateLimiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+
+		start := time.Now()
+		body, err := ec.doRequest(ctx, ep, payloadBytes)
+		if err == nil {
+			ep.recordSuccess(time.Since(start))
+			return body, nil
+		}
+
+		// do not blame the endpoint if the caller gave up
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		// the node answered, but rejected the request itself, such as invalid params, unsupported methods
+		// or results that have to be narrowed down by the caller, which other endpoints would reject as well
+		if rpcErr, ok := errs.AsRpcErr(err); ok && !rpcErr.Retryable() {
+			ep.recordSuccess(time.Since(start))
+			return nil, fmt.Errorf("%s: %w", redactUrl(ep.Url), err)
+		}
+		ep.recordFailure(time.Since(start), err)
+		lastErr = fmt.Errorf("%s: %w", redactUrl(ep.Url), err)
+		if retryableErr == nil && errs.IsRetryableErr(err) {
+			retryableErr = lastErr
+		}
+	}
+
+	if retryableErr != nil {
+		lastErr = retryableErr
+	}
+	return nil, fmt.Errorf("all rpc endpoints failed: %w", lastErr)
+}
+
+func (ec *ethereumClient) doRequest(ctx context.Context, ep *endpoint, payloadBytes []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ep.Url, bytes.NewReader(payloadBytes))
 	if err != nil {
 		return nil, fmt.Errorf("encountered error when constructing request: %w", err)
 	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range ep.Headers {
+		// net/http ignores a Host header and sends req.Host instead
+		if http.CanonicalHeaderKey(key) == "Host" {
+			req.Host = value
+			continue
+		}
+		req.Header.Set(key, value)
+	}
 
 	resp, err := ec.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("could not make request: %w", err)
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("could not make request: %w", err)
+		}
+		// transport failures such as refused connections are usually transient
+		return nil, errs.RetryableErr(fmt.Errorf("could not make request: %w", err))
 	}
+	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("received non-200 status code: %d", resp.StatusCode)
+		return nil, &errs.ErrorHttpStatus{
+			StatusCode: resp.StatusCode,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
 	}
 
 	body, err := io.ReadAll(resp.Body)
@@ -121,5 +590,51 @@ func (ec *ethereumClient) makeRequest(ctx context.Context, reqPayload *rpcReques
 		return nil, fmt.Errorf("could not read response body: %w", err)
 	}
 
+	// check if the node responded with a json-rpc error object
+	if err := checkRpcError(body); err != nil {
+		return nil, err
+	}
+
 	return body, nil
 }
+
+// checkRpcError returns the first json-rpc error object found in a single or batch response body
+func checkRpcError(body []byte) error {
+	type errorPayload struct {
+		Error *errs.ErrorRpc `json:"error"`
+	}
+
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var payloads []errorPayload
+		if err := json.Unmarshal(body, &payloads); err != nil {
+			return nil
+		}
+		for i := range payloads {
+			if payloads[i].Error != nil {
+				return payloads[i].Error
+			}
+		}
+		return nil
+	}
+
+	var payload errorPayload
+	if err := json.Unmarshal(body, &payload); err == nil && payload.Error != nil {
+		return payload.Error
+	}
+	return nil
+}
+
+// parseRetryAfter parses the value of a Retry-After header, which is either delay seconds or an http date
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0)
+	}
+	return 0
+}
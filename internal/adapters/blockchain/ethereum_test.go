@@ -0,0 +1,567 @@
+package blockchain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aniladanir/ethereum-blockchain-parser/internal/core/domain"
+	"github.com/aniladanir/ethereum-blockchain-parser/pkg/errs"
+)
+
+func newRpcServer(t *testing.T, status int, body string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestFetchCurrentBlockFailover(t *testing.T) {
+	tests := []struct {
+		name          string
+		failingStatus int
+		failingBody   string
+	}{
+		{
+			name:          "Non200",
+			failingStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:          "JsonRpcError",
+			failingStatus: http.StatusOK,
+			failingBody:   `{"jsonrpc":"2.0","id":1,"error":{"code":-32005,"message":"limit exceeded"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failing := newRpcServer(t, tt.failingStatus, tt.failingBody)
+			healthy := newRpcServer(t, http.StatusOK, `{"jsonrpc":"2.0","id":1,"result":"0x10"}`)
+
+			client := NewEthereumClient(EthereumClientConfig{
+				Endpoints: []Endpoint{{Url: failing.URL}, {Url: healthy.URL}},
+				Timeout:   time.Second,
+			})
+			blockNumber, err := client.FetchCurrentBlock(context.Background())
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if blockNumber != 16 {
+				t.Errorf("expected block number 16, got %d", blockNumber)
+			}
+
+			health := client.EndpointHealth()
+			if health[0].Failures != 1 || health[1].Failures != 0 {
+				t.Errorf("expected one failure on the first endpoint only, got %+v", health)
+			}
+			if health[0].LastErrorAt == nil || health[1].LastErrorAt != nil {
+				t.Errorf("expected a last error time on the first endpoint only, got %+v", health)
+			}
+
+			// the healthy endpoint should now be preferred
+			if ranked := rankEndpoints(client.endpoints); ranked[0].Url != healthy.URL {
+				t.Errorf("expected healthy endpoint to be ranked first, got %s", ranked[0].Url)
+			}
+		})
+	}
+}
+
+func TestFetchCurrentBlockAllEndpointsFail(t *testing.T) {
+	failing := newRpcServer(t, http.StatusInternalServerError, "")
+
+	client := NewEthereumClient(EthereumClientConfig{
+		Endpoints: []Endpoint{{Url: failing.URL}},
+		Timeout:   time.Second,
+	})
+	for i := 0; i < endpointFailureThreshold; i++ {
+		if _, err := client.FetchCurrentBlock(context.Background()); err == nil {
+			t.Fatal("expected error, got nil")
+		}
+	}
+
+	if health := client.EndpointHealth(); health[0].Healthy {
+		t.Errorf("expected endpoint to be unhealthy after %d failures", endpointFailureThreshold)
+	}
+}
+
+func TestEndpointHeaders(t *testing.T) {
+	var host, apiKey string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, apiKey = r.Host, r.Header.Get("X-Api-Key")
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x10"}`))
+	}))
+	defer srv.Close()
+
+	client := NewEthereumClient(EthereumClientConfig{
+		Endpoints: []Endpoint{{Url: srv.URL, Headers: map[string]string{"host": "rpc.example.com", "x-api-key": "secret"}}},
+		Timeout:   time.Second,
+	})
+	if _, err := client.FetchCurrentBlock(context.Background()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if host != "rpc.example.com" || apiKey != "secret" {
+		t.Errorf("expected the configured host and api key headers, got %q and %q", host, apiKey)
+	}
+}
+
+func TestFetchBlocksByRange(t *testing.T) {
+	var batchSizes []int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reqs []rpcRequest
+		if err := json.NewDecoder(r.Body).Decode(&reqs); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		batchSizes = append(batchSizes, len(reqs))
+
+		// respond in reverse order to make sure responses are matched by id
+		resps := make([]map[string]any, 0, len(reqs))
+		for i := len(reqs) - 1; i >= 0; i-- {
+			resps = append(resps, map[string]any{
+				"jsonrpc": "2.0",
+				"id":      reqs[i].ID,
+				"result":  map[string]any{"number": reqs[i].Params[0]},
+			})
+		}
+		_ = json.NewEncoder(w).Encode(resps)
+	}))
+	defer srv.Close()
+
+	client := NewEthereumClient(EthereumClientConfig{
+		Endpoints:    []Endpoint{{Url: srv.URL}},
+		MaxBatchSize: 2,
+	})
+	blocks, err := client.FetchBlocksByRange(context.Background(), 10, 14)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	expectedNumbers := []uint64{10, 11, 12, 13, 14}
+	if len(blocks) != len(expectedNumbers) {
+		t.Fatalf("expected %d blocks, got %d", len(expectedNumbers), len(blocks))
+	}
+	for i := range blocks {
+		if blocks[i].Number != expectedNumbers[i] {
+			t.Errorf("expected block %d at index %d, got %d", expectedNumbers[i], i, blocks[i].Number)
+		}
+	}
+
+	expectedBatchSizes := []int{2, 2, 1}
+	if len(batchSizes) != len(expectedBatchSizes) {
+		t.Fatalf("expected %d batch requests, got %d", len(expectedBatchSizes), len(batchSizes))
+	}
+	for i := range batchSizes {
+		if batchSizes[i] != expectedBatchSizes[i] {
+			t.Errorf("expected batch %d to have %d calls, got %d", i, expectedBatchSizes[i], batchSizes[i])
+		}
+	}
+}
+
+func TestFetchBlockByNumberErrors(t *testing.T) {
+	t.Run("NullResult", func(t *testing.T) {
+		srv := newRpcServer(t, http.StatusOK, `{"jsonrpc":"2.0","id":1,"result":null}`)
+		client := NewEthereumClient(EthereumClientConfig{Endpoints: []Endpoint{{Url: srv.URL}}})
+
+		_, err := client.FetchBlockByNumber(context.Background(), 1)
+		if !errs.IsBlockNotFoundErr(err) {
+			t.Errorf("expected block not found error, got %v", err)
+		}
+	})
+
+	t.Run("InvalidQuantity", func(t *testing.T) {
+		srv := newRpcServer(t, http.StatusOK, `{"jsonrpc":"2.0","id":1,"result":{"number":"0x1","transactions":[
+			{"blockNumber":"0x1","hash":"0xhash","value":"0x0de0b6b3a7640000","transactionIndex":"0x0","nonce":"0x0","gas":"0x5208"}]}}`)
+		client := NewEthereumClient(EthereumClientConfig{Endpoints: []Endpoint{{Url: srv.URL}}})
+
+		_, err := client.FetchBlockByNumber(context.Background(), 1)
+		if !errs.IsInvalidQuantityErr(err) {
+			t.Errorf("expected invalid quantity error, got %v", err)
+		}
+	})
+
+	t.Run("RpcError", func(t *testing.T) {
+		srv := newRpcServer(t, http.StatusOK, `{"jsonrpc":"2.0","id":1,"error":{"code":-32005,"message":"limit exceeded","data":"retry later"}}`)
+		client := NewEthereumClient(EthereumClientConfig{Endpoints: []Endpoint{{Url: srv.URL}}})
+
+		_, err := client.FetchBlockByNumber(context.Background(), 1)
+		rpcErr, ok := errs.AsRpcErr(err)
+		if !ok {
+			t.Fatalf("expected json-rpc error, got %v", err)
+		}
+		if rpcErr.Code != -32005 || rpcErr.Message != "limit exceeded" || rpcErr.Data != "retry later" {
+			t.Errorf("unexpected json-rpc error %+v", rpcErr)
+		}
+		if !errs.IsRetryableErr(err) {
+			t.Error("expected error to be retryable")
+		}
+	})
+}
+
+func TestFetchBlockByNumberTransactionDetails(t *testing.T) {
+	srv := newRpcServer(t, http.StatusOK, `{"jsonrpc":"2.0","id":1,"result":{"number":"0x1","hash":"0xblock","transactions":[{
+		"blockHash":"0xblock","blockNumber":"0x1","from":"0xfrom","to":"0xto","hash":"0xhash","value":"0x0",
+		"transactionIndex":"0x0","type":"0x3","chainId":"0x1","nonce":"0x7","gas":"0x5208","gasPrice":"0x3b9aca00",
+		"maxFeePerGas":"0x77359400","maxPriorityFeePerGas":"0x3b9aca00","maxFeePerBlobGas":"0x2",
+		"accessList":[{"address":"0xcontract","storageKeys":["0xkey"]}],"blobVersionedHashes":["0x01hash"],
+		"input":"0x","v":"0x1","r":"0xr","s":"0xs","yParity":"0x1"}]}}`)
+	client := NewEthereumClient(EthereumClientConfig{Endpoints: []Endpoint{{Url: srv.URL}}})
+
+	block, err := client.FetchBlockByNumber(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(block.Transactions) != 1 {
+		t.Fatalf("expected 1 transaction, got %d", len(block.Transactions))
+	}
+
+	expected := domain.Transaction{
+		Hash:                 "0xhash",
+		From:                 "0xfrom",
+		To:                   "0xto",
+		Value:                big.NewInt(0),
+		BlockNumber:          1,
+		BlockHash:            "0xblock",
+		TransactionIndex:     0,
+		Type:                 3,
+		ChainID:              1,
+		Nonce:                7,
+		Gas:                  21000,
+		GasPrice:             big.NewInt(1_000_000_000),
+		MaxFeePerGas:         big.NewInt(2_000_000_000),
+		MaxPriorityFeePerGas: big.NewInt(1_000_000_000),
+		AccessList:           []domain.AccessTuple{{Address: "0xcontract", StorageKeys: []string{"0xkey"}}},
+		MaxFeePerBlobGas:     big.NewInt(2),
+		BlobVersionedHashes:  []string{"0x01hash"},
+		Input:                "0x",
+		V:                    "0x1",
+		R:                    "0xr",
+		S:                    "0xs",
+		YParity:              "0x1",
+	}
+	// compare the encodings, as equal big integers may differ in their internal representation
+	expectedJson, _ := json.Marshal(expected)
+	actualJson, _ := json.Marshal(block.Transactions[0])
+	if string(actualJson) != string(expectedJson) {
+		t.Errorf("expected transaction %s, got %s", expectedJson, actualJson)
+	}
+}
+
+func TestFetchTransactionReceipt(t *testing.T) {
+	t.Run("Receipt", func(t *testing.T) {
+		srv := newRpcServer(t, http.StatusOK, `{"jsonrpc":"2.0","id":1,"result":{"transactionHash":"0xhash","transactionIndex":"0x0",
+			"blockHash":"0xblock","blockNumber":"0x1","from":"0xfrom","to":null,"cumulativeGasUsed":"0xc350","gasUsed":"0x7530",
+			"effectiveGasPrice":"0x3b9aca00","contractAddress":"0xcontract","type":"0x2","status":"0x1","logs":[
+			{"address":"0xcontract","topics":["0xtopic"],"data":"0x","blockNumber":"0x1","blockHash":"0xblock",
+			"transactionHash":"0xhash","transactionIndex":"0x0","logIndex":"0x2","removed":false}]}}`)
+		client := NewEthereumClient(EthereumClientConfig{Endpoints: []Endpoint{{Url: srv.URL}}})
+
+		receipt, err := client.FetchTransactionReceipt(context.Background(), "0xhash")
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		expected := domain.Receipt{
+			TransactionHash:   "0xhash",
+			BlockHash:         "0xblock",
+			Status:            domain.ReceiptStatusSuccessful,
+			GasUsed:           30000,
+			CumulativeGasUsed: 50000,
+			EffectiveGasPrice: big.NewInt(1_000_000_000),
+			ContractAddress:   "0xcontract",
+			Logs: []domain.Log{{
+				Address: "0xcontract", Topics: []string{"0xtopic"}, Data: "0x", LogIndex: 2,
+				BlockNumber: 1, BlockHash: "0xblock", TransactionHash: "0xhash",
+			}},
+		}
+		expectedJson, _ := json.Marshal(expected)
+		actualJson, _ := json.Marshal(receipt)
+		if string(actualJson) != string(expectedJson) {
+			t.Errorf("expected receipt %s, got %s", expectedJson, actualJson)
+		}
+	})
+
+	t.Run("NullResult", func(t *testing.T) {
+		srv := newRpcServer(t, http.StatusOK, `{"jsonrpc":"2.0","id":1,"result":null}`)
+		client := NewEthereumClient(EthereumClientConfig{Endpoints: []Endpoint{{Url: srv.URL}}})
+
+		_, err := client.FetchTransactionReceipt(context.Background(), "0xhash")
+		if !errs.IsNotFoundErr(err) {
+			t.Errorf("expected not found error, got %v", err)
+		}
+	})
+}
+
+func TestFetchBlockReceipts(t *testing.T) {
+	t.Run("Receipts", func(t *testing.T) {
+		// the receipt of tx2 predates the byzantium fork and has a state root instead of a status
+		srv := newRpcServer(t, http.StatusOK, `{"jsonrpc":"2.0","id":1,"result":[
+			{"transactionHash":"0xtx1","cumulativeGasUsed":"0x5208","gasUsed":"0x5208","contractAddress":null,"status":"0x0","logs":[]},
+			{"transactionHash":"0xtx2","cumulativeGasUsed":"0xa410","gasUsed":"0x5208","contractAddress":null,"root":"0xroot","logs":[]}]}`)
+		client := NewEthereumClient(EthereumClientConfig{Endpoints: []Endpoint{{Url: srv.URL}}})
+
+		receipts, err := client.FetchBlockReceipts(context.Background(), 1)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(receipts) != 2 {
+			t.Fatalf("expected 2 receipts, got %d", len(receipts))
+		}
+		if receipts[0].TransactionHash != "0xtx1" || receipts[0].Status != domain.ReceiptStatusFailed || receipts[0].EffectiveGasPrice != nil {
+			t.Errorf("unexpected receipt %+v", receipts[0])
+		}
+		if receipts[1].TransactionHash != "0xtx2" || receipts[1].Status != domain.ReceiptStatusUnknown || receipts[1].CumulativeGasUsed != 42000 {
+			t.Errorf("unexpected receipt %+v", receipts[1])
+		}
+	})
+
+	t.Run("NullResult", func(t *testing.T) {
+		srv := newRpcServer(t, http.StatusOK, `{"jsonrpc":"2.0","id":1,"result":null}`)
+		client := NewEthereumClient(EthereumClientConfig{Endpoints: []Endpoint{{Url: srv.URL}}})
+
+		_, err := client.FetchBlockReceipts(context.Background(), 1)
+		if !errs.IsBlockNotFoundErr(err) {
+			t.Errorf("expected block not found error, got %v", err)
+		}
+	})
+}
+
+func TestFetchLogs(t *testing.T) {
+	var params string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Method != "eth_getLogs" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		params = string(req.Params)
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":[{"address":"0xtoken","topics":["0xtopic0","0xtopic1"],
+			"data":"0x01","blockNumber":"0xa","blockHash":"0xblock","transactionHash":"0xhash","transactionIndex":"0x1",
+			"logIndex":"0x5","removed":false}]}`))
+	}))
+	defer srv.Close()
+
+	client := NewEthereumClient(EthereumClientConfig{Endpoints: []Endpoint{{Url: srv.URL}}})
+	logs, err := client.FetchLogs(context.Background(), LogFilter{FromBlock: 10, ToBlock: 20, Topics: [][]string{{"0xtopic0"}, nil, {"0xa", "0xb"}}})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if expected := `[{"fromBlock":"0xa","toBlock":"0x14","topics":[["0xtopic0"],null,["0xa","0xb"]]}]`; params != expected {
+		t.Errorf("expected params %s, got %s", expected, params)
+	}
+	expected := []domain.Log{{
+		Address: "0xtoken", Topics: []string{"0xtopic0", "0xtopic1"}, Data: "0x01", LogIndex: 5,
+		BlockNumber: 10, BlockHash: "0xblock", TransactionHash: "0xhash",
+	}}
+	if !reflect.DeepEqual(logs, expected) {
+		t.Errorf("expected logs %+v, got %+v", expected, logs)
+	}
+}
+
+func TestNonRetryableRpcError(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		code int
+	}{
+		{
+			name: "ResultLimit",
+			body: `{"jsonrpc":"2.0","id":1,"error":{"code":-32005,"message":"query returned more than 10000 results"}}`,
+			code: -32005,
+		},
+		{
+			name: "InvalidParams",
+			body: `{"jsonrpc":"2.0","id":1,"error":{"code":-32602,"message":"invalid argument 0"}}`,
+			code: -32602,
+		},
+		{
+			name: "MethodNotFound",
+			body: `{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"the method eth_getLogs does not exist"}}`,
+			code: -32601,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+			other := newRpcServer(t, http.StatusOK, `{"jsonrpc":"2.0","id":1,"result":[]}`)
+
+			// the first endpoint is preferred, the other one is only tried on failures
+			client := NewEthereumClient(EthereumClientConfig{
+				Endpoints:      []Endpoint{{Url: srv.URL}, {Url: other.URL}},
+				MaxRetries:     3,
+				InitialBackoff: time.Millisecond,
+				MaxBackoff:     time.Millisecond * 5,
+			})
+			_, err := client.FetchLogs(context.Background(), LogFilter{FromBlock: 1, ToBlock: 100000})
+			if rpcErr, ok := errs.AsRpcErr(err); !ok || rpcErr.Code != tt.code {
+				t.Errorf("expected json-rpc error %d, got %v", tt.code, err)
+			}
+			if calls != 1 {
+				t.Errorf("expected the request to be sent once, got %d calls", calls)
+			}
+			for _, health := range client.EndpointHealth() {
+				if health.Failures != 0 || !health.Healthy || health.LastErrorAt != nil {
+					t.Errorf("expected endpoint not to be blamed, got %+v", health)
+				}
+			}
+		})
+	}
+}
+
+func TestFetchCurrentBlockRetry(t *testing.T) {
+	tests := []struct {
+		name          string
+		failingStatus int
+		maxRetries    int
+		expectedCalls int
+		expectError   bool
+	}{
+		{
+			name:          "RetryableRecovers",
+			failingStatus: http.StatusTooManyRequests,
+			maxRetries:    3,
+			expectedCalls: 3,
+			expectError:   false,
+		},
+		{
+			name:          "RetriesExhausted",
+			failingStatus: http.StatusTooManyRequests,
+			maxRetries:    1,
+			expectedCalls: 2,
+			expectError:   true,
+		},
+		{
+			name:          "FatalNotRetried",
+			failingStatus: http.StatusUnauthorized,
+			maxRetries:    3,
+			expectedCalls: 1,
+			expectError:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// fail the first two calls
+			calls := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				if calls <= 2 {
+					w.WriteHeader(tt.failingStatus)
+					return
+				}
+				_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x10"}`))
+			}))
+			defer srv.Close()
+
+			client := NewEthereumClient(EthereumClientConfig{
+				Endpoints:      []Endpoint{{Url: srv.URL}},
+				MaxRetries:     tt.maxRetries,
+				InitialBackoff: time.Millisecond,
+				MaxBackoff:     time.Millisecond * 5,
+			})
+			_, err := client.FetchCurrentBlock(context.Background())
+			if (err != nil) != tt.expectError {
+				t.Errorf("expected error %v, got %v", tt.expectError, err)
+			}
+			if calls != tt.expectedCalls {
+				t.Errorf("expected %d calls, got %d", tt.expectedCalls, calls)
+			}
+		})
+	}
+}
+
+func TestRetryAfterCapped(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x10"}`))
+	}))
+	defer srv.Close()
+
+	client := NewEthereumClient(EthereumClientConfig{
+		Endpoints:      []Endpoint{{Url: srv.URL}},
+		MaxRetries:     1,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     time.Millisecond * 5,
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	if _, err := client.FetchCurrentBlock(ctx); err != nil {
+		t.Fatalf("expected the retry to wait at most the maximum backoff, got %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("expected 2 calls, got %d", calls)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if d := parseRetryAfter("3"); d != time.Second*3 {
+		t.Errorf("expected 3s, got %v", d)
+	}
+	if d := parseRetryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)); d <= 0 || d > time.Minute {
+		t.Errorf("expected delay up to 1m, got %v", d)
+	}
+	if d := parseRetryAfter("invalid"); d != 0 {
+		t.Errorf("expected 0, got %v", d)
+	}
+}
+
+func TestFetchBlockNumberByTime(t *testing.T) {
+	// blocks 0 to 1000 mined every 12 seconds
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req rpcRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if req.Method == ethBlockNumber {
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x3e8"}`))
+			return
+		}
+		blockNumber, _ := parseQuantity(req.Params[0].(string))
+		_, _ = fmt.Fprintf(w, `{"jsonrpc":"2.0","id":1,"result":{"timestamp":"0x%x"}}`, blockNumber*12)
+	}))
+	defer srv.Close()
+
+	client := NewEthereumClient(EthereumClientConfig{Endpoints: []Endpoint{{Url: srv.URL}}})
+	tests := []struct {
+		name     string
+		time     time.Time
+		expected int
+	}{
+		{name: "ExactTimestamp", time: time.Unix(120, 0), expected: 10},
+		{name: "BetweenBlocks", time: time.Unix(121, 0), expected: 11},
+		{name: "BeforeGenesis", time: time.Unix(-1, 0), expected: 0},
+		{name: "AfterLatest", time: time.Unix(12001, 0), expected: 1001},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blockNumber, err := client.FetchBlockNumberByTime(context.Background(), tt.time)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if blockNumber != tt.expected {
+				t.Errorf("expected block %d, got %d", tt.expected, blockNumber)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package blockchain
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket limiting the number of requests per second
+type rateLimiter struct {
+	mtx    sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter creates a limiter allowing 'rate' requests per second with bursts of up to 'burst' requests.
+// A nil limiter is returned if rate is not positive, which never blocks.
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = 1
+	}
+	return &rateLimiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Wait blocks until a token is available or the context is done
+func (rl *rateLimiter) Wait(ctx context.Context) error {
+	if rl == nil {
+		return nil
+	}
+
+	for {
+		rl.mtx.Lock()
+		now := time.Now()
+		rl.tokens = min(rl.burst, rl.tokens+now.Sub(rl.last).Seconds()*rl.rate)
+		rl.last = now
+		if rl.tokens >= 1 {
+			rl.tokens--
+			rl.mtx.Unlock()
+			return nil
+		}
+		wait := time.Duration((1 - rl.tokens) / rl.rate * float64(time.Second))
+		rl.mtx.Unlock()
+
+		if err := sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// backoff returns the jittered exponential delay before the given retry attempt, starting from 0
+func backoff(attempt int, initial, max time.Duration) time.Duration {
+	delay := initial
+	for i := 0; i < attempt && delay < max; i++ {
+		delay *= 2
+	}
+	delay = min(delay, max)
+
+	// pick a random delay in [delay/2, delay] so that clients do not retry in lockstep
+	half := delay / 2
+	return half + rand.N(half+1)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,183 @@
+package blockchain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/aniladanir/ethereum-blockchain-parser/pkg/errs"
+)
+
+const (
+	ethSubscribe        = "eth_subscribe"
+	ethSubscription     = "eth_subscription"
+	newHeadsTopic       = "newHeads"
+	wsReadTimeout       = time.Minute * 2
+	wsHandshakeTimeout  = time.Second * 10
+	wsReconnectBackoff  = time.Second
+	wsMaxReconnectDelay = time.Minute
+)
+
+// HeadSubscriber notifies about new blocks mined on the blockchain
+type HeadSubscriber interface {
+	// SubscribeNewHeads pushes the number of new block heads to the returned channel until ctx is done.
+	// Lost connections are re-established automatically. Only the most recent head is buffered.
+	SubscribeNewHeads(ctx context.Context) <-chan int
+	// Connected reports whether the subscription is currently active
+	Connected() bool
+}
+
+var _ HeadSubscriber = (*wsHeadSubscriber)(nil)
+
+type wsHeadSubscriber struct {
+	url       string
+	headers   http.Header
+	logger    *slog.Logger
+	dialer    websocket.Dialer
+	connected atomic.Bool
+}
+
+// NewWebsocketHeadSubscriber creates a head subscriber using eth_subscribe("newHeads") over a websocket connection
+func NewWebsocketHeadSubscriber(url string, headers map[string]string, logger *slog.Logger) *wsHeadSubscriber {
+	httpHeaders := make(http.Header)
+	for key, value := range headers {
+		httpHeaders.Set(key, value)
+	}
+	return &wsHeadSubscriber{
+		url:     url,
+		headers: httpHeaders,
+		logger:  logger,
+		dialer: websocket.Dialer{
+			Proxy:            http.ProxyFromEnvironment,
+			HandshakeTimeout: wsHandshakeTimeout,
+		},
+	}
+}
+
+func (ws *wsHeadSubscriber) Connected() bool {
+	return ws.connected.Load()
+}
+
+func (ws *wsHeadSubscriber) SubscribeNewHeads(ctx context.Context) <-chan int {
+	heads := make(chan int, 1)
+	go ws.run(ctx, heads)
+	return heads
+}
+
+func (ws *wsHeadSubscriber) run(ctx context.Context, heads chan int) {
+	defer close(heads)
+
+	attempt := 0
+	for {
+		received, err := ws.subscribe(ctx, heads)
+		ws.connected.Store(false)
+		if ctx.Err() != nil {
+			return
+		}
+		// start backing off from the beginning if the connection was healthy for a while
+		if received {
+			attempt = 0
+		}
+		delay := backoff(attempt, wsReconnectBackoff, wsMaxReconnectDelay)
+		ws.logger.Warn("websocket subscription lost, reconnecting",
+			slog.Any("error", err), slog.String("url", redactUrl(ws.url)), slog.Duration("delay", delay))
+		if err := sleep(ctx, delay); err != nil {
+			return
+		}
+		attempt++
+	}
+}
+
+// subscribe opens a connection and forwards new heads until the connection fails or ctx is done.
+// It reports whether any head was received over the connection.
+func (ws *wsHeadSubscriber) subscribe(ctx context.Context, heads chan int) (received bool, err error) {
+	conn, _, err := ws.dialer.DialContext(ctx, ws.url, ws.headers)
+	if err != nil {
+		return false, fmt.Errorf("could not dial websocket: %w", err)
+	}
+	defer conn.Close()
+
+	// unblock reads when the context is done
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.Close()
+	})
+	defer stop()
+
+	if err := conn.WriteJSON(rpcRequest{
+		ID:      1,
+		JsonRpc: "2.0",
+		Method:  ethSubscribe,
+		Params:  []any{newHeadsTopic},
+	}); err != nil {
+		return false, fmt.Errorf("could not send subscription request: %w", err)
+	}
+
+	type message struct {
+		ID     int             `json:"id"`
+		Method string          `json:"method"`
+		Result json.RawMessage `json:"result"`
+		Error  *errs.ErrorRpc  `json:"error"`
+		Params struct {
+			Subscription string `json:"subscription"`
+			Result       struct {
+				Number string `json:"number"`
+			} `json:"result"`
+		} `json:"params"`
+	}
+
+	var subscriptionID string
+	for {
+		if err := conn.SetReadDeadline(time.Now().Add(wsReadTimeout)); err != nil {
+			return received, err
+		}
+
+		var msg message
+		if err := conn.ReadJSON(&msg); err != nil {
+			return received, fmt.Errorf("could not read message: %w", err)
+		}
+
+		// response to the subscription request
+		if msg.ID == 1 && subscriptionID == "" {
+			if msg.Error != nil {
+				return received, fmt.Errorf("could not subscribe to new heads: %w", msg.Error)
+			}
+			if err := json.Unmarshal(msg.Result, &subscriptionID); err != nil {
+				return received, fmt.Errorf("could not parse subscription id: %w", err)
+			}
+			ws.connected.Store(true)
+			continue
+		}
+
+		if msg.Method != ethSubscription || msg.Params.Subscription != subscriptionID {
+			continue
+		}
+
+		head, err := parseQuantity(msg.Params.Result.Number)
+		if err != nil {
+			return received, fmt.Errorf("error parsing block number: %w", err)
+		}
+		received = true
+		pushLatest(heads, head)
+	}
+}
+
+// pushLatest sends the head to the channel, replacing the buffered head if the consumer is behind
+func pushLatest(heads chan int, head int) {
+	for {
+		select {
+		case heads <- head:
+			return
+		default:
+		}
+		select {
+		case <-heads:
+		default:
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package blockchain
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestWebsocketHeadSubscriber(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	connections := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		connections++
+
+		var req rpcRequest
+		if err := conn.ReadJSON(&req); err != nil || req.Method != ethSubscribe {
+			return
+		}
+		_ = conn.WriteJSON(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": "0xsub"})
+
+		// send one head per connection, then drop the connection to force a reconnect
+		_ = conn.WriteJSON(map[string]any{
+			"jsonrpc": "2.0",
+			"method":  ethSubscription,
+			"params": map[string]any{
+				"subscription": "0xsub",
+				"result":       map[string]any{"number": fmt.Sprintf("0x%x", connections)},
+			},
+		})
+	}))
+	defer srv.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	subscriber := NewWebsocketHeadSubscriber("ws"+strings.TrimPrefix(srv.URL, "http"), nil, logger)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	heads := subscriber.SubscribeNewHeads(ctx)
+
+	for expected := 1; expected <= 2; expected++ {
+		select {
+		case head := <-heads:
+			if head != expected {
+				t.Errorf("expected head %d, got %d", expected, head)
+			}
+		case <-time.After(time.Second * 5):
+			t.Fatalf("timed out waiting for head %d", expected)
+		}
+	}
+
+	cancel()
+	for range heads {
+	}
+	if subscriber.Connected() {
+		t.Error("expected subscriber to be disconnected after cancellation")
+	}
+}
@@ -0,0 +1,226 @@
+package httphandler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aniladanir/ethereum-blockchain-parser/internal/core/services"
+)
+
+const (
+	// maxBatchSize is the maximum number of addresses in a batch subscription
+	maxBatchSize = 10000
+	// maxBatchBodySize is the maximum size of a batch subscription request body in bytes
+	maxBatchBodySize = 8 << 20
+)
+
+// batchEntry is a single entry of a batch subscription request
+type batchEntry struct {
+	// address is the address as given by the client
+	address string
+	request services.SubscribeRequest
+	// err is set if the entry could not be parsed
+	err error
+}
+
+// batchResult is the result of a single entry of a batch subscription
+type batchResult struct {
+	Address string                   `json:"address"`
+	Status  services.SubscribeStatus `json:"status"`
+	Error   string                   `json:"error,omitempty"`
+}
+
+func (h *HttpHandler) subscribeBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// set content type
+	w.Header().Set("Content-Type", "application/json")
+
+	// parse the entries of the request body
+	r.Body = http.MaxBytesReader(w, r.Body, maxBatchBodySize)
+	entries, err := parseBatchEntries(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		h.logger.Error("invalid batch subscription request", slog.Any("error", err))
+		return
+	}
+	if len(entries) == 0 {
+		http.Error(w, "request contains no addresses", http.StatusBadRequest)
+		h.logger.Error("empty batch subscription request")
+		return
+	}
+	if len(entries) > maxBatchSize {
+		http.Error(w, fmt.Sprintf("request contains more than %d addresses", maxBatchSize), http.StatusBadRequest)
+		h.logger.Error("batch subscription request is too large", slog.Int("addresses", len(entries)))
+		return
+	}
+
+	// subscribe to the entries that could be parsed
+	requests := make([]services.SubscribeRequest, 0, len(entries))
+	for i := range entries {
+		if entries[i].err == nil {
+			requests = append(requests, entries[i].request)
+		}
+	}
+	subscribed, err := h.txParser.SubscribeBatch(r.Context(), requests)
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		h.logger.Error(err.Error())
+		return
+	}
+
+	// merge the results of the service with the entries that could not be parsed
+	results := make([]batchResult, len(entries))
+	counts := make(map[services.SubscribeStatus]int)
+	for i := range entries {
+		results[i] = batchResult{Address: entries[i].address}
+		if entries[i].err != nil {
+			results[i].Status = services.SubscribeStatusInvalid
+			results[i].Error = entries[i].err.Error()
+		} else {
+			result := subscribed[0]
+			subscribed = subscribed[1:]
+			results[i].Status = result.Status
+			if result.Err != nil {
+				results[i].Error = result.Err.Error()
+			} else {
+				results[i].Address = result.Address.Checksum()
+			}
+		}
+		counts[results[i].Status]++
+	}
+
+	// write to response body
+	err = json.NewEncoder(w).Encode(&Response{
+		Msg: "success",
+		Data: struct {
+			Created       int           `json:"created"`
+			AlreadyExists int           `json:"alreadyExists"`
+			Invalid       int           `json:"invalid"`
+			Results       []batchResult `json:"results"`
+		}{
+			Created:       counts[services.SubscribeStatusCreated],
+			AlreadyExists: counts[services.SubscribeStatusAlreadyExists],
+			Invalid:       counts[services.SubscribeStatusInvalid],
+			Results:       results,
+		},
+	})
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		h.logger.Error("error writing to response body", slog.Any("error", err))
+	}
+}
+
+// parseBatchEntries parses a JSON array, a CSV body or a CSV file uploaded as multipart form field 'file'.
+// The returned error is meant to be sent to the client.
+func parseBatchEntries(r *http.Request) ([]batchEntry, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "text/csv":
+		return parseBatchCsv(r.Body)
+	case "multipart/form-data":
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			return nil, errors.New("multipart request must contain a csv file in the file field")
+		}
+		defer file.Close()
+		return parseBatchCsv(file)
+	case "", "application/json":
+		return parseBatchJson(r.Body)
+	default:
+		return nil, fmt.Errorf("unsupported content type %q, use application/json, text/csv or multipart/form-data", mediaType)
+	}
+}
+
+// parseBatchJson parses an array of addresses, or of objects with an address and an optional label and start block
+func parseBatchJson(body io.Reader) ([]batchEntry, error) {
+	var elements []json.RawMessage
+	if err := json.NewDecoder(body).Decode(&elements); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, fmt.Errorf("request body must not be larger than %d bytes", maxBytesErr.Limit)
+		}
+		return nil, errors.New("request body must be a json array")
+	}
+
+	entries := make([]batchEntry, len(elements))
+	for i, element := range elements {
+		var address string
+		if err := json.Unmarshal(element, &address); err == nil {
+			entries[i] = batchEntry{address: address, request: services.SubscribeRequest{Address: address}}
+			continue
+		}
+
+		var object struct {
+			Address    string `json:"address"`
+			Label      string `json:"label"`
+			StartBlock *int   `json:"startBlock"`
+		}
+		if err := json.Unmarshal(element, &object); err != nil {
+			entries[i] = batchEntry{address: string(element), err: errors.New("entry must be an address or an object with address, label and startBlock")}
+			continue
+		}
+		entries[i] = batchEntry{
+			address: object.Address,
+			request: services.SubscribeRequest{
+				Address: object.Address,
+				Options: services.SubscribeOptions{Label: object.Label, StartBlock: object.StartBlock},
+			},
+		}
+	}
+	return entries, nil
+}
+
+// parseBatchCsv parses rows of address, optional label and optional start block. A header row is skipped.
+func parseBatchCsv(body io.Reader) ([]batchEntry, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	entries := make([]batchEntry, 0)
+	for row := 0; ; row++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				return nil, fmt.Errorf("request body must not be larger than %d bytes", maxBytesErr.Limit)
+			}
+			return nil, fmt.Errorf("request body must be valid csv: %w", err)
+		}
+		if row == 0 && strings.EqualFold(strings.TrimSpace(record[0]), "address") {
+			continue
+		}
+
+		entry := batchEntry{address: strings.TrimSpace(record[0])}
+		entry.request.Address = entry.address
+		if len(record) > 1 {
+			entry.request.Options.Label = strings.TrimSpace(record[1])
+		}
+		if len(record) > 2 && strings.TrimSpace(record[2]) != "" {
+			if startBlock, err := strconv.Atoi(strings.TrimSpace(record[2])); err != nil {
+				entry.err = errors.New("start block must be an integer")
+			} else {
+				entry.request.Options.StartBlock = &startBlock
+			}
+		}
+		if len(record) > 3 {
+			entry.err = errors.New("row must have at most 3 columns: address, label and start block")
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
@@ -0,0 +1,146 @@
+package httphandler
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aniladanir/ethereum-blockchain-parser/pkg/errs"
+)
+
+func TestSubscribeBatchHandler(t *testing.T) {
+	multipartBody := func(field, content string) (string, string) {
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		part, _ := writer.CreateFormFile(field, "addresses.csv")
+		_, _ = part.Write([]byte(content))
+		_ = writer.Close()
+		return body.String(), writer.FormDataContentType()
+	}
+	csvUpload, csvUploadType := multipartBody("file", "address,label\n"+testAddress+",exchange\n")
+	wrongField, wrongFieldType := multipartBody("addresses", testAddress)
+
+	tests := []struct {
+		name             string
+		txParser         *MockTxParser
+		contentType      string
+		body             string
+		expectedStatus   int
+		expectedBody     string
+		expectedRequests int
+	}{
+		{
+			name:           "Json",
+			txParser:       &MockTxParser{},
+			contentType:    "application/json",
+			body:           `["` + testAddress + `", {"address": "0x123"}, {"address": "` + testAddress + `", "label": "exchange", "startBlock": 100}, 42]`,
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"msg":"success","data":{"created":2,"alreadyExists":0,"invalid":2,"results":[` +
+				`{"address":"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed","status":"created"},` +
+				`{"address":"0x123","status":"invalid","error":"invalid address: expected 40 hex characters, got 3"},` +
+				`{"address":"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed","status":"created"},` +
+				`{"address":"42","status":"invalid","error":"entry must be an address or an object with address, label and startBlock"}]}}
+`,
+			expectedRequests: 3,
+		},
+		{
+			name:           "Already Exists",
+			txParser:       &MockTxParser{subscribeError: errs.AlreadyExistErr()},
+			body:           `["` + testAddress + `"]`,
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"msg":"success","data":{"created":0,"alreadyExists":1,"invalid":0,"results":[` +
+				`{"address":"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed","status":"already_exists"}]}}
+`,
+			expectedRequests: 1,
+		},
+		{
+			name:           "Csv",
+			txParser:       &MockTxParser{},
+			contentType:    "text/csv",
+			body:           "address,label,startBlock\n" + testAddress + ",exchange,100\n" + testAddress + ",,soon\n",
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"msg":"success","data":{"created":1,"alreadyExists":0,"invalid":1,"results":[` +
+				`{"address":"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed","status":"created"},` +
+				`{"address":"0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed","status":"invalid","error":"start block must be an integer"}]}}
+`,
+			expectedRequests: 1,
+		},
+		{
+			name:           "Csv Upload",
+			txParser:       &MockTxParser{},
+			contentType:    csvUploadType,
+			body:           csvUpload,
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"msg":"success","data":{"created":1,"alreadyExists":0,"invalid":0,"results":[` +
+				`{"address":"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed","status":"created"}]}}
+`,
+			expectedRequests: 1,
+		},
+		{
+			name:           "Missing Upload",
+			txParser:       &MockTxParser{},
+			contentType:    wrongFieldType,
+			body:           wrongField,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "multipart request must contain a csv file in the file field\n",
+		},
+		{
+			name:           "Not An Array",
+			txParser:       &MockTxParser{},
+			contentType:    "application/json",
+			body:           `{"address": "` + testAddress + `"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "request body must be a json array\n",
+		},
+		{
+			name:           "Empty",
+			txParser:       &MockTxParser{},
+			contentType:    "application/json",
+			body:           `[]`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "request contains no addresses\n",
+		},
+		{
+			name:           "Too Many Addresses",
+			txParser:       &MockTxParser{},
+			contentType:    "text/csv",
+			body:           strings.Repeat(testAddress+"\n", maxBatchSize+1),
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "request contains more than 10000 addresses\n",
+		},
+		{
+			name:           "Unsupported Content Type",
+			txParser:       &MockTxParser{},
+			contentType:    "application/xml",
+			body:           "<addresses/>",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "unsupported content type \"application/xml\", use application/json, text/csv or multipart/form-data\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := setupTest(tt.txParser)
+			req := httptest.NewRequest(http.MethodPost, "/subscriptions:batch", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+
+			rec := httptest.NewRecorder()
+			h.subscribeBatch(rec, req)
+
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("expected status code %d, got %d", tt.expectedStatus, rec.Code)
+			}
+			actualBody := rec.Body.String()
+			if actualBody != tt.expectedBody {
+				t.Errorf("expected body %q, got %q", tt.expectedBody, actualBody)
+			}
+			if len(tt.txParser.batchRequests) != tt.expectedRequests {
+				t.Errorf("expected %d requests passed to the parser, got %d", tt.expectedRequests, len(tt.txParser.batchRequests))
+			}
+		})
+	}
+}
@@ -3,10 +3,17 @@ package httphandler
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
+	"math/big"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/aniladanir/ethereum-blockchain-parser/internal/adapters/blockchain"
 	"github.com/aniladanir/ethereum-blockchain-parser/internal/core/domain"
 	"github.com/aniladanir/ethereum-blockchain-parser/internal/core/services"
 	"github.com/aniladanir/ethereum-blockchain-parser/pkg/errs"
@@ -39,8 +46,13 @@ func NewHttpHandler(addr string, txParser services.TransactionParser, logger *sl
 	// register handlers
 	mux := http.NewServeMux()
 	mux.HandleFunc("/api/block", httpHandler.getCurrentBlockNumber)
-	mux.HandleFunc("/api/subscribe", httpHandler.subscribeToAddress)
+	mux.HandleFunc("/api/subscribe", httpHandler.handleSubscription)
+	mux.HandleFunc("/api/subscriptions", httpHandler.listSubscriptions)
+	mux.HandleFunc("/api/subscriptions:batch", httpHandler.subscribeBatch)
 	mux.HandleFunc("/api/transactions", httpHandler.getTransactionsByAddress)
+	mux.HandleFunc("/api/token-transfers", httpHandler.getTokenTransfersByAddress)
+	mux.HandleFunc("/api/nft-transfers", httpHandler.getNFTTransfersByAddress)
+	mux.HandleFunc("/api/rpc/health", httpHandler.getRpcHealth)
 	httpHandler.server.Handler = mux
 
 	return httpHandler
@@ -86,6 +98,16 @@ func (h *HttpHandler) getCurrentBlockNumber(w http.ResponseWriter, r *http.Reque
 	}
 }
 
+// handleSubscription dispatches the requests of the subscribe endpoint by method
+func (h *HttpHandler) handleSubscription(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodDelete:
+		h.unsubscribeFromAddress(w, r)
+	default:
+		h.subscribeToAddress(w, r)
+	}
+}
+
 func (h *HttpHandler) subscribeToAddress(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -96,16 +118,46 @@ func (h *HttpHandler) subscribeToAddress(w http.ResponseWriter, r *http.Request)
 	w.Header().Set("Content-Type", "application/json")
 
 	// get query params
-	address := r.URL.Query().Get("address")
-	if address == "" {
-		http.Error(w, "address query param is required", http.StatusBadRequest)
-		h.logger.Error("missing address query param")
+	address, ok := h.parseAddressParam(w, r)
+	if !ok {
+		return
+	}
+
+	// get optional backfill start
+	var opts services.SubscribeOptions
+	if startBlockParam := r.URL.Query().Get("startBlock"); startBlockParam != "" {
+		startBlock, err := strconv.Atoi(startBlockParam)
+		if err != nil || startBlock < 0 {
+			http.Error(w, "startBlock query param must be a non-negative integer", http.StatusBadRequest)
+			h.logger.Error("invalid startBlock query param", slog.String("startBlock", startBlockParam))
+			return
+		}
+		opts.StartBlock = &startBlock
+	}
+	if startTimeParam := r.URL.Query().Get("startTime"); startTimeParam != "" {
+		startTime, err := parseTime(startTimeParam)
+		if err != nil {
+			http.Error(w, "startTime query param must be a RFC3339 timestamp or unix seconds", http.StatusBadRequest)
+			h.logger.Error("invalid startTime query param", slog.String("startTime", startTimeParam))
+			return
+		}
+		opts.StartTime = &startTime
+	}
+
+	// get optional label
+	opts.Label = r.URL.Query().Get("label")
+	if len(opts.Label) > services.MaxLabelLength {
+		http.Error(w, fmt.Sprintf("label query param must be at most %d bytes", services.MaxLabelLength), http.StatusBadRequest)
+		h.logger.Error("invalid label query param", slog.Int("length", len(opts.Label)))
 		return
 	}
 
 	// subscribe to the provided address
-	if err := h.txParser.Subscribe(r.Context(), address); err != nil {
-		if errs.IsAlreadyExistErr(err) {
+	if err := h.txParser.Subscribe(r.Context(), address.String(), opts); err != nil {
+		if errs.IsInvalidAddressErr(err) {
+			http.Error(w, "provided address is not a valid ethereum address", http.StatusBadRequest)
+			h.logger.Error(err.Error())
+		} else if errs.IsAlreadyExistErr(err) {
 			http.Error(w, "provided address is already subscribed", http.StatusConflict)
 			h.logger.Error(err.Error())
 		} else {
@@ -118,6 +170,11 @@ func (h *HttpHandler) subscribeToAddress(w http.ResponseWriter, r *http.Request)
 	// write to response body
 	err := json.NewEncoder(w).Encode(&Response{
 		Msg: "success",
+		Data: struct {
+			Address string `json:"address"`
+		}{
+			Address: address.Checksum(),
+		},
 	})
 	if err != nil {
 		http.Error(w, "internal server error", http.StatusInternalServerError)
@@ -125,8 +182,8 @@ func (h *HttpHandler) subscribeToAddress(w http.ResponseWriter, r *http.Request)
 	}
 }
 
-func (h *HttpHandler) getTransactionsByAddress(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
+func (h *HttpHandler) unsubscribeFromAddress(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
@@ -135,18 +192,30 @@ func (h *HttpHandler) getTransactionsByAddress(w http.ResponseWriter, r *http.Re
 	w.Header().Set("Content-Type", "application/json")
 
 	// get query params
-	address := r.URL.Query().Get("address")
-	if address == "" {
-		http.Error(w, "address query param is required", http.StatusBadRequest)
-		h.logger.Error("missing address query param")
+	address, ok := h.parseAddressParam(w, r)
+	if !ok {
 		return
 	}
 
-	// get transactions belonging to the given address
-	transactions, err := h.txParser.GetTransactions(r.Context(), address)
-	if err != nil {
-		if errs.IsNotFoundErr(err) {
-			http.Error(w, "the address does not exist in our records", http.StatusNotFound)
+	// get whether the stored transactions are removed
+	var opts services.UnsubscribeOptions
+	if purgeParam := r.URL.Query().Get("purge"); purgeParam != "" {
+		purge, err := strconv.ParseBool(purgeParam)
+		if err != nil {
+			http.Error(w, "purge query param must be true or false", http.StatusBadRequest)
+			h.logger.Error("invalid purge query param", slog.String("purge", purgeParam))
+			return
+		}
+		opts.PurgeHistory = purge
+	}
+
+	// unsubscribe from the provided address
+	if err := h.txParser.Unsubscribe(r.Context(), address.String(), opts); err != nil {
+		if errs.IsInvalidAddressErr(err) {
+			http.Error(w, "provided address is not a valid ethereum address", http.StatusBadRequest)
+			h.logger.Error(err.Error())
+		} else if errs.IsNotFoundErr(err) {
+			http.Error(w, "provided address is not subscribed", http.StatusNotFound)
 			h.logger.Error(err.Error())
 		} else {
 			http.Error(w, "internal server error", http.StatusInternalServerError)
@@ -155,13 +224,128 @@ func (h *HttpHandler) getTransactionsByAddress(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	// write to response body
+	err := json.NewEncoder(w).Encode(&Response{
+		Msg: "success",
+		Data: struct {
+			Address string `json:"address"`
+			Purged  bool   `json:"purged"`
+		}{
+			Address: address.Checksum(),
+			Purged:  opts.PurgeHistory,
+		},
+	})
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		h.logger.Error("error writing to response body", slog.Any("error", err))
+	}
+}
+
+func (h *HttpHandler) listSubscriptions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// set content type
+	w.Header().Set("Content-Type", "application/json")
+
+	// get subscribed addresses
+	subscriptions, err := h.txParser.ListSubscriptions(r.Context())
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		h.logger.Error(err.Error())
+		return
+	}
+
+	type subscription struct {
+		Address string `json:"address"`
+		Label   string `json:"label,omitempty"`
+	}
+	result := make([]subscription, len(subscriptions))
+	for i := range subscriptions {
+		result[i] = subscription{Address: subscriptions[i].Address.Checksum(), Label: subscriptions[i].Label}
+	}
+
+	// write to response body
+	err = json.NewEncoder(w).Encode(&Response{
+		Msg: "success",
+		Data: struct {
+			Subscriptions []subscription `json:"subscriptions"`
+		}{
+			Subscriptions: result,
+		},
+	})
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		h.logger.Error("error writing to response body", slog.Any("error", err))
+	}
+}
+
+func (h *HttpHandler) getTransactionsByAddress(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// set content type
+	w.Header().Set("Content-Type", "application/json")
+
+	// get query params
+	address, ok := h.parseAddressParam(w, r)
+	if !ok {
+		return
+	}
+
+	// get pagination, ordering and filter params
+	query, err := parseTransactionQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		h.logger.Error("invalid transactions query params", slog.Any("error", err))
+		return
+	}
+	query.Address = address.String()
+	format, err := parseValueFormat(r.URL.Query().Get("valueFormat"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		h.logger.Error("invalid valueFormat query param", slog.String("valueFormat", r.URL.Query().Get("valueFormat")))
+		return
+	}
+
+	// get transactions belonging to the given address
+	page, err := h.txParser.QueryTransactions(r.Context(), query)
+	if err != nil {
+		h.writeQueryError(w, err)
+		return
+	}
+
 	// write to response body
 	err = json.NewEncoder(w).Encode(&Response{
+		Msg:  "success",
+		Data: newTransactionPageResponse(&page, format),
+	})
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		h.logger.Error("error writing to response body", slog.Any("error", err))
+	}
+}
+
+func (h *HttpHandler) getRpcHealth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// set content type
+	w.Header().Set("Content-Type", "application/json")
+
+	// write to response body
+	err := json.NewEncoder(w).Encode(&Response{
 		Msg: "success",
 		Data: struct {
-			Transactions []domain.Transaction `json:"transactions"`
+			Endpoints []blockchain.EndpointHealth `json:"endpoints"`
 		}{
-			Transactions: transactions,
+			Endpoints: h.txParser.GetRpcHealth(r.Context()),
 		},
 	})
 	if err != nil {
@@ -169,3 +353,151 @@ func (h *HttpHandler) getTransactionsByAddress(w http.ResponseWriter, r *http.Re
 		h.logger.Error("error writing to response body", slog.Any("error", err))
 	}
 }
+
+// parseAddressParam parses the required address query param. An error response is written if the param is
+// missing or malformed, in which case false is returned.
+// writeQueryError writes the response of an error of a paginated query of the records of an address
+func (h *HttpHandler) writeQueryError(w http.ResponseWriter, err error) {
+	if errs.IsInvalidAddressErr(err) {
+		http.Error(w, "provided address is not a valid ethereum address", http.StatusBadRequest)
+	} else if errs.IsInvalidCursorErr(err) {
+		http.Error(w, "provided cursor is not valid", http.StatusBadRequest)
+	} else if errs.IsNotFoundErr(err) {
+		http.Error(w, "the address does not exist in our records", http.StatusNotFound)
+	} else {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+	}
+	h.logger.Error(err.Error())
+}
+
+func (h *HttpHandler) parseAddressParam(w http.ResponseWriter, r *http.Request) (domain.Address, bool) {
+	addressParam := r.URL.Query().Get("address")
+	if addressParam == "" {
+		http.Error(w, "address query param is required", http.StatusBadRequest)
+		h.logger.Error("missing address query param")
+		return "", false
+	}
+
+	address, err := domain.ParseAddress(addressParam)
+	if err != nil {
+		http.Error(w, "provided address is not a valid ethereum address", http.StatusBadRequest)
+		h.logger.Error("invalid address query param", slog.Any("error", err))
+		return "", false
+	}
+	return address, true
+}
+
+// parseTransactionQuery parses the optional pagination, ordering and filter query params of the transactions
+// endpoint. The returned error is meant to be sent to the client.
+func parseTransactionQuery(values url.Values) (domain.TransactionQuery, error) {
+	var query domain.TransactionQuery
+
+	var err error
+	if query.Limit, query.Order, err = parsePageParams(values); err != nil {
+		return query, err
+	}
+	query.Cursor = values.Get("cursor")
+	if query.FromBlock, query.ToBlock, err = parseBlockRangeParams(values); err != nil {
+		return query, err
+	}
+	if query.Direction, err = parseDirectionParam(values); err != nil {
+		return query, err
+	}
+
+	if counterpartyParam := values.Get("counterparty"); counterpartyParam != "" {
+		counterparty, err := domain.ParseAddress(counterpartyParam)
+		if err != nil {
+			return query, errors.New("counterparty query param is not a valid ethereum address")
+		}
+		query.Counterparty = counterparty.String()
+	}
+
+	if minValueParam := values.Get("minValue"); minValueParam != "" {
+		minValue, ok := parseWei(minValueParam)
+		if !ok {
+			return query, errors.New("minValue query param must be a non-negative decimal or 0x prefixed hex amount of wei")
+		}
+		query.MinValue = minValue
+	}
+
+	return query, nil
+}
+
+// parsePageParams parses the optional limit and order query params
+func parsePageParams(values url.Values) (int, domain.SortOrder, error) {
+	var limit int
+	if limitParam := values.Get("limit"); limitParam != "" {
+		var err error
+		limit, err = strconv.Atoi(limitParam)
+		if err != nil || limit < 1 || limit > services.MaxQueryLimit {
+			return 0, "", fmt.Errorf("limit query param must be an integer between 1 and %d", services.MaxQueryLimit)
+		}
+	}
+
+	order := domain.SortOrder(values.Get("order"))
+	if order != "" && !order.Valid() {
+		return 0, "", errors.New("order query param must be asc or desc")
+	}
+	return limit, order, nil
+}
+
+// parseBlockRangeParams parses the optional fromBlock and toBlock query params
+func parseBlockRangeParams(values url.Values) (*int, *int, error) {
+	fromBlock, err := parseBlockParam(values, "fromBlock")
+	if err != nil {
+		return nil, nil, err
+	}
+	toBlock, err := parseBlockParam(values, "toBlock")
+	if err != nil {
+		return nil, nil, err
+	}
+	if fromBlock != nil && toBlock != nil && *fromBlock > *toBlock {
+		return nil, nil, errors.New("fromBlock query param must not be greater than toBlock")
+	}
+	return fromBlock, toBlock, nil
+}
+
+// parseDirectionParam parses the optional direction query param
+func parseDirectionParam(values url.Values) (domain.Direction, error) {
+	direction := domain.Direction(values.Get("direction"))
+	if !direction.Valid() {
+		return "", errors.New("direction query param must be in or out")
+	}
+	return direction, nil
+}
+
+// parseBlockParam parses an optional block number query param
+func parseBlockParam(values url.Values, name string) (*int, error) {
+	blockParam := values.Get(name)
+	if blockParam == "" {
+		return nil, nil
+	}
+	blockNumber, err := strconv.Atoi(blockParam)
+	if err != nil || blockNumber < 0 {
+		return nil, fmt.Errorf("%s query param must be a non-negative integer", name)
+	}
+	return &blockNumber, nil
+}
+
+// parseWei parses a non-negative amount of wei given as decimal or 0x prefixed hex
+func parseWei(value string) (*big.Int, bool) {
+	var wei *big.Int
+	var ok bool
+	if hex, found := strings.CutPrefix(value, "0x"); found {
+		wei, ok = new(big.Int).SetString(hex, 16)
+	} else {
+		wei, ok = new(big.Int).SetString(value, 10)
+	}
+	if !ok || wei.Sign() < 0 {
+		return nil, false
+	}
+	return wei, true
+}
+
+// parseTime parses either a RFC3339 timestamp or unix seconds
+func parseTime(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -3,37 +3,97 @@ package httphandler
 import (
 	"context"
 	"log/slog"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/aniladanir/ethereum-blockchain-parser/internal/adapters/blockchain"
 	"github.com/aniladanir/ethereum-blockchain-parser/internal/core/domain"
+	"github.com/aniladanir/ethereum-blockchain-parser/internal/core/services"
 	"github.com/aniladanir/ethereum-blockchain-parser/pkg/errs"
 )
 
 // Define a mock struct for txParser for testing
 type MockTxParser struct {
-	currentBlock      int
-	transactions      []domain.Transaction
-	subscribeError    error
-	transactionsError error
+	currentBlock       int
+	transactions       []domain.Transaction
+	nextCursor         string
+	query              domain.TransactionQuery
+	subscribeError     error
+	unsubscribeError   error
+	unsubscribeOpts    services.UnsubscribeOptions
+	subscriptions      []domain.Subscription
+	batchRequests      []services.SubscribeRequest
+	transactionsError  error
+	tokenTransfers     []domain.TokenTransfer
+	tokenTransferQuery domain.TokenTransferQuery
+	nftTransfers       []domain.NFTTransfer
+	nftTransferQuery   domain.NFTTransferQuery
+	rpcHealth          []blockchain.EndpointHealth
 }
 
 func (m *MockTxParser) GetCurrentBlock(ctx context.Context) (int, error) {
 	return m.currentBlock, nil
 }
-func (m *MockTxParser) Subscribe(ctx context.Context, address string) error {
+func (m *MockTxParser) Subscribe(ctx context.Context, address string, opts services.SubscribeOptions) error {
 	return m.subscribeError
 }
+func (m *MockTxParser) Unsubscribe(ctx context.Context, address string, opts services.UnsubscribeOptions) error {
+	m.unsubscribeOpts = opts
+	return m.unsubscribeError
+}
+func (m *MockTxParser) SubscribeBatch(ctx context.Context, requests []services.SubscribeRequest) ([]services.SubscribeResult, error) {
+	m.batchRequests = requests
+	results := make([]services.SubscribeResult, len(requests))
+	for i := range requests {
+		addr, err := domain.ParseAddress(requests[i].Address)
+		switch {
+		case err != nil:
+			results[i] = services.SubscribeResult{Status: services.SubscribeStatusInvalid, Err: err}
+		case m.subscribeError != nil:
+			results[i] = services.SubscribeResult{Address: addr, Status: services.SubscribeStatusAlreadyExists}
+		default:
+			results[i] = services.SubscribeResult{Address: addr, Status: services.SubscribeStatusCreated}
+		}
+	}
+	return results, nil
+}
+func (m *MockTxParser) ListSubscriptions(ctx context.Context) ([]domain.Subscription, error) {
+	return m.subscriptions, nil
+}
 func (m *MockTxParser) GetTransactions(ctx context.Context, address string) ([]domain.Transaction, error) {
 	return m.transactions, m.transactionsError
 }
+func (m *MockTxParser) QueryTransactions(ctx context.Context, query domain.TransactionQuery) (domain.TransactionPage, error) {
+	m.query = query
+	return domain.TransactionPage{Transactions: m.transactions, NextCursor: m.nextCursor}, m.transactionsError
+}
+func (m *MockTxParser) QueryTokenTransfers(ctx context.Context, query domain.TokenTransferQuery) (domain.TokenTransferPage, error) {
+	m.tokenTransferQuery = query
+	return domain.TokenTransferPage{TokenTransfers: m.tokenTransfers, NextCursor: m.nextCursor}, m.transactionsError
+}
+func (m *MockTxParser) QueryNFTTransfers(ctx context.Context, query domain.NFTTransferQuery) (domain.NFTTransferPage, error) {
+	m.nftTransferQuery = query
+	return domain.NFTTransferPage{NFTTransfers: m.nftTransfers, NextCursor: m.nextCursor}, m.transactionsError
+}
+func (m *MockTxParser) GetRpcHealth(ctx context.Context) []blockchain.EndpointHealth {
+	return m.rpcHealth
+}
 func (m *MockTxParser) ProcessNewBlocks(ctx context.Context, interval time.Duration) error {
 	return nil
 }
 
+func intPtr(i int) *int {
+	return &i
+}
+
+const testAddress = "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed"
+
 func setupTest(txParser *MockTxParser) *HttpHandler {
 	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
 	return &HttpHandler{txParser: txParser, logger: logger}
@@ -84,9 +144,9 @@ func TestSubscribeHandler(t *testing.T) {
 		{
 			name:           "Success",
 			txParser:       &MockTxParser{},
-			address:        "0x123",
+			address:        testAddress,
 			expectedStatus: http.StatusOK,
-			expectedBody: `{"msg":"success"}
+			expectedBody: `{"msg":"success","data":{"address":"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"}}
 `,
 		},
 		{
@@ -96,13 +156,64 @@ func TestSubscribeHandler(t *testing.T) {
 			expectedStatus: http.StatusBadRequest,
 			expectedBody:   "address query param is required\n",
 		},
+		{
+			name:           "Invalid Address",
+			txParser:       &MockTxParser{},
+			address:        "0x123",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "provided address is not a valid ethereum address\n",
+		},
+		{
+			name:           "Invalid Checksum",
+			txParser:       &MockTxParser{},
+			address:        "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAeD",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "provided address is not a valid ethereum address\n",
+		},
 		{
 			name:           "Already Subscribed",
 			txParser:       &MockTxParser{subscribeError: errs.AlreadyExistErr()},
-			address:        "0x123",
+			address:        testAddress,
 			expectedStatus: http.StatusConflict,
 			expectedBody:   "provided address is already subscribed\n",
 		},
+		{
+			name:           "With Start Block",
+			txParser:       &MockTxParser{},
+			address:        testAddress + "&startBlock=100",
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"msg":"success","data":{"address":"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"}}
+`,
+		},
+		{
+			name:           "Invalid Start Block",
+			txParser:       &MockTxParser{},
+			address:        testAddress + "&startBlock=-1",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "startBlock query param must be a non-negative integer\n",
+		},
+		{
+			name:           "With Label",
+			txParser:       &MockTxParser{},
+			address:        testAddress + "&label=exchange",
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"msg":"success","data":{"address":"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"}}
+`,
+		},
+		{
+			name:           "Label Too Long",
+			txParser:       &MockTxParser{},
+			address:        testAddress + "&label=" + strings.Repeat("a", services.MaxLabelLength+1),
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "label query param must be at most 256 bytes\n",
+		},
+		{
+			name:           "Invalid Start Time",
+			txParser:       &MockTxParser{},
+			address:        testAddress + "&startTime=yesterday",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "startTime query param must be a RFC3339 timestamp or unix seconds\n",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -124,21 +235,134 @@ func TestSubscribeHandler(t *testing.T) {
 	}
 }
 
+func TestUnsubscribeHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		txParser       *MockTxParser
+		params         string
+		expectedStatus int
+		expectedBody   string
+		expectedPurge  bool
+	}{
+		{
+			name:           "Keep History",
+			txParser:       &MockTxParser{},
+			params:         "address=" + testAddress,
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"msg":"success","data":{"address":"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed","purged":false}}
+`,
+		},
+		{
+			name:           "Purge History",
+			txParser:       &MockTxParser{},
+			params:         "address=" + testAddress + "&purge=true",
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"msg":"success","data":{"address":"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed","purged":true}}
+`,
+			expectedPurge: true,
+		},
+		{
+			name:           "Missing Address",
+			txParser:       &MockTxParser{},
+			params:         "",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "address query param is required\n",
+		},
+		{
+			name:           "Invalid Purge",
+			txParser:       &MockTxParser{},
+			params:         "address=" + testAddress + "&purge=maybe",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "purge query param must be true or false\n",
+		},
+		{
+			name:           "Not Subscribed",
+			txParser:       &MockTxParser{unsubscribeError: errs.NotFoundErr()},
+			params:         "address=" + testAddress,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   "provided address is not subscribed\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := setupTest(tt.txParser)
+			req := httptest.NewRequest(http.MethodDelete, "/subscribe?"+tt.params, nil)
+
+			rec := httptest.NewRecorder()
+			h.handleSubscription(rec, req)
+
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("expected status code %d, got %d", tt.expectedStatus, rec.Code)
+			}
+			actualBody := rec.Body.String()
+			if actualBody != tt.expectedBody {
+				t.Errorf("expected body %q, got %q", tt.expectedBody, actualBody)
+			}
+			if tt.txParser.unsubscribeOpts.PurgeHistory != tt.expectedPurge {
+				t.Errorf("expected purge %t, got %t", tt.expectedPurge, tt.txParser.unsubscribeOpts.PurgeHistory)
+			}
+		})
+	}
+}
+
+func TestSubscriptionsHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		txParser       *MockTxParser
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Success",
+			txParser:       &MockTxParser{subscriptions: []domain.Subscription{{Address: testAddress, Label: "exchange"}, {Address: "0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359"}}},
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"msg":"success","data":{"subscriptions":[{"address":"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed","label":"exchange"},{"address":"0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359"}]}}
+`,
+		},
+		{
+			name:           "Empty",
+			txParser:       &MockTxParser{subscriptions: []domain.Subscription{}},
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"msg":"success","data":{"subscriptions":[]}}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := setupTest(tt.txParser)
+			req := httptest.NewRequest(http.MethodGet, "/subscriptions", nil)
+
+			rec := httptest.NewRecorder()
+			h.listSubscriptions(rec, req)
+
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("expected status code %d, got %d", tt.expectedStatus, rec.Code)
+			}
+			actualBody := rec.Body.String()
+			if actualBody != tt.expectedBody {
+				t.Errorf("expected body %q, got %q", tt.expectedBody, actualBody)
+			}
+		})
+	}
+}
+
 func TestTransactionsHandler(t *testing.T) {
 
 	tests := []struct {
 		name           string
 		txParser       *MockTxParser
 		address        string
+		params         string
 		expectedStatus int
 		expectedBody   string
+		expectedQuery  *domain.TransactionQuery
 	}{
 		{
 			name:           "Success",
-			txParser:       &MockTxParser{transactions: []domain.Transaction{{Hash: "hash1", From: "from1", To: "to1", Value: "100", BlockNumber: "1"}}},
-			address:        "0x123",
+			txParser:       &MockTxParser{transactions: []domain.Transaction{{Hash: "hash1", From: "from1", To: "to1", Value: big.NewInt(100), BlockNumber: 1}}},
+			address:        testAddress,
 			expectedStatus: http.StatusOK,
-			expectedBody: `{"msg":"success","data":{"transactions":[{"hash":"hash1","from":"from1","to":"to1","value":"100","blockNumber":"1"}]}}
+			expectedBody: `{"msg":"success","data":{"transactions":[{"hash":"hash1","from":"from1","to":"to1","value":"0x64","blockNumber":"0x1","transactionIndex":0,"type":0,"nonce":0,"gas":0}]}}
 `,
 		},
 		{
@@ -148,18 +372,158 @@ func TestTransactionsHandler(t *testing.T) {
 			expectedStatus: http.StatusBadRequest,
 			expectedBody:   "address query param is required\n",
 		},
+		{
+			name:           "Invalid Address",
+			txParser:       &MockTxParser{},
+			address:        "hello",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "provided address is not a valid ethereum address\n",
+		},
 		{
 			name:           "Address Not Found",
 			txParser:       &MockTxParser{transactionsError: errs.NotFoundErr()},
-			address:        "0x123",
+			address:        testAddress,
 			expectedStatus: http.StatusNotFound,
 			expectedBody:   "the address does not exist in our records\n",
 		},
+		{
+			name:           "Next Page",
+			txParser:       &MockTxParser{transactions: []domain.Transaction{{Hash: "hash1", From: "from1", To: "to1", Value: big.NewInt(100), BlockNumber: 1}}, nextCursor: "MTpoYXNoMQ"},
+			address:        testAddress,
+			params:         "&limit=1",
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"msg":"success","data":{"transactions":[{"hash":"hash1","from":"from1","to":"to1","value":"0x64","blockNumber":"0x1","transactionIndex":0,"type":0,"nonce":0,"gas":0}],"nextCursor":"MTpoYXNoMQ"}}
+`,
+			expectedQuery: &domain.TransactionQuery{Address: testAddress, Limit: 1},
+		},
+		{
+			name:           "Filters",
+			txParser:       &MockTxParser{transactions: []domain.Transaction{}},
+			address:        testAddress,
+			params:         "&cursor=MTpoYXNoMQ&order=desc&fromBlock=10&toBlock=20&direction=in&counterparty=0xFB6916095CA1DF60BB79CE92CE3EA74C37C5D359&minValue=0x3e8",
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"msg":"success","data":{"transactions":[]}}
+`,
+			expectedQuery: &domain.TransactionQuery{
+				Address:      testAddress,
+				FromBlock:    intPtr(10),
+				ToBlock:      intPtr(20),
+				Direction:    domain.DirectionIn,
+				Counterparty: "0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359",
+				MinValue:     big.NewInt(1000),
+				Order:        domain.SortDescending,
+				Cursor:       "MTpoYXNoMQ",
+			},
+		},
+		{
+			name:           "Invalid Limit",
+			txParser:       &MockTxParser{},
+			address:        testAddress,
+			params:         "&limit=0",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "limit query param must be an integer between 1 and 1000\n",
+		},
+		{
+			name:           "Invalid Order",
+			txParser:       &MockTxParser{},
+			address:        testAddress,
+			params:         "&order=up",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "order query param must be asc or desc\n",
+		},
+		{
+			name:           "Invalid Block Range",
+			txParser:       &MockTxParser{},
+			address:        testAddress,
+			params:         "&fromBlock=20&toBlock=10",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "fromBlock query param must not be greater than toBlock\n",
+		},
+		{
+			name:           "Invalid Direction",
+			txParser:       &MockTxParser{},
+			address:        testAddress,
+			params:         "&direction=both",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "direction query param must be in or out\n",
+		},
+		{
+			name:           "Invalid Counterparty",
+			txParser:       &MockTxParser{},
+			address:        testAddress,
+			params:         "&counterparty=0x123",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "counterparty query param is not a valid ethereum address\n",
+		},
+		{
+			name:           "Invalid Min Value",
+			txParser:       &MockTxParser{},
+			address:        testAddress,
+			params:         "&minValue=-1",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "minValue query param must be a non-negative decimal or 0x prefixed hex amount of wei\n",
+		},
+		{
+			name: "Hex Values",
+			txParser: &MockTxParser{transactions: []domain.Transaction{{
+				Hash: "hash1", From: "from1", To: "to1", Value: big.NewInt(1_500_000_000_000_000_000), BlockNumber: 1,
+				Type: 2, ChainID: 1, Gas: 21000, GasPrice: big.NewInt(1_000_000_000), MaxFeePerGas: big.NewInt(2_000_000_000),
+			}}},
+			address:        testAddress,
+			params:         "&valueFormat=hex",
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"msg":"success","data":{"transactions":[{"hash":"hash1","from":"from1","to":"to1","value":"0x14d1120d7b160000","blockNumber":"0x1","transactionIndex":0,"type":2,"chainId":1,"nonce":0,"gas":21000,"gasPrice":"0x3b9aca00","maxFeePerGas":"0x77359400"}]}}
+`,
+		},
+		{
+			name: "Ether Values",
+			txParser: &MockTxParser{transactions: []domain.Transaction{{
+				Hash: "hash1", From: "from1", To: "to1", Value: big.NewInt(1_500_000_000_000_000_000), BlockNumber: 1,
+				Type: 2, ChainID: 1, Gas: 21000, GasPrice: big.NewInt(1_000_000_000), MaxFeePerGas: big.NewInt(2_000_000_000),
+			}}},
+			address:        testAddress,
+			params:         "&valueFormat=ether",
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"msg":"success","data":{"transactions":[{"hash":"hash1","from":"from1","to":"to1","value":"1.5","blockNumber":1,"transactionIndex":0,"type":2,"chainId":1,"nonce":0,"gas":21000,"gasPrice":"0.000000001","maxFeePerGas":"0.000000002"}]}}
+`,
+		},
+		{
+			name: "Wei Values With Receipt",
+			txParser: &MockTxParser{transactions: []domain.Transaction{{
+				Hash: "hash1", From: "from1", To: "to1", Value: big.NewInt(0), BlockNumber: 1,
+				Receipt: &domain.Receipt{
+					TransactionHash: "hash1", Status: domain.ReceiptStatusFailed, GasUsed: 30000, CumulativeGasUsed: 51000,
+					EffectiveGasPrice: big.NewInt(1_000_000_000),
+					Logs:              []domain.Log{{Address: "0xcontract", Topics: []string{"0xtopic"}, Data: "0x", LogIndex: 2}},
+				},
+			}}},
+			address:        testAddress,
+			params:         "&valueFormat=wei",
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"msg":"success","data":{"transactions":[{"hash":"hash1","from":"from1","to":"to1","value":"0","blockNumber":1,"transactionIndex":0,"type":0,"nonce":0,"gas":0,"receipt":{"status":"failed","gasUsed":30000,"cumulativeGasUsed":51000,"effectiveGasPrice":"1000000000","logs":[{"address":"0xcontract","topics":["0xtopic"],"data":"0x","logIndex":2}]}}]}}
+`,
+		},
+		{
+			name:           "Invalid Value Format",
+			txParser:       &MockTxParser{},
+			address:        testAddress,
+			params:         "&valueFormat=gwei",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "valueFormat query param must be wei, hex or ether\n",
+		},
+		{
+			name:           "Invalid Cursor",
+			txParser:       &MockTxParser{transactionsError: errs.InvalidCursorErr()},
+			address:        testAddress,
+			params:         "&cursor=abc",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "provided cursor is not valid\n",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			h := setupTest(tt.txParser)
-			req := httptest.NewRequest(http.MethodGet, "/transactions?address="+tt.address, nil)
+			req := httptest.NewRequest(http.MethodGet, "/transactions?address="+tt.address+tt.params, nil)
 
 			rec := httptest.NewRecorder()
 			h.getTransactionsByAddress(rec, req)
@@ -171,6 +535,56 @@ func TestTransactionsHandler(t *testing.T) {
 			if actualBody != tt.expectedBody {
 				t.Errorf("expected body %q, got %q", tt.expectedBody, actualBody)
 			}
+			if tt.expectedQuery != nil && !reflect.DeepEqual(tt.txParser.query, *tt.expectedQuery) {
+				t.Errorf("expected query %+v, got %+v", *tt.expectedQuery, tt.txParser.query)
+			}
+		})
+	}
+}
+
+func TestRpcHealthHandler(t *testing.T) {
+	lastErrorAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name           string
+		txParser       *MockTxParser
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "Success",
+			txParser: &MockTxParser{rpcHealth: []blockchain.EndpointHealth{
+				{Url: "https://node1", Healthy: true, Score: 11, LatencyMs: 10, Requests: 1},
+			}},
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"msg":"success","data":{"endpoints":[{"url":"https://node1","healthy":true,"score":11,"latencyMs":10,"errorRate":0,"requests":1,"failures":0,"consecutiveFailures":0}]}}
+`,
+		},
+		{
+			name: "Failed",
+			txParser: &MockTxParser{rpcHealth: []blockchain.EndpointHealth{
+				{Url: "https://node1", Healthy: true, Score: 11, LatencyMs: 10, ErrorRate: 1, Requests: 1, Failures: 1,
+					ConsecutiveFailures: 1, LastError: "timeout", LastErrorAt: &lastErrorAt},
+			}},
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"msg":"success","data":{"endpoints":[{"url":"https://node1","healthy":true,"score":11,"latencyMs":10,"errorRate":1,"requests":1,"failures":1,"consecutiveFailures":1,"lastError":"timeout","lastErrorAt":"2024-01-02T03:04:05Z"}]}}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := setupTest(tt.txParser)
+			req := httptest.NewRequest(http.MethodGet, "/rpc/health", nil)
+			rec := httptest.NewRecorder()
+
+			h.getRpcHealth(rec, req)
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("expected status code %d, got %d", tt.expectedStatus, rec.Code)
+			}
+
+			actualBody := rec.Body.String()
+			if actualBody != tt.expectedBody {
+				t.Errorf("expected body %q, got %q", tt.expectedBody, actualBody)
+			}
 		})
 	}
 }
@@ -0,0 +1,74 @@
+package httphandler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/aniladanir/ethereum-blockchain-parser/internal/core/domain"
+)
+
+// nftTransferResponse is an nft transfer with its token id and amount as decimal strings,
+// as they may exceed the precision of json numbers
+type nftTransferResponse struct {
+	Contract        string `json:"contract"`
+	Standard        string `json:"standard"`
+	Operator        string `json:"operator,omitempty"`
+	From            string `json:"from"`
+	To              string `json:"to"`
+	TokenID         string `json:"tokenId"`
+	Amount          string `json:"amount"`
+	BlockNumber     uint64 `json:"blockNumber"`
+	BlockHash       string `json:"blockHash"`
+	TransactionHash string `json:"transactionHash"`
+	LogIndex        uint64 `json:"logIndex"`
+	BatchIndex      uint64 `json:"batchIndex"`
+}
+
+// nftTransferPageResponse is a domain.NFTTransferPage with its numbers rendered as decimal strings
+type nftTransferPageResponse struct {
+	NFTTransfers []nftTransferResponse `json:"nftTransfers"`
+	NextCursor   string                `json:"nextCursor,omitempty"`
+}
+
+func newNFTTransferPageResponse(page *domain.NFTTransferPage) nftTransferPageResponse {
+	response := nftTransferPageResponse{
+		NFTTransfers: make([]nftTransferResponse, len(page.NFTTransfers)),
+		NextCursor:   page.NextCursor,
+	}
+	for i, transfer := range page.NFTTransfers {
+		response.NFTTransfers[i] = nftTransferResponse{
+			Contract:        transfer.Contract,
+			Standard:        string(transfer.Standard),
+			Operator:        transfer.Operator,
+			From:            transfer.From,
+			To:              transfer.To,
+			TokenID:         valueFormatWei.format(transfer.TokenID),
+			Amount:          valueFormatWei.format(transfer.Amount),
+			BlockNumber:     transfer.BlockNumber,
+			BlockHash:       transfer.BlockHash,
+			TransactionHash: transfer.TransactionHash,
+			LogIndex:        transfer.LogIndex,
+			BatchIndex:      transfer.BatchIndex,
+		}
+	}
+	return response
+}
+
+func (h *HttpHandler) getNFTTransfersByAddress(w http.ResponseWriter, r *http.Request) {
+	h.getTransfersByAddress(w, r, "nft transfers", "contract", func(ctx context.Context, query transferQuery) (any, error) {
+		page, err := h.txParser.QueryNFTTransfers(ctx, domain.NFTTransferQuery{
+			Address:   query.Address,
+			Contract:  query.Contract,
+			FromBlock: query.FromBlock,
+			ToBlock:   query.ToBlock,
+			Direction: query.Direction,
+			Order:     query.Order,
+			Limit:     query.Limit,
+			Cursor:    query.Cursor,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return newNFTTransferPageResponse(&page), nil
+	})
+}
@@ -0,0 +1,122 @@
+package httphandler
+
+import (
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/aniladanir/ethereum-blockchain-parser/internal/core/domain"
+	"github.com/aniladanir/ethereum-blockchain-parser/pkg/errs"
+)
+
+func TestNFTTransfersHandler(t *testing.T) {
+	nftTransfers := []domain.NFTTransfer{
+		{
+			Contract: "0xkitties", Standard: domain.NFTStandardERC721, From: "0xfrom", To: testAddress,
+			TokenID: new(big.Int).Lsh(big.NewInt(1), 64), Amount: big.NewInt(1),
+			BlockNumber: 1, BlockHash: "0xblock", TransactionHash: "0xtx", LogIndex: 3,
+		},
+		{
+			Contract: "0xitems", Standard: domain.NFTStandardERC1155, Operator: "0xoperator", From: testAddress, To: "0xto",
+			TokenID: big.NewInt(5), Amount: big.NewInt(20),
+			BlockNumber: 1, BlockHash: "0xblock", TransactionHash: "0xtx", LogIndex: 4, BatchIndex: 1,
+		},
+	}
+
+	tests := []struct {
+		name           string
+		txParser       *MockTxParser
+		address        string
+		params         string
+		expectedStatus int
+		expectedBody   string
+		expectedQuery  *domain.NFTTransferQuery
+	}{
+		{
+			name:           "Success",
+			txParser:       &MockTxParser{nftTransfers: nftTransfers, nextCursor: "MTo0LjE"},
+			address:        testAddress,
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"msg":"success","data":{"nftTransfers":[{"contract":"0xkitties","standard":"erc721","from":"0xfrom","to":"0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed","tokenId":"18446744073709551616","amount":"1","blockNumber":1,"blockHash":"0xblock","transactionHash":"0xtx","logIndex":3,"batchIndex":0},{"contract":"0xitems","standard":"erc1155","operator":"0xoperator","from":"0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed","to":"0xto","tokenId":"5","amount":"20","blockNumber":1,"blockHash":"0xblock","transactionHash":"0xtx","logIndex":4,"batchIndex":1}],"nextCursor":"MTo0LjE"}}
+`,
+			expectedQuery: &domain.NFTTransferQuery{Address: testAddress},
+		},
+		{
+			name:           "Filters",
+			txParser:       &MockTxParser{nftTransfers: []domain.NFTTransfer{}},
+			address:        testAddress,
+			params:         "&limit=10&cursor=MTo0LjE&order=desc&fromBlock=10&toBlock=20&direction=in&contract=0xFB6916095CA1DF60BB79CE92CE3EA74C37C5D359",
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"msg":"success","data":{"nftTransfers":[]}}
+`,
+			expectedQuery: &domain.NFTTransferQuery{
+				Address:   testAddress,
+				Contract:  "0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359",
+				FromBlock: intPtr(10),
+				ToBlock:   intPtr(20),
+				Direction: domain.DirectionIn,
+				Order:     domain.SortDescending,
+				Limit:     10,
+				Cursor:    "MTo0LjE",
+			},
+		},
+		{
+			name:           "Missing Address",
+			txParser:       &MockTxParser{},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "address query param is required\n",
+		},
+		{
+			name:           "Invalid Contract",
+			txParser:       &MockTxParser{},
+			address:        testAddress,
+			params:         "&contract=0x123",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "contract query param is not a valid ethereum address\n",
+		},
+		{
+			name:           "Invalid Limit",
+			txParser:       &MockTxParser{},
+			address:        testAddress,
+			params:         "&limit=1001",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "limit query param must be an integer between 1 and 1000\n",
+		},
+		{
+			name:           "Address Not Found",
+			txParser:       &MockTxParser{transactionsError: errs.NotFoundErr()},
+			address:        testAddress,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   "the address does not exist in our records\n",
+		},
+		{
+			name:           "Invalid Cursor",
+			txParser:       &MockTxParser{transactionsError: errs.InvalidCursorErr()},
+			address:        testAddress,
+			params:         "&cursor=abc",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "provided cursor is not valid\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := setupTest(tt.txParser)
+			req := httptest.NewRequest(http.MethodGet, "/nft-transfers?address="+tt.address+tt.params, nil)
+
+			rec := httptest.NewRecorder()
+			h.getNFTTransfersByAddress(rec, req)
+
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("expected status code %d, got %d", tt.expectedStatus, rec.Code)
+			}
+			if actualBody := rec.Body.String(); actualBody != tt.expectedBody {
+				t.Errorf("expected body %q, got %q", tt.expectedBody, actualBody)
+			}
+			if tt.expectedQuery != nil && !reflect.DeepEqual(tt.txParser.nftTransferQuery, *tt.expectedQuery) {
+				t.Errorf("expected query %+v, got %+v", *tt.expectedQuery, tt.txParser.nftTransferQuery)
+			}
+		})
+	}
+}
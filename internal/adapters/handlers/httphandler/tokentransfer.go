@@ -0,0 +1,66 @@
+package httphandler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/aniladanir/ethereum-blockchain-parser/internal/core/domain"
+)
+
+// tokenTransferResponse is a token transfer with its value as decimal string of the smallest unit of the token,
+// as it may exceed the precision of json numbers
+type tokenTransferResponse struct {
+	Token           string `json:"token"`
+	From            string `json:"from"`
+	To              string `json:"to"`
+	Value           string `json:"value"`
+	BlockNumber     uint64 `json:"blockNumber"`
+	BlockHash       string `json:"blockHash"`
+	TransactionHash string `json:"transactionHash"`
+	LogIndex        uint64 `json:"logIndex"`
+}
+
+// tokenTransferPageResponse is a domain.TokenTransferPage with its values rendered as decimal strings
+type tokenTransferPageResponse struct {
+	TokenTransfers []tokenTransferResponse `json:"tokenTransfers"`
+	NextCursor     string                  `json:"nextCursor,omitempty"`
+}
+
+func newTokenTransferPageResponse(page *domain.TokenTransferPage) tokenTransferPageResponse {
+	response := tokenTransferPageResponse{
+		TokenTransfers: make([]tokenTransferResponse, len(page.TokenTransfers)),
+		NextCursor:     page.NextCursor,
+	}
+	for i, transfer := range page.TokenTransfers {
+		response.TokenTransfers[i] = tokenTransferResponse{
+			Token:           transfer.Token,
+			From:            transfer.From,
+			To:              transfer.To,
+			Value:           valueFormatWei.format(transfer.Value),
+			BlockNumber:     transfer.BlockNumber,
+			BlockHash:       transfer.BlockHash,
+			TransactionHash: transfer.TransactionHash,
+			LogIndex:        transfer.LogIndex,
+		}
+	}
+	return response
+}
+
+func (h *HttpHandler) getTokenTransfersByAddress(w http.ResponseWriter, r *http.Request) {
+	h.getTransfersByAddress(w, r, "token transfers", "token", func(ctx context.Context, query transferQuery) (any, error) {
+		page, err := h.txParser.QueryTokenTransfers(ctx, domain.TokenTransferQuery{
+			Address:   query.Address,
+			Token:     query.Contract,
+			FromBlock: query.FromBlock,
+			ToBlock:   query.ToBlock,
+			Direction: query.Direction,
+			Order:     query.Order,
+			Limit:     query.Limit,
+			Cursor:    query.Cursor,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return newTokenTransferPageResponse(&page), nil
+	})
+}
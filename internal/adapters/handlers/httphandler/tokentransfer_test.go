@@ -0,0 +1,114 @@
+package httphandler
+
+import (
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/aniladanir/ethereum-blockchain-parser/internal/core/domain"
+	"github.com/aniladanir/ethereum-blockchain-parser/pkg/errs"
+)
+
+func TestTokenTransfersHandler(t *testing.T) {
+	tokenTransfer := domain.TokenTransfer{
+		Token: "0xtoken", From: "0xfrom", To: testAddress, Value: new(big.Int).Lsh(big.NewInt(1), 64),
+		BlockNumber: 1, BlockHash: "0xblock", TransactionHash: "0xtx", LogIndex: 3,
+	}
+
+	tests := []struct {
+		name           string
+		txParser       *MockTxParser
+		address        string
+		params         string
+		expectedStatus int
+		expectedBody   string
+		expectedQuery  *domain.TokenTransferQuery
+	}{
+		{
+			name:           "Success",
+			txParser:       &MockTxParser{tokenTransfers: []domain.TokenTransfer{tokenTransfer}, nextCursor: "MTozMw"},
+			address:        testAddress,
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"msg":"success","data":{"tokenTransfers":[{"token":"0xtoken","from":"0xfrom","to":"0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed","value":"18446744073709551616","blockNumber":1,"blockHash":"0xblock","transactionHash":"0xtx","logIndex":3}],"nextCursor":"MTozMw"}}
+`,
+			expectedQuery: &domain.TokenTransferQuery{Address: testAddress},
+		},
+		{
+			name:           "Filters",
+			txParser:       &MockTxParser{tokenTransfers: []domain.TokenTransfer{}},
+			address:        testAddress,
+			params:         "&limit=10&cursor=MTozMw&order=desc&fromBlock=10&toBlock=20&direction=out&token=0xFB6916095CA1DF60BB79CE92CE3EA74C37C5D359",
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"msg":"success","data":{"tokenTransfers":[]}}
+`,
+			expectedQuery: &domain.TokenTransferQuery{
+				Address:   testAddress,
+				Token:     "0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359",
+				FromBlock: intPtr(10),
+				ToBlock:   intPtr(20),
+				Direction: domain.DirectionOut,
+				Order:     domain.SortDescending,
+				Limit:     10,
+				Cursor:    "MTozMw",
+			},
+		},
+		{
+			name:           "Missing Address",
+			txParser:       &MockTxParser{},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "address query param is required\n",
+		},
+		{
+			name:           "Invalid Token",
+			txParser:       &MockTxParser{},
+			address:        testAddress,
+			params:         "&token=0x123",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "token query param is not a valid ethereum address\n",
+		},
+		{
+			name:           "Invalid Direction",
+			txParser:       &MockTxParser{},
+			address:        testAddress,
+			params:         "&direction=both",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "direction query param must be in or out\n",
+		},
+		{
+			name:           "Address Not Found",
+			txParser:       &MockTxParser{transactionsError: errs.NotFoundErr()},
+			address:        testAddress,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   "the address does not exist in our records\n",
+		},
+		{
+			name:           "Invalid Cursor",
+			txParser:       &MockTxParser{transactionsError: errs.InvalidCursorErr()},
+			address:        testAddress,
+			params:         "&cursor=abc",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "provided cursor is not valid\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := setupTest(tt.txParser)
+			req := httptest.NewRequest(http.MethodGet, "/token-transfers?address="+tt.address+tt.params, nil)
+
+			rec := httptest.NewRecorder()
+			h.getTokenTransfersByAddress(rec, req)
+
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("expected status code %d, got %d", tt.expectedStatus, rec.Code)
+			}
+			if actualBody := rec.Body.String(); actualBody != tt.expectedBody {
+				t.Errorf("expected body %q, got %q", tt.expectedBody, actualBody)
+			}
+			if tt.expectedQuery != nil && !reflect.DeepEqual(tt.txParser.tokenTransferQuery, *tt.expectedQuery) {
+				t.Errorf("expected query %+v, got %+v", *tt.expectedQuery, tt.txParser.tokenTransferQuery)
+			}
+		})
+	}
+}
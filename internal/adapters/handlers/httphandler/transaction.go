@@ -0,0 +1,160 @@
+package httphandler
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/aniladanir/ethereum-blockchain-parser/internal/core/domain"
+)
+
+// valueFormat selects how amounts of wei are rendered in responses
+type valueFormat string
+
+const (
+	// valueFormatWei renders amounts as decimal wei
+	valueFormatWei valueFormat = "wei"
+	// valueFormatHex renders amounts as 0x prefixed hex wei, like json-rpc quantities, which is the default
+	// as transactions were served with hex values before the formats were introduced
+	valueFormatHex valueFormat = "hex"
+	// valueFormatEther renders amounts as decimal ether
+	valueFormatEther valueFormat = "ether"
+)
+
+func parseValueFormat(value string) (valueFormat, error) {
+	switch format := valueFormat(value); format {
+	case "":
+		return valueFormatHex, nil
+	case valueFormatWei, valueFormatHex, valueFormatEther:
+		return format, nil
+	default:
+		return "", fmt.Errorf("valueFormat query param must be %s, %s or %s", valueFormatWei, valueFormatHex, valueFormatEther)
+	}
+}
+
+// format renders an amount of wei, or an empty string if the amount is absent
+func (f valueFormat) format(wei *big.Int) string {
+	if wei == nil {
+		return ""
+	}
+	switch f {
+	case valueFormatHex:
+		return domain.EncodeQuantity(wei)
+	case valueFormatEther:
+		return domain.FormatEther(wei)
+	default:
+		return wei.String()
+	}
+}
+
+// formatBlockNumber renders a block number as 0x prefixed hex string in hex format, like json-rpc quantities,
+// and as json number otherwise
+func (f valueFormat) formatBlockNumber(blockNumber uint64) any {
+	if f == valueFormatHex {
+		return domain.EncodeUint64Quantity(blockNumber)
+	}
+	return blockNumber
+}
+
+// transactionResponse is a transaction with its amounts rendered in the requested format.
+// Amounts are strings, as they may exceed the precision of json numbers.
+type transactionResponse struct {
+	Hash                 string               `json:"hash"`
+	From                 string               `json:"from"`
+	To                   string               `json:"to"`
+	Value                string               `json:"value"`
+	BlockNumber          any                  `json:"blockNumber"`
+	BlockHash            string               `json:"blockHash,omitempty"`
+	TransactionIndex     uint64               `json:"transactionIndex"`
+	Type                 uint8                `json:"type"`
+	ChainID              uint64               `json:"chainId,omitempty"`
+	Nonce                uint64               `json:"nonce"`
+	Gas                  uint64               `json:"gas"`
+	GasPrice             string               `json:"gasPrice,omitempty"`
+	MaxFeePerGas         string               `json:"maxFeePerGas,omitempty"`
+	MaxPriorityFeePerGas string               `json:"maxPriorityFeePerGas,omitempty"`
+	AccessList           []domain.AccessTuple `json:"accessList,omitempty"`
+	MaxFeePerBlobGas     string               `json:"maxFeePerBlobGas,omitempty"`
+	BlobVersionedHashes  []string             `json:"blobVersionedHashes,omitempty"`
+	Input                string               `json:"input,omitempty"`
+	V                    string               `json:"v,omitempty"`
+	R                    string               `json:"r,omitempty"`
+	S                    string               `json:"s,omitempty"`
+	YParity              string               `json:"yParity,omitempty"`
+	Receipt              *receiptResponse     `json:"receipt,omitempty"`
+}
+
+// receiptResponse is a receipt with its amounts rendered in the requested format
+type receiptResponse struct {
+	// Status is success, failed or unknown for receipts before the byzantium fork
+	Status            string        `json:"status"`
+	GasUsed           uint64        `json:"gasUsed"`
+	CumulativeGasUsed uint64        `json:"cumulativeGasUsed"`
+	EffectiveGasPrice string        `json:"effectiveGasPrice,omitempty"`
+	ContractAddress   string        `json:"contractAddress,omitempty"`
+	Logs              []logResponse `json:"logs"`
+}
+
+type logResponse struct {
+	Address  string   `json:"address"`
+	Topics   []string `json:"topics"`
+	Data     string   `json:"data"`
+	LogIndex uint64   `json:"logIndex"`
+}
+
+func newTransactionResponse(tx *domain.Transaction, format valueFormat) transactionResponse {
+	response := transactionResponse{
+		Hash:                 tx.Hash,
+		From:                 tx.From,
+		To:                   tx.To,
+		Value:                format.format(tx.Value),
+		BlockNumber:          format.formatBlockNumber(tx.BlockNumber),
+		BlockHash:            tx.BlockHash,
+		TransactionIndex:     tx.TransactionIndex,
+		Type:                 tx.Type,
+		ChainID:              tx.ChainID,
+		Nonce:                tx.Nonce,
+		Gas:                  tx.Gas,
+		GasPrice:             format.format(tx.GasPrice),
+		MaxFeePerGas:         format.format(tx.MaxFeePerGas),
+		MaxPriorityFeePerGas: format.format(tx.MaxPriorityFeePerGas),
+		AccessList:           tx.AccessList,
+		MaxFeePerBlobGas:     format.format(tx.MaxFeePerBlobGas),
+		BlobVersionedHashes:  tx.BlobVersionedHashes,
+		Input:                tx.Input,
+		V:                    tx.V,
+		R:                    tx.R,
+		S:                    tx.S,
+		YParity:              tx.YParity,
+	}
+	if tx.Receipt != nil {
+		response.Receipt = &receiptResponse{
+			Status:            tx.Receipt.Status.String(),
+			GasUsed:           tx.Receipt.GasUsed,
+			CumulativeGasUsed: tx.Receipt.CumulativeGasUsed,
+			EffectiveGasPrice: format.format(tx.Receipt.EffectiveGasPrice),
+			ContractAddress:   tx.Receipt.ContractAddress,
+			Logs:              make([]logResponse, len(tx.Receipt.Logs)),
+		}
+		for i, log := range tx.Receipt.Logs {
+			response.Receipt.Logs[i] = logResponse{Address: log.Address, Topics: log.Topics, Data: log.Data, LogIndex: log.LogIndex}
+		}
+	}
+	return response
+}
+
+// transactionPageResponse is a domain.TransactionPage with its amounts rendered in the requested format
+type transactionPageResponse struct {
+	Transactions []transactionResponse `json:"transactions"`
+	NextCursor   string                `json:"nextCursor,omitempty"`
+}
+
+func newTransactionPageResponse(page *domain.TransactionPage, format valueFormat) transactionPageResponse {
+	response := transactionPageResponse{
+		Transactions: make([]transactionResponse, len(page.Transactions)),
+		NextCursor:   page.NextCursor,
+	}
+	for i := range page.Transactions {
+		response.Transactions[i] = newTransactionResponse(&page.Transactions[i], format)
+	}
+	return response
+}
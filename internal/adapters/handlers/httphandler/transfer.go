@@ -0,0 +1,99 @@
+package httphandler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	"github.com/aniladanir/ethereum-blockchain-parser/internal/core/domain"
+)
+
+// transferQuery holds the pagination, ordering and filter params shared by the transfer endpoints
+type transferQuery struct {
+	Address string
+	// Contract is the address of the token or nft contract, empty for the transfers of every contract
+	Contract  string
+	FromBlock *int
+	ToBlock   *int
+	Direction domain.Direction
+	Order     domain.SortOrder
+	Limit     int
+	Cursor    string
+}
+
+// getTransfersByAddress serves a page of the transfers of the address in the request path. kind names the transfers
+// in logs, contractParam is the query param that filters the transfers by contract and queryPage returns the page
+// as response data.
+func (h *HttpHandler) getTransfersByAddress(w http.ResponseWriter, r *http.Request, kind, contractParam string,
+	queryPage func(ctx context.Context, query transferQuery) (any, error)) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// set content type
+	w.Header().Set("Content-Type", "application/json")
+
+	// get query params
+	address, ok := h.parseAddressParam(w, r)
+	if !ok {
+		return
+	}
+
+	// get pagination, ordering and filter params
+	query, err := parseTransferQuery(r.URL.Query(), contractParam)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		h.logger.Error("invalid "+kind+" query params", slog.Any("error", err))
+		return
+	}
+	query.Address = address.String()
+
+	// get transfers belonging to the given address
+	page, err := queryPage(r.Context(), query)
+	if err != nil {
+		h.writeQueryError(w, err)
+		return
+	}
+
+	// write to response body
+	err = json.NewEncoder(w).Encode(&Response{
+		Msg:  "success",
+		Data: page,
+	})
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		h.logger.Error("error writing to response body", slog.Any("error", err))
+	}
+}
+
+// parseTransferQuery parses the optional pagination, ordering and filter query params of the transfer endpoints,
+// with contractParam as the name of the contract filter. The returned error is meant to be sent to the client.
+func parseTransferQuery(values url.Values, contractParam string) (transferQuery, error) {
+	var query transferQuery
+
+	var err error
+	if query.Limit, query.Order, err = parsePageParams(values); err != nil {
+		return query, err
+	}
+	query.Cursor = values.Get("cursor")
+	if query.FromBlock, query.ToBlock, err = parseBlockRangeParams(values); err != nil {
+		return query, err
+	}
+	if query.Direction, err = parseDirectionParam(values); err != nil {
+		return query, err
+	}
+
+	if value := values.Get(contractParam); value != "" {
+		contract, err := domain.ParseAddress(value)
+		if err != nil {
+			return query, fmt.Errorf("%s query param is not a valid ethereum address", contractParam)
+		}
+		query.Contract = contract.String()
+	}
+
+	return query, nil
+}
@@ -0,0 +1,75 @@
+package repositories_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/aniladanir/ethereum-blockchain-parser/internal/adapters/repositories"
+	"github.com/aniladanir/ethereum-blockchain-parser/internal/adapters/repositories/repotest"
+)
+
+// postgresDsnEnv names the environment variable holding the dsn of a postgres database used for testing.
+// The postgres repository is only tested if it is set, and the database is emptied before every test.
+const postgresDsnEnv = "TXPARSER_TEST_POSTGRES_DSN"
+
+func TestInMemRepositoryConformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repositories.Repository {
+		return repositories.NewInmemTransactionRepository()
+	})
+}
+
+func TestFileRepositoryConformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repositories.Repository {
+		repo, err := repositories.NewFileRepository(t.TempDir(), 10)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		t.Cleanup(func() { _ = repo.Close() })
+		return repo
+	})
+}
+
+func TestSqliteRepositoryConformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repositories.Repository {
+		dsn := "file:" + filepath.Join(t.TempDir(), "txparser.db") + "?_journal_mode=WAL&_busy_timeout=5000&_txlock=immediate"
+		repo, err := repositories.NewSqlRepository(context.Background(), "sqlite3", dsn)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		t.Cleanup(func() { _ = repo.Close() })
+		return repo
+	})
+}
+
+func TestPostgresRepositoryConformance(t *testing.T) {
+	dsn := os.Getenv(postgresDsnEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", postgresDsnEnv)
+	}
+
+	repotest.Run(t, func(t *testing.T) repositories.Repository {
+		repo, err := repositories.NewSqlRepository(context.Background(), "postgres", dsn)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		t.Cleanup(func() { _ = repo.Close() })
+
+		db, err := sql.Open("postgres", dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		for _, table := range []string{"addresses", "transactions", "token_transfers", "nft_transfers", "block_hashes", "parser_state"} {
+			if _, err := db.Exec("DELETE FROM " + table); err != nil {
+				t.Fatalf("could not empty table %s: %v", table, err)
+			}
+		}
+		return repo
+	})
+}
@@ -0,0 +1,429 @@
+package repositories
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/aniladanir/ethereum-blockchain-parser/internal/core/domain"
+	"github.com/aniladanir/ethereum-blockchain-parser/pkg/errs"
+)
+
+const (
+	walFileName            = "wal.log"
+	snapshotFileName       = "snapshot.json"
+	defaultSnapshotRecords = 1000
+	// every log record is prefixed with the length and the crc32 checksum of its payload
+	walHeaderSize = 8
+)
+
+var _ Repository = (*fileRepository)(nil)
+
+// fileRepository keeps its state in memory and persists every committed write to an append-only write-ahead log.
+// The log is compacted into a snapshot after a number of records, and both are replayed on startup.
+type fileRepository struct {
+	*inMemRepository
+	dir             string
+	snapshotRecords int
+
+	// walMtx serializes writes, so that operations are applied in the order they are logged
+	walMtx    sync.Mutex
+	wal       *os.File
+	walOffset int64
+	// sequence number of the last logged record
+	seq uint64
+	// number of records logged since the last snapshot
+	records int
+}
+
+// walRecord is a committed transaction in the log
+type walRecord struct {
+	Seq uint64      `json:"seq"`
+	Ops []operation `json:"ops"`
+}
+
+// snapshot is the state of the repository including all records up to its sequence number
+type snapshot struct {
+	Seq          uint64                          `json:"seq"`
+	BlockNumber  int                             `json:"blockNumber"`
+	BlockHashes  map[int]string                  `json:"blockHashes"`
+	Transactions map[string][]domain.Transaction `json:"transactions"`
+	// TokenTransfers are the token transfers of the addresses in Transactions
+	TokenTransfers map[string][]domain.TokenTransfer `json:"tokenTransfers,omitempty"`
+	// NFTTransfers are the nft transfers of the addresses in Transactions
+	NFTTransfers map[string][]domain.NFTTransfer `json:"nftTransfers,omitempty"`
+	// Unsubscribed are the addresses in Transactions that are not subscribed anymore
+	Unsubscribed []string `json:"unsubscribed,omitempty"`
+	// Labels are the labels of the subscribed addresses
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// NewFileRepository opens the repository stored in the given directory, creating it if it does not exist.
+// A snapshot is written after every 'snapshotRecords' committed transactions. A torn record at the end of the log,
+// e.g. after a crash during a write, is discarded. Opening fails if a complete record of the log is corrupted.
+func NewFileRepository(dir string, snapshotRecords int) (*fileRepository, error) {
+	if snapshotRecords <= 0 {
+		snapshotRecords = defaultSnapshotRecords
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("could not create data directory: %w", err)
+	}
+
+	fr := &fileRepository{
+		inMemRepository: NewInmemTransactionRepository().(*inMemRepository),
+		dir:             dir,
+		snapshotRecords: snapshotRecords,
+	}
+	if err := fr.loadSnapshot(); err != nil {
+		return nil, err
+	}
+
+	wal, err := os.OpenFile(filepath.Join(dir, walFileName), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("could not open write-ahead log: %w", err)
+	}
+	fr.wal = wal
+	if err := fr.replayLog(); err != nil {
+		_ = wal.Close()
+		return nil, err
+	}
+
+	return fr, nil
+}
+
+// Close writes a snapshot and closes the log. The repository must not be used afterwards.
+func (fr *fileRepository) Close() error {
+	fr.walMtx.Lock()
+	defer fr.walMtx.Unlock()
+
+	var snapshotErr error
+	if fr.records > 0 {
+		snapshotErr = fr.writeSnapshot()
+	}
+	return errors.Join(snapshotErr, fr.wal.Close())
+}
+
+func (fr *fileRepository) NewTransaction(ctx context.Context) (Transaction, error) {
+	return newInMemTransaction(fr.inMemRepository, fr.commit), nil
+}
+
+func (fr *fileRepository) SetBlockNumber(ctx context.Context, blockNumber int) error {
+	return fr.commit([]operation{{Kind: opSetBlockNumber, BlockNumber: blockNumber}})
+}
+
+func (fr *fileRepository) SetBlockHash(ctx context.Context, blockNumber int, hash string) error {
+	return fr.commit([]operation{{Kind: opSetBlockHash, BlockNumber: blockNumber, Hash: hash}})
+}
+
+func (fr *fileRepository) RemoveBlocks(ctx context.Context, fromBlock int) error {
+	return fr.commit([]operation{{Kind: opRemoveBlocks, BlockNumber: fromBlock}})
+}
+
+func (fr *fileRepository) PruneBlockHashes(ctx context.Context, beforeBlock int) error {
+	return fr.commit([]operation{{Kind: opPruneBlockHashes, BlockNumber: beforeBlock}})
+}
+
+func (fr *fileRepository) AddTransaction(ctx context.Context, address string, transaction domain.Transaction) error {
+	fr.walMtx.Lock()
+	defer fr.walMtx.Unlock()
+
+	if !fr.hasAddress(address) {
+		return errs.NotFoundErr()
+	}
+	return fr.commitLocked([]operation{{Kind: opAddTransaction, Address: address, Transaction: &transaction}})
+}
+
+func (fr *fileRepository) AddTokenTransfer(ctx context.Context, address string, transfer domain.TokenTransfer) error {
+	fr.walMtx.Lock()
+	defer fr.walMtx.Unlock()
+
+	if !fr.hasAddress(address) {
+		return errs.NotFoundErr()
+	}
+	return fr.commitLocked([]operation{{Kind: opAddTokenTransfer, Address: address, TokenTransfer: &transfer}})
+}
+
+func (fr *fileRepository) AddNFTTransfer(ctx context.Context, address string, transfer domain.NFTTransfer) error {
+	fr.walMtx.Lock()
+	defer fr.walMtx.Unlock()
+
+	if !fr.hasAddress(address) {
+		return errs.NotFoundErr()
+	}
+	return fr.commitLocked([]operation{{Kind: opAddNFTTransfer, Address: address, NFTTransfer: &transfer}})
+}
+
+func (fr *fileRepository) AddAddress(ctx context.Context, address string) error {
+	fr.walMtx.Lock()
+	defer fr.walMtx.Unlock()
+
+	if fr.hasAddress(address) {
+		return errs.AlreadyExistErr()
+	}
+	return fr.commitLocked([]operation{{Kind: opAddAddress, Address: address}})
+}
+
+func (fr *fileRepository) RemoveAddress(ctx context.Context, address string, purge bool) error {
+	fr.walMtx.Lock()
+	defer fr.walMtx.Unlock()
+
+	if !fr.knowsAddress(address) || (!purge && !fr.hasAddress(address)) {
+		return errs.NotFoundErr()
+	}
+	return fr.commitLocked([]operation{{Kind: opRemoveAddress, Address: address, Purge: purge}})
+}
+
+func (fr *fileRepository) SetAddressLabel(ctx context.Context, address string, label string) error {
+	fr.walMtx.Lock()
+	defer fr.walMtx.Unlock()
+
+	if !fr.hasAddress(address) {
+		return errs.NotFoundErr()
+	}
+	return fr.commitLocked([]operation{{Kind: opSetAddressLabel, Address: address, Label: label}})
+}
+
+// commit logs the operations as a single record, syncs the log to disk and applies the operations
+func (fr *fileRepository) commit(ops []operation) error {
+	fr.walMtx.Lock()
+	defer fr.walMtx.Unlock()
+
+	return fr.commitLocked(ops)
+}
+
+func (fr *fileRepository) commitLocked(ops []operation) error {
+	payload, err := json.Marshal(walRecord{Seq: fr.seq + 1, Ops: ops})
+	if err != nil {
+		return fmt.Errorf("could not encode log record: %w", err)
+	}
+	record := make([]byte, walHeaderSize+len(payload))
+	binary.LittleEndian.PutUint32(record[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(payload))
+	copy(record[walHeaderSize:], payload)
+
+	if _, err := fr.wal.Write(record); err != nil {
+		fr.discardTail()
+		return fmt.Errorf("could not write to write-ahead log: %w", err)
+	}
+	if err := fr.wal.Sync(); err != nil {
+		fr.discardTail()
+		return fmt.Errorf("could not sync write-ahead log: %w", err)
+	}
+	fr.walOffset += int64(len(record))
+	fr.seq++
+	fr.records++
+
+	_ = fr.apply(ops)
+
+	if fr.records >= fr.snapshotRecords {
+		// the records are durable in the log, so a failed snapshot is retried after the next commit
+		_ = fr.writeSnapshot()
+	}
+	return nil
+}
+
+// discardTail removes a partially written record from the end of the log
+func (fr *fileRepository) discardTail() {
+	if err := fr.wal.Truncate(fr.walOffset); err == nil {
+		_, _ = fr.wal.Seek(fr.walOffset, io.SeekStart)
+	}
+}
+
+// replayLog applies the records of the log that are newer than the snapshot. Only a record cut short by the end
+// of the log is dropped, a corrupted record fails the replay and leaves the log untouched.
+func (fr *fileRepository) replayLog() error {
+	info, err := fr.wal.Stat()
+	if err != nil {
+		return fmt.Errorf("could not stat write-ahead log: %w", err)
+	}
+
+	reader := bufio.NewReader(fr.wal)
+	header := make([]byte, walHeaderSize)
+	var offset int64
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				break
+			}
+			return fmt.Errorf("could not read write-ahead log: %w", err)
+		}
+		// a length beyond the end of the log belongs to a torn record
+		length := int64(binary.LittleEndian.Uint32(header[0:4]))
+		if offset+walHeaderSize+length > info.Size() {
+			break
+		}
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(reader, payload); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				break
+			}
+			return fmt.Errorf("could not read write-ahead log: %w", err)
+		}
+		if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(header[4:8]) {
+			return fmt.Errorf("corrupted write-ahead log record at offset %d: checksum mismatch", offset)
+		}
+		var record walRecord
+		if err := json.Unmarshal(payload, &record); err != nil {
+			return fmt.Errorf("corrupted write-ahead log record at offset %d: %w", offset, err)
+		}
+
+		offset += int64(walHeaderSize + len(payload))
+		// records may already be included in the snapshot if the process stopped before the log was truncated
+		if record.Seq <= fr.seq {
+			continue
+		}
+		_ = fr.apply(record.Ops)
+		fr.seq = record.Seq
+		fr.records++
+	}
+
+	// drop the torn record at the end of the log, if any
+	if offset < info.Size() {
+		if err := fr.wal.Truncate(offset); err != nil {
+			return fmt.Errorf("could not truncate write-ahead log: %w", err)
+		}
+	}
+	if _, err := fr.wal.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("could not seek write-ahead log: %w", err)
+	}
+	fr.walOffset = offset
+	return nil
+}
+
+func (fr *fileRepository) loadSnapshot() error {
+	content, err := os.ReadFile(filepath.Join(fr.dir, snapshotFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not read snapshot: %w", err)
+	}
+
+	var snap snapshot
+	if err := json.Unmarshal(content, &snap); err != nil {
+		return fmt.Errorf("could not decode snapshot: %w", err)
+	}
+
+	for address, transactions := range snap.Transactions {
+		fr.addresses.Store(address, new(sync.RWMutex))
+		fr.transactions[address], fr.transactionKeys[address] = uniqueRecords(transactions, transactionKey)
+	}
+	for address, transfers := range snap.TokenTransfers {
+		fr.tokenTransfers[address], fr.tokenTransferKeys[address] = uniqueRecords(transfers, tokenTransferKey)
+	}
+	for address, transfers := range snap.NFTTransfers {
+		fr.nftTransfers[address], fr.nftTransferKeys[address] = uniqueRecords(transfers, nftTransferKey)
+	}
+	for _, address := range snap.Unsubscribed {
+		fr.unsubscribed.Store(address, struct{}{})
+	}
+	for address, label := range snap.Labels {
+		fr.labels.Store(address, label)
+	}
+	for blockNumber, hash := range snap.BlockHashes {
+		fr.blockHashes[blockNumber] = hash
+	}
+	fr.blockNumber.Store(int64(snap.BlockNumber))
+	fr.seq = snap.Seq
+	return nil
+}
+
+// writeSnapshot replaces the snapshot with the current state and truncates the log. The caller must hold walMtx.
+func (fr *fileRepository) writeSnapshot() error {
+	snap := snapshot{
+		Seq:            fr.seq,
+		BlockHashes:    make(map[int]string),
+		Transactions:   make(map[string][]domain.Transaction),
+		TokenTransfers: make(map[string][]domain.TokenTransfer),
+		NFTTransfers:   make(map[string][]domain.NFTTransfer),
+	}
+	fr.commitMtx.RLock()
+	snap.BlockNumber = fr.getBlockNumber()
+	fr.blockHashesMtx.RLock()
+	for blockNumber, hash := range fr.blockHashes {
+		snap.BlockHashes[blockNumber] = hash
+	}
+	fr.blockHashesMtx.RUnlock()
+	fr.addresses.Range(func(address, _ any) bool {
+		transactions, _ := fr.getTransactions(address.(string))
+		snap.Transactions[address.(string)] = transactions
+		if transfers, _ := fr.getTokenTransfers(address.(string)); len(transfers) > 0 {
+			snap.TokenTransfers[address.(string)] = transfers
+		}
+		if transfers, _ := fr.getNFTTransfers(address.(string)); len(transfers) > 0 {
+			snap.NFTTransfers[address.(string)] = transfers
+		}
+		return true
+	})
+	fr.unsubscribed.Range(func(address, _ any) bool {
+		snap.Unsubscribed = append(snap.Unsubscribed, address.(string))
+		return true
+	})
+	snap.Labels = make(map[string]string)
+	fr.labels.Range(func(address, label any) bool {
+		snap.Labels[address.(string)] = label.(string)
+		return true
+	})
+	fr.commitMtx.RUnlock()
+
+	content, err := json.Marshal(snap)
+	if err != nil {
+		return fmt.Errorf("could not encode snapshot: %w", err)
+	}
+
+	// write to a temporary file first, so that a crash never leaves a partial snapshot behind
+	tmpPath := filepath.Join(fr.dir, snapshotFileName+".tmp")
+	if err := writeFileSync(tmpPath, content); err != nil {
+		return fmt.Errorf("could not write snapshot: %w", err)
+	}
+	if err := os.Rename(tmpPath, filepath.Join(fr.dir, snapshotFileName)); err != nil {
+		return fmt.Errorf("could not replace snapshot: %w", err)
+	}
+	if err := syncDir(fr.dir); err != nil {
+		return fmt.Errorf("could not sync data directory: %w", err)
+	}
+
+	// the records are included in the snapshot now
+	if err := fr.wal.Truncate(0); err != nil {
+		return fmt.Errorf("could not truncate write-ahead log: %w", err)
+	}
+	if _, err := fr.wal.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("could not seek write-ahead log: %w", err)
+	}
+	fr.walOffset = 0
+	fr.records = 0
+	return nil
+}
+
+func writeFileSync(path string, content []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(content); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,274 @@
+package repositories
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/aniladanir/ethereum-blockchain-parser/internal/core/domain"
+	"github.com/aniladanir/ethereum-blockchain-parser/pkg/errs"
+)
+
+func openFileRepository(t *testing.T, dir string, snapshotRecords int) *fileRepository {
+	t.Helper()
+	repo, err := NewFileRepository(dir, snapshotRecords)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	return repo
+}
+
+// writeBlocks commits a transaction for each block, adding a transaction and a token transfer of the address
+func writeBlocks(t *testing.T, repo Repository, address string, from, to int) {
+	t.Helper()
+	ctx := context.Background()
+	for block := from; block <= to; block++ {
+		repoTx, _ := repo.NewTransaction(ctx)
+		_ = repoTx.AddTransaction(ctx, address, domain.Transaction{Hash: fmt.Sprintf("hash%d", block), BlockNumber: uint64(block)})
+		_ = repoTx.AddTokenTransfer(ctx, address, domain.TokenTransfer{Value: big.NewInt(1), BlockNumber: uint64(block), TransactionHash: fmt.Sprintf("hash%d", block)})
+		_ = repoTx.AddNFTTransfer(ctx, address, domain.NFTTransfer{
+			Standard: domain.NFTStandardERC721, TokenID: big.NewInt(int64(block)), Amount: big.NewInt(1), BlockNumber: uint64(block),
+			TransactionHash: fmt.Sprintf("hash%d", block),
+		})
+		_ = repoTx.SetBlockHash(ctx, block, "block")
+		_ = repoTx.SetBlockNumber(ctx, block)
+		if err := repoTx.Commit(ctx); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+}
+
+func TestFileRepositoryRecovery(t *testing.T) {
+	tests := []struct {
+		name            string
+		snapshotRecords int
+		close           bool
+	}{
+		{
+			name:            "FromLog",
+			snapshotRecords: 100,
+		},
+		{
+			name:            "FromSnapshotAndLog",
+			snapshotRecords: 3,
+		},
+		{
+			name:            "AfterClose",
+			snapshotRecords: 100,
+			close:           true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			dir := t.TempDir()
+
+			repo := openFileRepository(t, dir, tt.snapshotRecords)
+			if err := repo.AddAddress(ctx, "0x123"); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			writeBlocks(t, repo, "0x123", 1, 10)
+
+			// rolled back writes are not persisted
+			repoTx, _ := repo.NewTransaction(ctx)
+			_ = repoTx.AddTransaction(ctx, "0x123", domain.Transaction{Hash: "rolled back"})
+			_ = repoTx.SetBlockNumber(ctx, 11)
+			_ = repoTx.Rollback(ctx)
+
+			if tt.close {
+				if err := repo.Close(); err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+			}
+
+			recovered := openFileRepository(t, dir, tt.snapshotRecords)
+			defer recovered.Close()
+
+			if blockNumber, _ := recovered.GetBlockNumber(ctx); blockNumber != 10 {
+				t.Errorf("expected block number 10, got %d", blockNumber)
+			}
+			if transactions, _ := recovered.GetTransactions(ctx, "0x123"); len(transactions) != 10 {
+				t.Errorf("expected 10 transactions, got %d", len(transactions))
+			}
+			if page, _ := recovered.QueryTokenTransfers(ctx, domain.TokenTransferQuery{Address: "0x123"}); len(page.TokenTransfers) != 10 {
+				t.Errorf("expected 10 token transfers, got %d", len(page.TokenTransfers))
+			}
+			if page, _ := recovered.QueryNFTTransfers(ctx, domain.NFTTransferQuery{Address: "0x123"}); len(page.NFTTransfers) != 10 {
+				t.Errorf("expected 10 nft transfers, got %d", len(page.NFTTransfers))
+			}
+			if hash, _ := recovered.GetBlockHash(ctx, 10); hash != "block" {
+				t.Errorf("expected block hash of block 10, got %q", hash)
+			}
+			if err := recovered.AddAddress(ctx, "0x123"); !errs.IsAlreadyExistErr(err) {
+				t.Errorf("expected already exist error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestFileRepositoryTornRecord(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	repo := openFileRepository(t, dir, 100)
+	_ = repo.AddAddress(ctx, "0x123")
+	writeBlocks(t, repo, "0x123", 1, 2)
+
+	// simulate a crash in the middle of writing a record
+	walPath := filepath.Join(dir, walFileName)
+	info, _ := os.Stat(walPath)
+	if err := os.Truncate(walPath, info.Size()-5); err != nil {
+		t.Fatal(err)
+	}
+
+	recovered := openFileRepository(t, dir, 100)
+	if blockNumber, _ := recovered.GetBlockNumber(ctx); blockNumber != 1 {
+		t.Errorf("expected torn record of block 2 to be discarded, got block number %d", blockNumber)
+	}
+
+	// new records are appended after the last valid record
+	writeBlocks(t, recovered, "0x123", 2, 3)
+	recovered = openFileRepository(t, dir, 100)
+	if blockNumber, _ := recovered.GetBlockNumber(ctx); blockNumber != 3 {
+		t.Errorf("expected block number 3, got %d", blockNumber)
+	}
+	if transactions, _ := recovered.GetTransactions(ctx, "0x123"); len(transactions) != 3 {
+		t.Errorf("expected 3 transactions, got %d", len(transactions))
+	}
+}
+
+func TestFileRepositoryCorruptedRecord(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt func(wal []byte)
+	}{
+		{
+			name: "Checksum",
+			corrupt: func(wal []byte) {
+				wal[walHeaderSize+1] ^= 0xff
+			},
+		},
+		{
+			name: "Payload",
+			corrupt: func(wal []byte) {
+				// replace the payload of the first record with invalid json and update its checksum
+				length := binary.LittleEndian.Uint32(wal[0:4])
+				payload := wal[walHeaderSize : walHeaderSize+length]
+				payload[0] = '['
+				binary.LittleEndian.PutUint32(wal[4:8], crc32.ChecksumIEEE(payload))
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			dir := t.TempDir()
+
+			repo := openFileRepository(t, dir, 100)
+			_ = repo.AddAddress(ctx, "0x123")
+			writeBlocks(t, repo, "0x123", 1, 3)
+
+			// corrupt the first record, which is followed by the records of the blocks
+			walPath := filepath.Join(dir, walFileName)
+			wal, _ := os.ReadFile(walPath)
+			tt.corrupt(wal)
+			if err := os.WriteFile(walPath, wal, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := NewFileRepository(dir, 100); err == nil {
+				t.Fatal("expected error opening repository with corrupted log, got nil")
+			}
+			if content, _ := os.ReadFile(walPath); !bytes.Equal(content, wal) {
+				t.Errorf("expected log to be left untouched, got %d bytes instead of %d", len(content), len(wal))
+			}
+		})
+	}
+}
+
+func TestFileRepositoryStaleLog(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	repo := openFileRepository(t, dir, 100)
+	_ = repo.AddAddress(ctx, "0x123")
+	writeBlocks(t, repo, "0x123", 1, 5)
+	walPath := filepath.Join(dir, walFileName)
+	staleLog, _ := os.ReadFile(walPath)
+
+	// simulate a crash after the snapshot was written but before the log was truncated
+	if err := repo.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(walPath, staleLog, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	recovered := openFileRepository(t, dir, 100)
+	if transactions, _ := recovered.GetTransactions(ctx, "0x123"); len(transactions) != 5 {
+		t.Errorf("expected records included in the snapshot to be skipped, got %d transactions", len(transactions))
+	}
+}
+
+func TestFileRepositoryRecoverSubscriptions(t *testing.T) {
+	tests := []struct {
+		name  string
+		close bool
+	}{
+		{
+			name: "FromLog",
+		},
+		{
+			name:  "FromSnapshot",
+			close: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			dir := t.TempDir()
+
+			repo := openFileRepository(t, dir, 100)
+			for _, address := range []string{"0xa", "0xb"} {
+				_ = repo.AddAddress(ctx, address)
+				writeBlocks(t, repo, address, 1, 2)
+			}
+			if err := repo.RemoveAddress(ctx, "0xa", false); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if err := repo.RemoveAddress(ctx, "0xb", true); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			_ = repo.AddAddress(ctx, "0xc")
+			if err := repo.SetAddressLabel(ctx, "0xc", "label"); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if tt.close {
+				if err := repo.Close(); err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+			}
+
+			recovered := openFileRepository(t, dir, 100)
+			defer recovered.Close()
+
+			subscriptions, _ := recovered.GetSubscriptions(ctx)
+			if expected := []domain.Subscription{{Address: "0xc", Label: "label"}}; !reflect.DeepEqual(subscriptions, expected) {
+				t.Errorf("expected subscriptions %v, got %v", expected, subscriptions)
+			}
+			if transactions, _ := recovered.GetTransactions(ctx, "0xa"); len(transactions) != 2 {
+				t.Errorf("expected 2 kept transactions, got %d", len(transactions))
+			}
+			if _, err := recovered.GetTransactions(ctx, "0xb"); !errs.IsNotFoundErr(err) {
+				t.Errorf("expected not found error for purged address, got %v", err)
+			}
+		})
+	}
+}
@@ -2,6 +2,9 @@ package repositories
 
 import (
 	"context"
+	"fmt"
+	"slices"
+	"sort"
 	"sync"
 	"sync/atomic"
 
@@ -9,55 +12,283 @@ import (
 	"github.com/aniladanir/ethereum-blockchain-parser/pkg/errs"
 )
 
-var (
-	_ Repository  = (*inMemRepository)(nil)
-	_ Transaction = (*inMemTransaction)(nil)
-)
+var _ Repository = (*inMemRepository)(nil)
 
 type inMemRepository struct {
-	addresses    *sync.Map
+	// commitMtx is held exclusively while a transaction is applied, so that readers never observe a partial commit
+	commitMtx sync.RWMutex
+	// addresses holds the subscribed addresses and those with kept transactions, mapped to the mutex of their
+	// transactions, token transfers and nft transfers
+	addresses *sync.Map
+	// unsubscribed holds the addresses that are not observed anymore, but whose transactions are kept
+	unsubscribed sync.Map
+	// labels holds the labels of the subscribed addresses
+	labels       sync.Map
 	transactions map[string][]domain.Transaction
-	blockNumber  *atomic.Int64
-}
-
-type inMemTransaction struct {
-	*inMemRepository
+	// the keys index the records of every address, so that they are stored once
+	transactionKeys   map[string]recordIndex
+	tokenTransfers    map[string][]domain.TokenTransfer
+	tokenTransferKeys map[string]recordIndex
+	nftTransfers      map[string][]domain.NFTTransfer
+	nftTransferKeys   map[string]recordIndex
+	blockNumber       *atomic.Int64
+	blockHashesMtx    sync.RWMutex
+	blockHashes       map[int]string
 }
 
 func NewInmemTransactionRepository() Repository {
-	a := &inMemRepository{
-		blockNumber: &atomic.Int64{},
-		addresses:   new(sync.Map),
-		transactions: map[string][]domain.Transaction{
-			"0x123": {{
-				Hash:        "00000",
-				From:        "0x123",
-				To:          "0x456",
-				Value:       "hello world!",
-				BlockNumber: "20000000",
-			}},
-		},
-	}
-	a.addresses.Store("0x123", new(sync.RWMutex))
-	return a
+	return &inMemRepository{
+		blockNumber:       &atomic.Int64{},
+		addresses:         new(sync.Map),
+		blockHashes:       make(map[int]string),
+		transactions:      make(map[string][]domain.Transaction),
+		transactionKeys:   make(map[string]recordIndex),
+		tokenTransfers:    make(map[string][]domain.TokenTransfer),
+		tokenTransferKeys: make(map[string]recordIndex),
+		nftTransfers:      make(map[string][]domain.NFTTransfer),
+		nftTransferKeys:   make(map[string]recordIndex),
+	}
 }
 
 func (tr *inMemRepository) NewTransaction(ctx context.Context) (Transaction, error) {
-	return &inMemTransaction{
-		inMemRepository: tr,
-	}, nil
+	return newInMemTransaction(tr, tr.apply), nil
+}
+
+// apply applies the operations at once, so that readers observe either none or all of them
+func (tr *inMemRepository) apply(ops []operation) error {
+	tr.commitMtx.Lock()
+	defer tr.commitMtx.Unlock()
+
+	for i := range ops {
+		ops[i].apply(tr)
+	}
+	return nil
 }
 
 func (tr *inMemRepository) GetBlockNumber(ctx context.Context) (int, error) {
-	return int(tr.blockNumber.Load()), nil
+	tr.commitMtx.RLock()
+	defer tr.commitMtx.RUnlock()
+
+	return tr.getBlockNumber(), nil
 }
 
 func (tr *inMemRepository) SetBlockNumber(ctx context.Context, blockNumber int) error {
-	tr.blockNumber.Store(int64(blockNumber))
+	tr.commitMtx.RLock()
+	defer tr.commitMtx.RUnlock()
+
+	tr.setBlockNumber(blockNumber)
+	return nil
+}
+
+func (tr *inMemRepository) SetBlockHash(ctx context.Context, blockNumber int, hash string) error {
+	tr.commitMtx.RLock()
+	defer tr.commitMtx.RUnlock()
+
+	tr.setBlockHash(blockNumber, hash)
+	return nil
+}
+
+func (tr *inMemRepository) GetBlockHash(ctx context.Context, blockNumber int) (string, error) {
+	tr.commitMtx.RLock()
+	defer tr.commitMtx.RUnlock()
+
+	return tr.getBlockHash(blockNumber)
+}
+
+func (tr *inMemRepository) RemoveBlocks(ctx context.Context, fromBlock int) error {
+	tr.commitMtx.RLock()
+	defer tr.commitMtx.RUnlock()
+
+	tr.removeBlocks(fromBlock)
+	return nil
+}
+
+func (tr *inMemRepository) PruneBlockHashes(ctx context.Context, beforeBlock int) error {
+	tr.commitMtx.RLock()
+	defer tr.commitMtx.RUnlock()
+
+	tr.pruneBlockHashes(beforeBlock)
 	return nil
 }
 
 func (tr *inMemRepository) GetTransactions(ctx context.Context, address string) ([]domain.Transaction, error) {
+	tr.commitMtx.RLock()
+	defer tr.commitMtx.RUnlock()
+
+	return tr.getTransactions(address)
+}
+
+func (tr *inMemRepository) QueryTransactions(ctx context.Context, query domain.TransactionQuery) (domain.TransactionPage, error) {
+	transactions, err := tr.GetTransactions(ctx, query.Address)
+	if err != nil {
+		return domain.TransactionPage{}, err
+	}
+	return queryTransactions(transactions, query)
+}
+
+func (tr *inMemRepository) AddTransaction(ctx context.Context, address string, transaction domain.Transaction) error {
+	tr.commitMtx.RLock()
+	defer tr.commitMtx.RUnlock()
+
+	return tr.addTransaction(address, transaction)
+}
+
+func (tr *inMemRepository) AddTokenTransfer(ctx context.Context, address string, transfer domain.TokenTransfer) error {
+	tr.commitMtx.RLock()
+	defer tr.commitMtx.RUnlock()
+
+	return tr.addTokenTransfer(address, transfer)
+}
+
+func (tr *inMemRepository) QueryTokenTransfers(ctx context.Context, query domain.TokenTransferQuery) (domain.TokenTransferPage, error) {
+	tr.commitMtx.RLock()
+	transfers, err := tr.getTokenTransfers(query.Address)
+	tr.commitMtx.RUnlock()
+	if err != nil {
+		return domain.TokenTransferPage{}, err
+	}
+	return queryTokenTransfers(transfers, query)
+}
+
+func (tr *inMemRepository) AddNFTTransfer(ctx context.Context, address string, transfer domain.NFTTransfer) error {
+	tr.commitMtx.RLock()
+	defer tr.commitMtx.RUnlock()
+
+	return tr.addNFTTransfer(address, transfer)
+}
+
+func (tr *inMemRepository) QueryNFTTransfers(ctx context.Context, query domain.NFTTransferQuery) (domain.NFTTransferPage, error) {
+	tr.commitMtx.RLock()
+	transfers, err := tr.getNFTTransfers(query.Address)
+	tr.commitMtx.RUnlock()
+	if err != nil {
+		return domain.NFTTransferPage{}, err
+	}
+	return queryNFTTransfers(transfers, query)
+}
+
+func (tr *inMemRepository) AddAddress(ctx context.Context, address string) error {
+	tr.commitMtx.RLock()
+	defer tr.commitMtx.RUnlock()
+
+	return tr.addAddress(address)
+}
+
+func (tr *inMemRepository) RemoveAddress(ctx context.Context, address string, purge bool) error {
+	// transactions are removed from the map, which requires exclusive access
+	tr.commitMtx.Lock()
+	defer tr.commitMtx.Unlock()
+
+	return tr.removeAddress(address, purge)
+}
+
+func (tr *inMemRepository) GetAddresses(ctx context.Context) ([]string, error) {
+	tr.commitMtx.RLock()
+	defer tr.commitMtx.RUnlock()
+
+	return tr.getAddresses(), nil
+}
+
+func (tr *inMemRepository) SetAddressLabel(ctx context.Context, address string, label string) error {
+	tr.commitMtx.RLock()
+	defer tr.commitMtx.RUnlock()
+
+	return tr.setAddressLabel(address, label)
+}
+
+func (tr *inMemRepository) GetSubscriptions(ctx context.Context) ([]domain.Subscription, error) {
+	tr.commitMtx.RLock()
+	defer tr.commitMtx.RUnlock()
+
+	return tr.getSubscriptions(), nil
+}
+
+// The methods below access the state without holding commitMtx, so that they can be used to apply transactions.
+
+func (tr *inMemRepository) getBlockNumber() int {
+	return int(tr.blockNumber.Load())
+}
+
+func (tr *inMemRepository) setBlockNumber(blockNumber int) {
+	tr.blockNumber.Store(int64(blockNumber))
+}
+
+func (tr *inMemRepository) setBlockHash(blockNumber int, hash string) {
+	tr.blockHashesMtx.Lock()
+	defer tr.blockHashesMtx.Unlock()
+
+	if tr.blockHashes == nil {
+		tr.blockHashes = make(map[int]string)
+	}
+	tr.blockHashes[blockNumber] = hash
+}
+
+func (tr *inMemRepository) getBlockHash(blockNumber int) (string, error) {
+	tr.blockHashesMtx.RLock()
+	defer tr.blockHashesMtx.RUnlock()
+
+	hash, ok := tr.blockHashes[blockNumber]
+	if !ok {
+		return "", errs.NotFoundErr()
+	}
+	return hash, nil
+}
+
+func (tr *inMemRepository) removeBlocks(fromBlock int) {
+	// remove block hashes
+	tr.blockHashesMtx.Lock()
+	for blockNumber := range tr.blockHashes {
+		if blockNumber >= fromBlock {
+			delete(tr.blockHashes, blockNumber)
+		}
+	}
+	tr.blockHashesMtx.Unlock()
+
+	// remove transactions and transfers of every address
+	tr.addresses.Range(func(address, transactionsMtxAny any) bool {
+		transactionsMtx := transactionsMtxAny.(*sync.RWMutex)
+		transactionsMtx.Lock()
+		defer transactionsMtx.Unlock()
+
+		transactions := tr.transactions[address.(string)]
+		kept := transactions[:0]
+		for i := range transactions {
+			if int(transactions[i].BlockNumber) < fromBlock {
+				kept = append(kept, transactions[i])
+			}
+		}
+		if len(kept) != len(transactions) {
+			tr.transactions[address.(string)] = kept
+			tr.transactionKeys[address.(string)].removeFrom(fromBlock)
+		}
+
+		transfers := tr.tokenTransfers[address.(string)]
+		if keptTransfers := keepTokenTransfersBefore(transfers, fromBlock); len(keptTransfers) != len(transfers) {
+			tr.tokenTransfers[address.(string)] = keptTransfers
+			tr.tokenTransferKeys[address.(string)].removeFrom(fromBlock)
+		}
+
+		nftTransfers := tr.nftTransfers[address.(string)]
+		if keptNFTTransfers := keepNFTTransfersBefore(nftTransfers, fromBlock); len(keptNFTTransfers) != len(nftTransfers) {
+			tr.nftTransfers[address.(string)] = keptNFTTransfers
+			tr.nftTransferKeys[address.(string)].removeFrom(fromBlock)
+		}
+		return true
+	})
+}
+
+func (tr *inMemRepository) pruneBlockHashes(beforeBlock int) {
+	tr.blockHashesMtx.Lock()
+	defer tr.blockHashesMtx.Unlock()
+
+	for blockNumber := range tr.blockHashes {
+		if blockNumber < beforeBlock {
+			delete(tr.blockHashes, blockNumber)
+		}
+	}
+}
+
+func (tr *inMemRepository) getTransactions(address string) ([]domain.Transaction, error) {
 	// get transactions rw mutex
 	transactionsMtxAny, ok := tr.addresses.Load(address)
 	if !ok {
@@ -80,10 +311,10 @@ func (tr *inMemRepository) GetTransactions(ctx context.Context, address string)
 	return transactionCopy, nil
 }
 
-func (tr *inMemRepository) AddTransaction(ctx context.Context, address string, transaction domain.Transaction) error {
+func (tr *inMemRepository) addTransaction(address string, transaction domain.Transaction) error {
 	// get transactions mutex
 	transactionsMtxAny, ok := tr.addresses.Load(address)
-	if !ok {
+	if !ok || !tr.hasAddress(address) {
 		return errs.NotFoundErr()
 	}
 	transactionsMtx := transactionsMtxAny.(*sync.RWMutex)
@@ -92,35 +323,236 @@ func (tr *inMemRepository) AddTransaction(ctx context.Context, address string, t
 	transactionsMtx.Lock()
 	defer transactionsMtx.Unlock()
 
-	// write transaction
+	// write transaction, unless the address already has it
 	if _, ok = tr.transactions[address]; !ok {
 		tr.transactions[address] = make([]domain.Transaction, 0)
 	}
+	if !addRecordKey(tr.transactionKeys, address, transactionKey(&transaction)) {
+		return nil
+	}
 	tr.transactions[address] = append(tr.transactions[address], transaction)
 
 	return nil
 }
 
-func (tr *inMemRepository) AddAddress(ctx context.Context, address string) error {
+// hasRecord reports whether the index of the address contains the key of a record in a block before beforeBlock
+func (tr *inMemRepository) hasRecord(indexes map[string]recordIndex, address string, key recordKey, beforeBlock int) bool {
+	transactionsMtxAny, ok := tr.addresses.Load(address)
+	if !ok {
+		return false
+	}
+	transactionsMtx := transactionsMtxAny.(*sync.RWMutex)
+	transactionsMtx.RLock()
+	defer transactionsMtx.RUnlock()
+
+	return indexes[address].has(key, beforeBlock)
+}
+
+func (tr *inMemRepository) getTokenTransfers(address string) ([]domain.TokenTransfer, error) {
+	transactionsMtxAny, ok := tr.addresses.Load(address)
+	if !ok {
+		return nil, errs.NotFoundErr()
+	}
+	transactionsMtx := transactionsMtxAny.(*sync.RWMutex)
+	transactionsMtx.RLock()
+	defer transactionsMtx.RUnlock()
+
+	return slices.Clone(tr.tokenTransfers[address]), nil
+}
+
+func (tr *inMemRepository) addTokenTransfer(address string, transfer domain.TokenTransfer) error {
+	transactionsMtxAny, ok := tr.addresses.Load(address)
+	if !ok || !tr.hasAddress(address) {
+		return errs.NotFoundErr()
+	}
+	transactionsMtx := transactionsMtxAny.(*sync.RWMutex)
+	transactionsMtx.Lock()
+	defer transactionsMtx.Unlock()
+
+	if !addRecordKey(tr.tokenTransferKeys, address, tokenTransferKey(&transfer)) {
+		return nil
+	}
+	tr.tokenTransfers[address] = append(tr.tokenTransfers[address], transfer)
+	return nil
+}
+
+func (tr *inMemRepository) getNFTTransfers(address string) ([]domain.NFTTransfer, error) {
+	transactionsMtxAny, ok := tr.addresses.Load(address)
+	if !ok {
+		return nil, errs.NotFoundErr()
+	}
+	transactionsMtx := transactionsMtxAny.(*sync.RWMutex)
+	transactionsMtx.RLock()
+	defer transactionsMtx.RUnlock()
+
+	return slices.Clone(tr.nftTransfers[address]), nil
+}
+
+func (tr *inMemRepository) addNFTTransfer(address string, transfer domain.NFTTransfer) error {
+	transactionsMtxAny, ok := tr.addresses.Load(address)
+	if !ok || !tr.hasAddress(address) {
+		return errs.NotFoundErr()
+	}
+	transactionsMtx := transactionsMtxAny.(*sync.RWMutex)
+	transactionsMtx.Lock()
+	defer transactionsMtx.Unlock()
+
+	if !addRecordKey(tr.nftTransferKeys, address, nftTransferKey(&transfer)) {
+		return nil
+	}
+	tr.nftTransfers[address] = append(tr.nftTransfers[address], transfer)
+	return nil
+}
+
+func (tr *inMemRepository) addAddress(address string) error {
 	if _, ok := tr.addresses.LoadOrStore(address, new(sync.RWMutex)); ok {
+		// resubscribe an address whose transactions were kept
+		if _, unsubscribed := tr.unsubscribed.LoadAndDelete(address); unsubscribed {
+			return nil
+		}
 		return errs.AlreadyExistErr()
 	}
 	return nil
 }
 
-func (tr *inMemRepository) GetAddresses(ctx context.Context) ([]string, error) {
+// removeAddress unsubscribes the address, removing its transactions if purge is set. The kept transactions of an
+// address that is already unsubscribed can be purged as well. The caller must hold commitMtx exclusively.
+func (tr *inMemRepository) removeAddress(address string, purge bool) error {
+	if !tr.knowsAddress(address) || (!purge && !tr.hasAddress(address)) {
+		return errs.NotFoundErr()
+	}
+	tr.labels.Delete(address)
+	if purge {
+		tr.unsubscribed.Delete(address)
+		tr.addresses.Delete(address)
+		delete(tr.transactions, address)
+		delete(tr.transactionKeys, address)
+		delete(tr.tokenTransfers, address)
+		delete(tr.tokenTransferKeys, address)
+		delete(tr.nftTransfers, address)
+		delete(tr.nftTransferKeys, address)
+		return nil
+	}
+	tr.unsubscribed.Store(address, struct{}{})
+	return nil
+}
+
+// hasAddress reports whether the address is subscribed
+func (tr *inMemRepository) hasAddress(address string) bool {
+	if _, ok := tr.addresses.Load(address); !ok {
+		return false
+	}
+	_, unsubscribed := tr.unsubscribed.Load(address)
+	return !unsubscribed
+}
+
+func (tr *inMemRepository) setAddressLabel(address string, label string) error {
+	if !tr.hasAddress(address) {
+		return errs.NotFoundErr()
+	}
+	if label == "" {
+		tr.labels.Delete(address)
+	} else {
+		tr.labels.Store(address, label)
+	}
+	return nil
+}
+
+func (tr *inMemRepository) getSubscriptions() []domain.Subscription {
+	addresses := tr.getAddresses()
+	subscriptions := make([]domain.Subscription, len(addresses))
+	for i, address := range addresses {
+		subscriptions[i] = domain.Subscription{Address: domain.Address(address)}
+		if label, ok := tr.labels.Load(address); ok {
+			subscriptions[i].Label = label.(string)
+		}
+	}
+	return subscriptions
+}
+
+// knowsAddress reports whether the address is subscribed or its transactions are kept
+func (tr *inMemRepository) knowsAddress(address string) bool {
+	_, ok := tr.addresses.Load(address)
+	return ok
+}
+
+func (tr *inMemRepository) getAddresses() []string {
 	addresses := make([]string, 0)
 	tr.addresses.Range(func(address, _ any) bool {
-		addresses = append(addresses, address.(string))
+		if _, unsubscribed := tr.unsubscribed.Load(address); !unsubscribed {
+			addresses = append(addresses, address.(string))
+		}
 		return true
 	})
-	return addresses, nil
+	sort.Strings(addresses)
+	return addresses
 }
 
-func (tr *inMemTransaction) Commit(ctx context.Context) error {
-	return nil
+// recordKey identifies a record of an address, which is stored once
+type recordKey struct {
+	key         string
+	blockNumber uint64
 }
 
-func (tr *inMemTransaction) Rollback(ctx context.Context) error {
-	return nil
+// recordIndex maps the unique keys of the records of an address to their block numbers
+type recordIndex map[string]uint64
+
+// add adds the key of a record, returning false if the index already contains it
+func (index recordIndex) add(key recordKey) bool {
+	if _, ok := index[key.key]; ok {
+		return false
+	}
+	index[key.key] = key.blockNumber
+	return true
+}
+
+// has reports whether the index contains the key of a record in a block before beforeBlock
+func (index recordIndex) has(key recordKey, beforeBlock int) bool {
+	blockNumber, ok := index[key.key]
+	return ok && int(blockNumber) < beforeBlock
+}
+
+// removeFrom removes the keys of the records of the blocks from the given block number on
+func (index recordIndex) removeFrom(fromBlock int) {
+	for key, blockNumber := range index {
+		if int(blockNumber) >= fromBlock {
+			delete(index, key)
+		}
+	}
+}
+
+// addRecordKey adds the key of a record of the address to the indexes, returning false if the address already has it
+func addRecordKey(indexes map[string]recordIndex, address string, key recordKey) bool {
+	if _, ok := indexes[address]; !ok {
+		indexes[address] = make(recordIndex)
+	}
+	return indexes[address].add(key)
+}
+
+func transactionKey(transaction *domain.Transaction) recordKey {
+	return recordKey{key: transaction.Hash, blockNumber: transaction.BlockNumber}
+}
+
+func tokenTransferKey(transfer *domain.TokenTransfer) recordKey {
+	return recordKey{key: fmt.Sprintf("%s:%d", transfer.TransactionHash, transfer.LogIndex), blockNumber: transfer.BlockNumber}
+}
+
+func nftTransferKey(transfer *domain.NFTTransfer) recordKey {
+	return recordKey{
+		key:         fmt.Sprintf("%s:%d.%d", transfer.TransactionHash, transfer.LogIndex, transfer.BatchIndex),
+		blockNumber: transfer.BlockNumber,
+	}
+}
+
+// uniqueRecords returns the records of an address with their index. Duplicates of a record in snapshots written
+// before the records of an address were unique are removed, keeping the first one.
+func uniqueRecords[T any](records []T, key func(record *T) recordKey) ([]T, recordIndex) {
+	index := make(recordIndex, len(records))
+	unique := records[:0]
+	for i := range records {
+		if index.add(key(&records[i])) {
+			unique = append(unique, records[i])
+		}
+	}
+	return unique, index
 }
@@ -3,6 +3,8 @@ package repositories
 import (
 	"context"
 	"errors"
+	"math/big"
+	"reflect"
 	"sync"
 	"testing"
 
@@ -21,10 +23,10 @@ func TestGetTransactions(t *testing.T) {
 		{
 			name: "Success",
 			initialState: map[string][]domain.Transaction{
-				"0x123": {{Hash: "hash1", From: "from1", To: "to1", Value: "100", BlockNumber: "1"}},
+				"0x123": {{Hash: "hash1", From: "from1", To: "to1", Value: big.NewInt(100), BlockNumber: 1}},
 			},
 			address:        "0x123",
-			expectedResult: []domain.Transaction{{Hash: "hash1", From: "from1", To: "to1", Value: "100", BlockNumber: "1"}},
+			expectedResult: []domain.Transaction{{Hash: "hash1", From: "from1", To: "to1", Value: big.NewInt(100), BlockNumber: 1}},
 			expectedError:  nil,
 		},
 		{
@@ -49,7 +51,7 @@ func TestGetTransactions(t *testing.T) {
 			}
 
 			for i := range transactions {
-				if transactions[i] != tt.expectedResult[i] {
+				if !reflect.DeepEqual(transactions[i], tt.expectedResult[i]) {
 					t.Errorf("expected transaction %v, got %v", tt.expectedResult[i], transactions[i])
 				}
 			}
@@ -71,7 +73,7 @@ func TestAddTransaction(t *testing.T) {
 			name:             "Success",
 			initialState:     map[string][]domain.Transaction{},
 			address:          "0x123",
-			transaction:      domain.Transaction{Hash: "hash1", From: "from1", To: "to1", Value: "100", BlockNumber: "1"},
+			transaction:      domain.Transaction{Hash: "hash1", From: "from1", To: "to1", Value: big.NewInt(100), BlockNumber: 1},
 			expectedError:    nil,
 			initialAddresses: map[string]any{"0x123": new(sync.RWMutex)},
 		},
@@ -79,7 +81,7 @@ func TestAddTransaction(t *testing.T) {
 			name:             "AddressNotFound",
 			initialState:     map[string][]domain.Transaction{},
 			address:          "0x456",
-			transaction:      domain.Transaction{Hash: "hash1", From: "from1", To: "to1", Value: "100", BlockNumber: "1"},
+			transaction:      domain.Transaction{Hash: "hash1", From: "from1", To: "to1", Value: big.NewInt(100), BlockNumber: 1},
 			expectedError:    errs.NotFoundErr(),
 			initialAddresses: map[string]any{},
 		},
@@ -98,7 +100,7 @@ func TestAddTransaction(t *testing.T) {
 					t.Errorf("expected to add one transaction, and found %d", len(transactions))
 				}
 
-				if transactions[0] != tt.transaction {
+				if !reflect.DeepEqual(transactions[0], tt.transaction) {
 					t.Errorf("expected the transaction to be %v and found %v", tt.transaction, transactions[0])
 				}
 			}
@@ -185,8 +187,42 @@ func setupTest(initialTransactions map[string][]domain.Transaction, initialAddre
 	for key, value := range initialAddresses {
 		addresses.Store(key, value)
 	}
+	transactionKeys := make(map[string]recordIndex)
+	for address, transactions := range initialTransactions {
+		initialTransactions[address], transactionKeys[address] = uniqueRecords(transactions, transactionKey)
+	}
 	return &inMemRepository{
-		transactions: initialTransactions,
-		addresses:    addresses,
+		transactions:    initialTransactions,
+		transactionKeys: transactionKeys,
+		addresses:       addresses,
+	}
+}
+
+func TestRemoveBlocks(t *testing.T) {
+	ctx := context.Background()
+	repo := setupTest(map[string][]domain.Transaction{
+		"0x123": {
+			{Hash: "hash1", BlockNumber: 1},
+			{Hash: "hash2", BlockNumber: 2},
+			{Hash: "hash3", BlockNumber: 3},
+		},
+	}, map[string]any{"0x123": new(sync.RWMutex)})
+	for blockNumber := 1; blockNumber <= 3; blockNumber++ {
+		_ = repo.SetBlockHash(ctx, blockNumber, "hash")
+	}
+
+	if err := repo.RemoveBlocks(ctx, 2); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	transactions, _ := repo.GetTransactions(ctx, "0x123")
+	if len(transactions) != 1 || transactions[0].Hash != "hash1" {
+		t.Errorf("expected only transaction hash1 to be kept, got %v", transactions)
+	}
+	if _, err := repo.GetBlockHash(ctx, 1); err != nil {
+		t.Errorf("expected hash of block 1 to be kept, got %v", err)
+	}
+	if _, err := repo.GetBlockHash(ctx, 2); !errs.IsNotFoundErr(err) {
+		t.Errorf("expected hash of block 2 to be removed, got %v", err)
 	}
 }
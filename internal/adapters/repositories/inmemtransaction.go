@@ -0,0 +1,522 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"math"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/aniladanir/ethereum-blockchain-parser/internal/core/domain"
+	"github.com/aniladanir/ethereum-blockchain-parser/pkg/errs"
+)
+
+var _ Transaction = (*inMemTransaction)(nil)
+
+// inMemTransaction buffers writes until Commit, when they are applied to the repository at once.
+// Reads through the transaction observe the committed state of the repository together with the buffered writes.
+type inMemTransaction struct {
+	repo *inMemRepository
+	mtx  sync.Mutex
+	done bool
+
+	// ops are the buffered writes, passed to commit in order
+	ops    []operation
+	commit func(ops []operation) error
+
+	// buffered state read by the transaction itself
+	blockNumber  *int
+	blockHashes  map[int]string
+	removedFrom  int
+	prunedBefore int
+	// addresses holds the addresses added or removed by the transaction, mapped to whether they are subscribed
+	addresses map[string]bool
+	// purged holds the addresses whose committed transactions and token transfers are removed by the transaction
+	purged map[string]struct{}
+	// labels holds the labels set by the transaction
+	labels       map[string]string
+	transactions map[string][]domain.Transaction
+	// the keys index the records written by the transaction
+	transactionKeys   map[string]recordIndex
+	tokenTransfers    map[string][]domain.TokenTransfer
+	tokenTransferKeys map[string]recordIndex
+	nftTransfers      map[string][]domain.NFTTransfer
+	nftTransferKeys   map[string]recordIndex
+}
+
+// newInMemTransaction creates a transaction on top of the repository. The buffered operations are passed to the
+// commit function, which must apply them to the repository.
+func newInMemTransaction(repo *inMemRepository, commit func(ops []operation) error) *inMemTransaction {
+	return &inMemTransaction{
+		repo:              repo,
+		commit:            commit,
+		blockHashes:       make(map[int]string),
+		removedFrom:       math.MaxInt,
+		prunedBefore:      math.MinInt,
+		addresses:         make(map[string]bool),
+		purged:            make(map[string]struct{}),
+		labels:            make(map[string]string),
+		transactions:      make(map[string][]domain.Transaction),
+		transactionKeys:   make(map[string]recordIndex),
+		tokenTransfers:    make(map[string][]domain.TokenTransfer),
+		tokenTransferKeys: make(map[string]recordIndex),
+		nftTransfers:      make(map[string][]domain.NFTTransfer),
+		nftTransferKeys:   make(map[string]recordIndex),
+	}
+}
+
+// lock locks the transaction, failing if it is already completed
+func (tx *inMemTransaction) lock() error {
+	tx.mtx.Lock()
+	if tx.done {
+		tx.mtx.Unlock()
+		return errs.TransactionDoneErr()
+	}
+	return nil
+}
+
+func (tx *inMemTransaction) NewTransaction(ctx context.Context) (Transaction, error) {
+	return nil, errors.New("nested transactions are not supported")
+}
+
+func (tx *inMemTransaction) GetBlockNumber(ctx context.Context) (int, error) {
+	if err := tx.lock(); err != nil {
+		return 0, err
+	}
+	defer tx.mtx.Unlock()
+
+	if tx.blockNumber != nil {
+		return *tx.blockNumber, nil
+	}
+	return tx.repo.GetBlockNumber(ctx)
+}
+
+func (tx *inMemTransaction) SetBlockNumber(ctx context.Context, blockNumber int) error {
+	if err := tx.lock(); err != nil {
+		return err
+	}
+	defer tx.mtx.Unlock()
+
+	tx.blockNumber = &blockNumber
+	tx.ops = append(tx.ops, operation{Kind: opSetBlockNumber, BlockNumber: blockNumber})
+	return nil
+}
+
+func (tx *inMemTransaction) SetBlockHash(ctx context.Context, blockNumber int, hash string) error {
+	if err := tx.lock(); err != nil {
+		return err
+	}
+	defer tx.mtx.Unlock()
+
+	tx.blockHashes[blockNumber] = hash
+	tx.ops = append(tx.ops, operation{Kind: opSetBlockHash, BlockNumber: blockNumber, Hash: hash})
+	return nil
+}
+
+func (tx *inMemTransaction) GetBlockHash(ctx context.Context, blockNumber int) (string, error) {
+	if err := tx.lock(); err != nil {
+		return "", err
+	}
+	defer tx.mtx.Unlock()
+
+	if hash, ok := tx.blockHashes[blockNumber]; ok {
+		return hash, nil
+	}
+	if blockNumber >= tx.removedFrom || blockNumber < tx.prunedBefore {
+		return "", errs.NotFoundErr()
+	}
+	return tx.repo.GetBlockHash(ctx, blockNumber)
+}
+
+func (tx *inMemTransaction) RemoveBlocks(ctx context.Context, fromBlock int) error {
+	if err := tx.lock(); err != nil {
+		return err
+	}
+	defer tx.mtx.Unlock()
+
+	tx.removedFrom = min(tx.removedFrom, fromBlock)
+	for blockNumber := range tx.blockHashes {
+		if blockNumber >= fromBlock {
+			delete(tx.blockHashes, blockNumber)
+		}
+	}
+	for address, transactions := range tx.transactions {
+		tx.transactions[address] = keepBefore(transactions, fromBlock)
+		tx.transactionKeys[address].removeFrom(fromBlock)
+	}
+	for address, transfers := range tx.tokenTransfers {
+		tx.tokenTransfers[address] = keepTokenTransfersBefore(transfers, fromBlock)
+		tx.tokenTransferKeys[address].removeFrom(fromBlock)
+	}
+	for address, transfers := range tx.nftTransfers {
+		tx.nftTransfers[address] = keepNFTTransfersBefore(transfers, fromBlock)
+		tx.nftTransferKeys[address].removeFrom(fromBlock)
+	}
+	tx.ops = append(tx.ops, operation{Kind: opRemoveBlocks, BlockNumber: fromBlock})
+	return nil
+}
+
+func (tx *inMemTransaction) PruneBlockHashes(ctx context.Context, beforeBlock int) error {
+	if err := tx.lock(); err != nil {
+		return err
+	}
+	defer tx.mtx.Unlock()
+
+	tx.prunedBefore = max(tx.prunedBefore, beforeBlock)
+	for blockNumber := range tx.blockHashes {
+		if blockNumber < beforeBlock {
+			delete(tx.blockHashes, blockNumber)
+		}
+	}
+	tx.ops = append(tx.ops, operation{Kind: opPruneBlockHashes, BlockNumber: beforeBlock})
+	return nil
+}
+
+func (tx *inMemTransaction) GetTransactions(ctx context.Context, address string) ([]domain.Transaction, error) {
+	if err := tx.lock(); err != nil {
+		return nil, err
+	}
+	defer tx.mtx.Unlock()
+
+	if !tx.knowsAddress(address) {
+		return nil, errs.NotFoundErr()
+	}
+	transactions := make([]domain.Transaction, 0)
+	if _, purged := tx.purged[address]; !purged {
+		committed, err := tx.repo.GetTransactions(ctx, address)
+		if err != nil && !errs.IsNotFoundErr(err) {
+			return nil, err
+		}
+		if err == nil {
+			transactions = committed
+		}
+	}
+	if tx.removedFrom != math.MaxInt {
+		transactions = keepBefore(transactions, tx.removedFrom)
+	}
+	return append(transactions, tx.transactions[address]...), nil
+}
+
+func (tx *inMemTransaction) QueryTransactions(ctx context.Context, query domain.TransactionQuery) (domain.TransactionPage, error) {
+	transactions, err := tx.GetTransactions(ctx, query.Address)
+	if err != nil {
+		return domain.TransactionPage{}, err
+	}
+	return queryTransactions(transactions, query)
+}
+
+func (tx *inMemTransaction) AddTransaction(ctx context.Context, address string, transaction domain.Transaction) error {
+	if err := tx.lock(); err != nil {
+		return err
+	}
+	defer tx.mtx.Unlock()
+
+	if !tx.hasAddress(address) {
+		return errs.NotFoundErr()
+	}
+	if key := transactionKey(&transaction); tx.hasCommittedRecord(tx.repo.transactionKeys, address, key) ||
+		!addRecordKey(tx.transactionKeys, address, key) {
+		return nil
+	}
+	tx.transactions[address] = append(tx.transactions[address], transaction)
+	tx.ops = append(tx.ops, operation{Kind: opAddTransaction, Address: address, Transaction: &transaction})
+	return nil
+}
+
+func (tx *inMemTransaction) AddTokenTransfer(ctx context.Context, address string, transfer domain.TokenTransfer) error {
+	if err := tx.lock(); err != nil {
+		return err
+	}
+	defer tx.mtx.Unlock()
+
+	if !tx.hasAddress(address) {
+		return errs.NotFoundErr()
+	}
+	if key := tokenTransferKey(&transfer); tx.hasCommittedRecord(tx.repo.tokenTransferKeys, address, key) ||
+		!addRecordKey(tx.tokenTransferKeys, address, key) {
+		return nil
+	}
+	tx.tokenTransfers[address] = append(tx.tokenTransfers[address], transfer)
+	tx.ops = append(tx.ops, operation{Kind: opAddTokenTransfer, Address: address, TokenTransfer: &transfer})
+	return nil
+}
+
+func (tx *inMemTransaction) QueryTokenTransfers(ctx context.Context, query domain.TokenTransferQuery) (domain.TokenTransferPage, error) {
+	if err := tx.lock(); err != nil {
+		return domain.TokenTransferPage{}, err
+	}
+	defer tx.mtx.Unlock()
+
+	if !tx.knowsAddress(query.Address) {
+		return domain.TokenTransferPage{}, errs.NotFoundErr()
+	}
+	transfers := make([]domain.TokenTransfer, 0)
+	if _, purged := tx.purged[query.Address]; !purged {
+		tx.repo.commitMtx.RLock()
+		committed, err := tx.repo.getTokenTransfers(query.Address)
+		tx.repo.commitMtx.RUnlock()
+		if err != nil && !errs.IsNotFoundErr(err) {
+			return domain.TokenTransferPage{}, err
+		}
+		if err == nil {
+			transfers = committed
+		}
+	}
+	if tx.removedFrom != math.MaxInt {
+		transfers = keepTokenTransfersBefore(transfers, tx.removedFrom)
+	}
+	return queryTokenTransfers(append(transfers, tx.tokenTransfers[query.Address]...), query)
+}
+
+func (tx *inMemTransaction) AddNFTTransfer(ctx context.Context, address string, transfer domain.NFTTransfer) error {
+	if err := tx.lock(); err != nil {
+		return err
+	}
+	defer tx.mtx.Unlock()
+
+	if !tx.hasAddress(address) {
+		return errs.NotFoundErr()
+	}
+	if key := nftTransferKey(&transfer); tx.hasCommittedRecord(tx.repo.nftTransferKeys, address, key) ||
+		!addRecordKey(tx.nftTransferKeys, address, key) {
+		return nil
+	}
+	tx.nftTransfers[address] = append(tx.nftTransfers[address], transfer)
+	tx.ops = append(tx.ops, operation{Kind: opAddNFTTransfer, Address: address, NFTTransfer: &transfer})
+	return nil
+}
+
+func (tx *inMemTransaction) QueryNFTTransfers(ctx context.Context, query domain.NFTTransferQuery) (domain.NFTTransferPage, error) {
+	if err := tx.lock(); err != nil {
+		return domain.NFTTransferPage{}, err
+	}
+	defer tx.mtx.Unlock()
+
+	if !tx.knowsAddress(query.Address) {
+		return domain.NFTTransferPage{}, errs.NotFoundErr()
+	}
+	transfers := make([]domain.NFTTransfer, 0)
+	if _, purged := tx.purged[query.Address]; !purged {
+		tx.repo.commitMtx.RLock()
+		committed, err := tx.repo.getNFTTransfers(query.Address)
+		tx.repo.commitMtx.RUnlock()
+		if err != nil && !errs.IsNotFoundErr(err) {
+			return domain.NFTTransferPage{}, err
+		}
+		if err == nil {
+			transfers = committed
+		}
+	}
+	if tx.removedFrom != math.MaxInt {
+		transfers = keepNFTTransfersBefore(transfers, tx.removedFrom)
+	}
+	return queryNFTTransfers(append(transfers, tx.nftTransfers[query.Address]...), query)
+}
+
+func (tx *inMemTransaction) AddAddress(ctx context.Context, address string) error {
+	if err := tx.lock(); err != nil {
+		return err
+	}
+	defer tx.mtx.Unlock()
+
+	if tx.hasAddress(address) {
+		return errs.AlreadyExistErr()
+	}
+	tx.addresses[address] = true
+	tx.ops = append(tx.ops, operation{Kind: opAddAddress, Address: address})
+	return nil
+}
+
+func (tx *inMemTransaction) RemoveAddress(ctx context.Context, address string, purge bool) error {
+	if err := tx.lock(); err != nil {
+		return err
+	}
+	defer tx.mtx.Unlock()
+
+	if !tx.knowsAddress(address) || (!purge && !tx.hasAddress(address)) {
+		return errs.NotFoundErr()
+	}
+	delete(tx.labels, address)
+	if purge {
+		delete(tx.addresses, address)
+		delete(tx.transactions, address)
+		delete(tx.transactionKeys, address)
+		delete(tx.tokenTransfers, address)
+		delete(tx.tokenTransferKeys, address)
+		delete(tx.nftTransfers, address)
+		delete(tx.nftTransferKeys, address)
+		tx.purged[address] = struct{}{}
+	} else {
+		tx.addresses[address] = false
+	}
+	tx.ops = append(tx.ops, operation{Kind: opRemoveAddress, Address: address, Purge: purge})
+	return nil
+}
+
+func (tx *inMemTransaction) GetAddresses(ctx context.Context) ([]string, error) {
+	if err := tx.lock(); err != nil {
+		return nil, err
+	}
+	defer tx.mtx.Unlock()
+
+	committed, err := tx.repo.GetAddresses(ctx)
+	if err != nil {
+		return nil, err
+	}
+	addresses := make([]string, 0, len(committed))
+	for _, address := range committed {
+		if tx.hasAddress(address) {
+			addresses = append(addresses, address)
+		}
+	}
+	for address, subscribed := range tx.addresses {
+		if subscribed && !slices.Contains(committed, address) {
+			addresses = append(addresses, address)
+		}
+	}
+	sort.Strings(addresses)
+	return addresses, nil
+}
+
+func (tx *inMemTransaction) SetAddressLabel(ctx context.Context, address string, label string) error {
+	if err := tx.lock(); err != nil {
+		return err
+	}
+	defer tx.mtx.Unlock()
+
+	if !tx.hasAddress(address) {
+		return errs.NotFoundErr()
+	}
+	tx.labels[address] = label
+	tx.ops = append(tx.ops, operation{Kind: opSetAddressLabel, Address: address, Label: label})
+	return nil
+}
+
+func (tx *inMemTransaction) GetSubscriptions(ctx context.Context) ([]domain.Subscription, error) {
+	committed, err := tx.repo.GetSubscriptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	committedLabels := make(map[string]string, len(committed))
+	for i := range committed {
+		committedLabels[committed[i].Address.String()] = committed[i].Label
+	}
+
+	addresses, err := tx.GetAddresses(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.lock(); err != nil {
+		return nil, err
+	}
+	defer tx.mtx.Unlock()
+
+	subscriptions := make([]domain.Subscription, len(addresses))
+	for i, address := range addresses {
+		subscriptions[i] = domain.Subscription{Address: domain.Address(address)}
+		if label, ok := tx.labels[address]; ok {
+			subscriptions[i].Label = label
+		} else if _, changed := tx.addresses[address]; !changed {
+			// the label of an address subscribed again by the transaction was removed with the address
+			subscriptions[i].Label = committedLabels[address]
+		}
+	}
+	return subscriptions, nil
+}
+
+// hasAddress reports whether the address is subscribed. The caller must hold mtx.
+func (tx *inMemTransaction) hasAddress(address string) bool {
+	if subscribed, ok := tx.addresses[address]; ok {
+		return subscribed
+	}
+	if _, purged := tx.purged[address]; purged {
+		return false
+	}
+	return tx.repo.hasAddress(address)
+}
+
+// knowsAddress reports whether the address is subscribed or its transactions are kept. The caller must hold mtx.
+func (tx *inMemTransaction) knowsAddress(address string) bool {
+	if _, ok := tx.addresses[address]; ok {
+		return true
+	}
+	if _, purged := tx.purged[address]; purged {
+		return false
+	}
+	return tx.repo.knowsAddress(address)
+}
+
+// Commit applies the buffered writes to the repository. Readers of the repository observe either none or all of them.
+func (tx *inMemTransaction) Commit(ctx context.Context) error {
+	if err := tx.lock(); err != nil {
+		return err
+	}
+	defer tx.mtx.Unlock()
+
+	tx.done = true
+	ops := tx.ops
+	tx.ops = nil
+	if len(ops) == 0 {
+		return nil
+	}
+	return tx.commit(ops)
+}
+
+// Rollback discards the buffered writes
+func (tx *inMemTransaction) Rollback(ctx context.Context) error {
+	if err := tx.lock(); err != nil {
+		return err
+	}
+	defer tx.mtx.Unlock()
+
+	tx.done = true
+	tx.ops = nil
+	return nil
+}
+
+// hasCommittedRecord reports whether the committed index of the address contains the key of a record, unless the
+// transaction removed it. The caller must hold mtx.
+func (tx *inMemTransaction) hasCommittedRecord(indexes map[string]recordIndex, address string, key recordKey) bool {
+	if _, purged := tx.purged[address]; purged {
+		return false
+	}
+	tx.repo.commitMtx.RLock()
+	defer tx.repo.commitMtx.RUnlock()
+
+	return tx.repo.hasRecord(indexes, address, key, tx.removedFrom)
+}
+
+// keepBefore returns the transactions of the blocks before the given block number
+func keepBefore(transactions []domain.Transaction, fromBlock int) []domain.Transaction {
+	kept := make([]domain.Transaction, 0, len(transactions))
+	for i := range transactions {
+		if int(transactions[i].BlockNumber) < fromBlock {
+			kept = append(kept, transactions[i])
+		}
+	}
+	return kept
+}
+
+// keepTokenTransfersBefore returns the token transfers of the blocks before the given block number
+func keepTokenTransfersBefore(transfers []domain.TokenTransfer, fromBlock int) []domain.TokenTransfer {
+	kept := make([]domain.TokenTransfer, 0, len(transfers))
+	for i := range transfers {
+		if int(transfers[i].BlockNumber) < fromBlock {
+			kept = append(kept, transfers[i])
+		}
+	}
+	return kept
+}
+
+// keepNFTTransfersBefore returns the nft transfers of the blocks before the given block number
+func keepNFTTransfersBefore(transfers []domain.NFTTransfer, fromBlock int) []domain.NFTTransfer {
+	kept := make([]domain.NFTTransfer, 0, len(transfers))
+	for i := range transfers {
+		if int(transfers[i].BlockNumber) < fromBlock {
+			kept = append(kept, transfers[i])
+		}
+	}
+	return kept
+}
@@ -0,0 +1,204 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/aniladanir/ethereum-blockchain-parser/internal/core/domain"
+	"github.com/aniladanir/ethereum-blockchain-parser/pkg/errs"
+)
+
+func TestInMemTransactionCommit(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInmemTransactionRepository()
+	_ = repo.AddAddress(ctx, "0x123")
+
+	repoTx, _ := repo.NewTransaction(ctx)
+	_ = repoTx.AddTransaction(ctx, "0x123", domain.Transaction{Hash: "hash1", BlockNumber: 1})
+	_ = repoTx.SetBlockHash(ctx, 1, "block1")
+	_ = repoTx.SetBlockNumber(ctx, 1)
+
+	// the transaction reads its own writes
+	if transactions, _ := repoTx.GetTransactions(ctx, "0x123"); len(transactions) != 1 {
+		t.Errorf("expected transaction to read its own writes, got %v", transactions)
+	}
+	if blockNumber, _ := repoTx.GetBlockNumber(ctx); blockNumber != 1 {
+		t.Errorf("expected block number 1 within transaction, got %d", blockNumber)
+	}
+
+	// the repository does not observe uncommitted writes
+	if transactions, _ := repo.GetTransactions(ctx, "0x123"); len(transactions) != 0 {
+		t.Errorf("expected no transactions before commit, got %v", transactions)
+	}
+	if blockNumber, _ := repo.GetBlockNumber(ctx); blockNumber != 0 {
+		t.Errorf("expected block number 0 before commit, got %d", blockNumber)
+	}
+	if _, err := repo.GetBlockHash(ctx, 1); !errs.IsNotFoundErr(err) {
+		t.Errorf("expected block hash to be not found before commit, got %v", err)
+	}
+
+	if err := repoTx.Commit(ctx); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if transactions, _ := repo.GetTransactions(ctx, "0x123"); len(transactions) != 1 || transactions[0].Hash != "hash1" {
+		t.Errorf("expected committed transaction, got %v", transactions)
+	}
+	if blockNumber, _ := repo.GetBlockNumber(ctx); blockNumber != 1 {
+		t.Errorf("expected block number 1 after commit, got %d", blockNumber)
+	}
+	if hash, _ := repo.GetBlockHash(ctx, 1); hash != "block1" {
+		t.Errorf("expected block hash block1 after commit, got %s", hash)
+	}
+}
+
+func TestInMemTransactionRollback(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInmemTransactionRepository()
+	_ = repo.AddAddress(ctx, "0x123")
+	_ = repo.AddTransaction(ctx, "0x123", domain.Transaction{Hash: "hash1", BlockNumber: 1})
+	_ = repo.SetBlockNumber(ctx, 1)
+
+	repoTx, _ := repo.NewTransaction(ctx)
+	_ = repoTx.RemoveBlocks(ctx, 1)
+	_ = repoTx.AddAddress(ctx, "0x456")
+	_ = repoTx.AddTransaction(ctx, "0x456", domain.Transaction{Hash: "hash2", BlockNumber: 2})
+	_ = repoTx.SetBlockNumber(ctx, 2)
+
+	if transactions, _ := repoTx.GetTransactions(ctx, "0x123"); len(transactions) != 0 {
+		t.Errorf("expected removed transactions to be hidden within transaction, got %v", transactions)
+	}
+
+	if err := repoTx.Rollback(ctx); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if transactions, _ := repo.GetTransactions(ctx, "0x123"); len(transactions) != 1 {
+		t.Errorf("expected transactions to be kept after rollback, got %v", transactions)
+	}
+	if _, err := repo.GetTransactions(ctx, "0x456"); !errs.IsNotFoundErr(err) {
+		t.Errorf("expected address to be discarded after rollback, got %v", err)
+	}
+	if blockNumber, _ := repo.GetBlockNumber(ctx); blockNumber != 1 {
+		t.Errorf("expected block number 1 after rollback, got %d", blockNumber)
+	}
+}
+
+func TestInMemTransactionDone(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInmemTransactionRepository()
+	_ = repo.AddAddress(ctx, "0x123")
+
+	tests := []struct {
+		name     string
+		complete func(tx Transaction) error
+	}{
+		{
+			name:     "AfterCommit",
+			complete: func(tx Transaction) error { return tx.Commit(ctx) },
+		},
+		{
+			name:     "AfterRollback",
+			complete: func(tx Transaction) error { return tx.Rollback(ctx) },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repoTx, _ := repo.NewTransaction(ctx)
+			if err := tt.complete(repoTx); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			if err := repoTx.AddTransaction(ctx, "0x123", domain.Transaction{Hash: "hash1"}); !errs.IsTransactionDoneErr(err) {
+				t.Errorf("expected transaction done error, got %v", err)
+			}
+			if err := repoTx.SetBlockNumber(ctx, 1); !errs.IsTransactionDoneErr(err) {
+				t.Errorf("expected transaction done error, got %v", err)
+			}
+			if _, err := repoTx.GetBlockNumber(ctx); !errs.IsTransactionDoneErr(err) {
+				t.Errorf("expected transaction done error, got %v", err)
+			}
+			if err := repoTx.Commit(ctx); !errs.IsTransactionDoneErr(err) {
+				t.Errorf("expected transaction done error, got %v", err)
+			}
+			if err := repoTx.Rollback(ctx); !errs.IsTransactionDoneErr(err) {
+				t.Errorf("expected transaction done error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestInMemTransactionConcurrentReaders(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInmemTransactionRepository()
+	_ = repo.AddAddress(ctx, "0x123")
+	_ = repo.AddAddress(ctx, "0x456")
+
+	const blocks = 200
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+
+	// every block adds a transaction for both addresses, so readers must see both addresses
+	// with at least as many transactions as the block number read before them
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				blockNumber, _ := repo.GetBlockNumber(ctx)
+				first, _ := repo.GetTransactions(ctx, "0x123")
+				second, _ := repo.GetTransactions(ctx, "0x456")
+				if len(first) < blockNumber || len(second) < blockNumber {
+					t.Errorf("observed block number %d with %d and %d transactions", blockNumber, len(first), len(second))
+					return
+				}
+				// the second address is read later, so it can only be behind if a commit was partially applied
+				if len(second) < len(first) {
+					t.Errorf("observed partial commit with %d and %d transactions", len(first), len(second))
+					return
+				}
+			}
+		}()
+	}
+
+	for block := 1; block <= blocks; block++ {
+		repoTx, _ := repo.NewTransaction(ctx)
+		for _, address := range []string{"0x123", "0x456"} {
+			tx := domain.Transaction{Hash: fmt.Sprintf("%s-%d", address, block), BlockNumber: uint64(block)}
+			if err := repoTx.AddTransaction(ctx, address, tx); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+		}
+		_ = repoTx.SetBlockNumber(ctx, block)
+
+		// every other transaction is rolled back and must never be observed
+		if block%2 == 0 {
+			_ = repoTx.Rollback(ctx)
+			repoTx, _ = repo.NewTransaction(ctx)
+			for _, address := range []string{"0x123", "0x456"} {
+				tx := domain.Transaction{Hash: fmt.Sprintf("%s-%d", address, block), BlockNumber: uint64(block)}
+				_ = repoTx.AddTransaction(ctx, address, tx)
+			}
+			_ = repoTx.SetBlockNumber(ctx, block)
+		}
+		if err := repoTx.Commit(ctx); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	for _, address := range []string{"0x123", "0x456"} {
+		if transactions, _ := repo.GetTransactions(ctx, address); len(transactions) != blocks {
+			t.Errorf("expected %d transactions for %s, got %d", blocks, address, len(transactions))
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package repositories
+
+import (
+	"github.com/aniladanir/ethereum-blockchain-parser/internal/core/domain"
+)
+
+// operation kinds
+const (
+	opAddAddress       = "addAddress"
+	opRemoveAddress    = "removeAddress"
+	opSetAddressLabel  = "setAddressLabel"
+	opAddTransaction   = "addTransaction"
+	opAddTokenTransfer = "addTokenTransfer"
+	opAddNFTTransfer   = "addNFTTransfer"
+	opSetBlockNumber   = "setBlockNumber"
+	opSetBlockHash     = "setBlockHash"
+	opRemoveBlocks     = "removeBlocks"
+	opPruneBlockHashes = "pruneBlockHashes"
+)
+
+// operation is a single buffered write of a transaction. Operations are serializable,
+// so that they can be written to a log before being applied.
+type operation struct {
+	Kind          string                `json:"kind"`
+	Address       string                `json:"address,omitempty"`
+	BlockNumber   int                   `json:"blockNumber,omitempty"`
+	Hash          string                `json:"hash,omitempty"`
+	Transaction   *domain.Transaction   `json:"transaction,omitempty"`
+	TokenTransfer *domain.TokenTransfer `json:"tokenTransfer,omitempty"`
+	NFTTransfer   *domain.NFTTransfer   `json:"nftTransfer,omitempty"`
+	Purge         bool                  `json:"purge,omitempty"`
+	Label         string                `json:"label,omitempty"`
+}
+
+// apply writes the operation to the repository. The caller must hold commitMtx exclusively.
+func (op *operation) apply(repo *inMemRepository) {
+	switch op.Kind {
+	case opAddAddress:
+		// the address may have been added concurrently, which leaves the same state
+		_ = repo.addAddress(op.Address)
+	case opRemoveAddress:
+		// the address may have been removed concurrently, which leaves the same state
+		_ = repo.removeAddress(op.Address, op.Purge)
+	case opSetAddressLabel:
+		// the address may have been removed concurrently, in which case its label is not needed
+		_ = repo.setAddressLabel(op.Address, op.Label)
+	case opAddTransaction:
+		// the address may have been removed concurrently, in which case its transactions are not needed
+		_ = repo.addTransaction(op.Address, *op.Transaction)
+	case opAddTokenTransfer:
+		// the address may have been removed concurrently, in which case its token transfers are not needed
+		_ = repo.addTokenTransfer(op.Address, *op.TokenTransfer)
+	case opAddNFTTransfer:
+		// the address may have been removed concurrently, in which case its nft transfers are not needed
+		_ = repo.addNFTTransfer(op.Address, *op.NFTTransfer)
+	case opSetBlockNumber:
+		repo.setBlockNumber(op.BlockNumber)
+	case opSetBlockHash:
+		repo.setBlockHash(op.BlockNumber, op.Hash)
+	case opRemoveBlocks:
+		repo.removeBlocks(op.BlockNumber)
+	case opPruneBlockHashes:
+		repo.pruneBlockHashes(op.BlockNumber)
+	}
+}
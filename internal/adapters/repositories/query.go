@@ -0,0 +1,335 @@
+package repositories
+
+import (
+	"cmp"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/aniladanir/ethereum-blockchain-parser/internal/core/domain"
+	"github.com/aniladanir/ethereum-blockchain-parser/pkg/errs"
+)
+
+// transactionCursor is the position of the last transaction of a page
+type transactionCursor struct {
+	blockNumber int
+	hash        string
+}
+
+func encodeCursor(c transactionCursor) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(c.blockNumber) + ":" + c.hash))
+}
+
+func decodeCursor(cursor string) (transactionCursor, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return transactionCursor{}, errs.InvalidCursorErr()
+	}
+	blockNumber, hash, ok := strings.Cut(string(decoded), ":")
+	if !ok {
+		return transactionCursor{}, errs.InvalidCursorErr()
+	}
+	n, err := strconv.Atoi(blockNumber)
+	if err != nil {
+		return transactionCursor{}, errs.InvalidCursorErr()
+	}
+	return transactionCursor{blockNumber: n, hash: hash}, nil
+}
+
+// compare orders the position of a transaction against the cursor, by block number then hash
+func (c transactionCursor) compare(blockNumber int, hash string) int {
+	if blockNumber != c.blockNumber {
+		return cmp.Compare(blockNumber, c.blockNumber)
+	}
+	return strings.Compare(hash, c.hash)
+}
+
+// valueKey returns the value as zero padded hex, which orders the same lexically and numerically
+func valueKey(value *big.Int) string {
+	return fmt.Sprintf("%064x", value)
+}
+
+// queryTransactions selects the page of the transactions of query.Address that matches the query
+func queryTransactions(transactions []domain.Transaction, query domain.TransactionQuery) (domain.TransactionPage, error) {
+	var cursor *transactionCursor
+	if query.Cursor != "" {
+		c, err := decodeCursor(query.Cursor)
+		if err != nil {
+			return domain.TransactionPage{}, err
+		}
+		cursor = &c
+	}
+
+	// sign is 1 for ascending order and -1 for descending order
+	sign := 1
+	if query.Order == domain.SortDescending {
+		sign = -1
+	}
+
+	type entry struct {
+		position    transactionCursor
+		transaction domain.Transaction
+	}
+	matched := make([]entry, 0)
+	for i := range transactions {
+		blockNumber := int(transactions[i].BlockNumber)
+		if !matchesQuery(&transactions[i], blockNumber, query) {
+			continue
+		}
+		if cursor != nil && cursor.compare(blockNumber, transactions[i].Hash)*sign <= 0 {
+			continue
+		}
+		matched = append(matched, entry{
+			position:    transactionCursor{blockNumber: blockNumber, hash: transactions[i].Hash},
+			transaction: transactions[i],
+		})
+	}
+
+	slices.SortStableFunc(matched, func(a, b entry) int {
+		return b.position.compare(a.position.blockNumber, a.position.hash) * sign
+	})
+
+	page := domain.TransactionPage{Transactions: make([]domain.Transaction, 0, len(matched))}
+	for i := range matched {
+		if query.Limit > 0 && i == query.Limit {
+			page.NextCursor = encodeCursor(matched[i-1].position)
+			break
+		}
+		page.Transactions = append(page.Transactions, matched[i].transaction)
+	}
+	return page, nil
+}
+
+func matchesQuery(tx *domain.Transaction, blockNumber int, query domain.TransactionQuery) bool {
+	if query.FromBlock != nil && blockNumber < *query.FromBlock {
+		return false
+	}
+	if query.ToBlock != nil && blockNumber > *query.ToBlock {
+		return false
+	}
+
+	incoming := strings.EqualFold(tx.To, query.Address)
+	outgoing := strings.EqualFold(tx.From, query.Address)
+	switch query.Direction {
+	case domain.DirectionIn:
+		if !incoming {
+			return false
+		}
+	case domain.DirectionOut:
+		if !outgoing {
+			return false
+		}
+	}
+
+	if query.Counterparty != "" {
+		fromCounterparty := incoming && strings.EqualFold(tx.From, query.Counterparty)
+		toCounterparty := outgoing && strings.EqualFold(tx.To, query.Counterparty)
+		switch query.Direction {
+		case domain.DirectionIn:
+			toCounterparty = false
+		case domain.DirectionOut:
+			fromCounterparty = false
+		}
+		if !fromCounterparty && !toCounterparty {
+			return false
+		}
+	}
+
+	if query.MinValue != nil {
+		if tx.Value == nil || tx.Value.Cmp(query.MinValue) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// transferQuery holds the filters, ordering and pagination shared by the token and nft transfer queries
+type transferQuery struct {
+	address string
+	// contract is the address of the token or nft contract, empty for the transfers of every contract
+	contract  string
+	fromBlock *int
+	toBlock   *int
+	direction domain.Direction
+	order     domain.SortOrder
+	limit     int
+	cursor    string
+}
+
+func tokenTransferQuery(query domain.TokenTransferQuery) transferQuery {
+	return transferQuery{
+		address:   query.Address,
+		contract:  query.Token,
+		fromBlock: query.FromBlock,
+		toBlock:   query.ToBlock,
+		direction: query.Direction,
+		order:     query.Order,
+		limit:     query.Limit,
+		cursor:    query.Cursor,
+	}
+}
+
+func nftTransferQuery(query domain.NFTTransferQuery) transferQuery {
+	return transferQuery{
+		address:   query.Address,
+		contract:  query.Contract,
+		fromBlock: query.FromBlock,
+		toBlock:   query.ToBlock,
+		direction: query.Direction,
+		order:     query.Order,
+		limit:     query.Limit,
+		cursor:    query.Cursor,
+	}
+}
+
+// transferCursor is the position of the last transfer of a page, the batch index is always zero for token transfers
+type transferCursor struct {
+	blockNumber int
+	logIndex    uint64
+	batchIndex  uint64
+}
+
+// encodeTransferCursor encodes the cursor like a transaction cursor, with the log index, followed by the batch index
+// when there is one, in place of the hash
+func encodeTransferCursor(c transferCursor) string {
+	index := strconv.FormatUint(c.logIndex, 10)
+	if c.batchIndex > 0 {
+		index += "." + strconv.FormatUint(c.batchIndex, 10)
+	}
+	return encodeCursor(transactionCursor{blockNumber: c.blockNumber, hash: index})
+}
+
+func decodeTransferCursor(cursor string) (transferCursor, error) {
+	c, err := decodeCursor(cursor)
+	if err != nil {
+		return transferCursor{}, err
+	}
+	decoded := transferCursor{blockNumber: c.blockNumber}
+	logIndex, batchIndex, batched := strings.Cut(c.hash, ".")
+	if decoded.logIndex, err = strconv.ParseUint(logIndex, 10, 64); err != nil {
+		return transferCursor{}, errs.InvalidCursorErr()
+	}
+	if batched {
+		if decoded.batchIndex, err = strconv.ParseUint(batchIndex, 10, 64); err != nil {
+			return transferCursor{}, errs.InvalidCursorErr()
+		}
+	}
+	return decoded, nil
+}
+
+// compare orders the position of a transfer against the cursor, by block number, log index then batch index
+func (c transferCursor) compare(position transferCursor) int {
+	if position.blockNumber != c.blockNumber {
+		return cmp.Compare(position.blockNumber, c.blockNumber)
+	}
+	if position.logIndex != c.logIndex {
+		return cmp.Compare(position.logIndex, c.logIndex)
+	}
+	return cmp.Compare(position.batchIndex, c.batchIndex)
+}
+
+// transferFields are the fields of a transfer that transfer queries filter and order on
+type transferFields struct {
+	position transferCursor
+	contract string
+	from     string
+	to       string
+}
+
+func tokenTransferFields(transfer *domain.TokenTransfer) transferFields {
+	return transferFields{
+		position: transferCursor{blockNumber: int(transfer.BlockNumber), logIndex: transfer.LogIndex},
+		contract: transfer.Token,
+		from:     transfer.From,
+		to:       transfer.To,
+	}
+}
+
+func nftTransferFields(transfer *domain.NFTTransfer) transferFields {
+	return transferFields{
+		position: transferCursor{blockNumber: int(transfer.BlockNumber), logIndex: transfer.LogIndex, batchIndex: transfer.BatchIndex},
+		contract: transfer.Contract,
+		from:     transfer.From,
+		to:       transfer.To,
+	}
+}
+
+// queryTokenTransfers selects the page of the token transfers of query.Address that matches the query
+func queryTokenTransfers(transfers []domain.TokenTransfer, query domain.TokenTransferQuery) (domain.TokenTransferPage, error) {
+	matched, nextCursor, err := queryTransfers(transfers, tokenTransferQuery(query), tokenTransferFields)
+	if err != nil {
+		return domain.TokenTransferPage{}, err
+	}
+	return domain.TokenTransferPage{TokenTransfers: matched, NextCursor: nextCursor}, nil
+}
+
+// queryNFTTransfers selects the page of the nft transfers of query.Address that matches the query
+func queryNFTTransfers(transfers []domain.NFTTransfer, query domain.NFTTransferQuery) (domain.NFTTransferPage, error) {
+	matched, nextCursor, err := queryTransfers(transfers, nftTransferQuery(query), nftTransferFields)
+	if err != nil {
+		return domain.NFTTransferPage{}, err
+	}
+	return domain.NFTTransferPage{NFTTransfers: matched, NextCursor: nextCursor}, nil
+}
+
+// queryTransfers selects the page of the transfers that matches the query, and the cursor of the next page
+func queryTransfers[T any](transfers []T, query transferQuery, fields func(transfer *T) transferFields) ([]T, string, error) {
+	var cursor *transferCursor
+	if query.cursor != "" {
+		c, err := decodeTransferCursor(query.cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		cursor = &c
+	}
+
+	// sign is 1 for ascending order and -1 for descending order
+	sign := 1
+	if query.order == domain.SortDescending {
+		sign = -1
+	}
+
+	matched := make([]T, 0)
+	for i := range transfers {
+		transfer := fields(&transfers[i])
+		if !query.matches(transfer) {
+			continue
+		}
+		if cursor != nil && cursor.compare(transfer.position)*sign <= 0 {
+			continue
+		}
+		matched = append(matched, transfers[i])
+	}
+
+	slices.SortStableFunc(matched, func(a, b T) int {
+		return fields(&b).position.compare(fields(&a).position) * sign
+	})
+
+	if query.limit > 0 && len(matched) > query.limit {
+		return matched[:query.limit], encodeTransferCursor(fields(&matched[query.limit-1]).position), nil
+	}
+	return matched, "", nil
+}
+
+func (query transferQuery) matches(transfer transferFields) bool {
+	blockNumber := transfer.position.blockNumber
+	if query.fromBlock != nil && blockNumber < *query.fromBlock {
+		return false
+	}
+	if query.toBlock != nil && blockNumber > *query.toBlock {
+		return false
+	}
+	if query.contract != "" && !strings.EqualFold(transfer.contract, query.contract) {
+		return false
+	}
+	switch query.direction {
+	case domain.DirectionIn:
+		return strings.EqualFold(transfer.to, query.address)
+	case domain.DirectionOut:
+		return strings.EqualFold(transfer.from, query.address)
+	}
+	return true
+}
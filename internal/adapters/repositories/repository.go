@@ -8,24 +8,64 @@ import (
 
 // Repository defines the interface for accessing transaction data.
 type Repository interface {
-	// AddTransaction writes transaction to the given address
+	// AddTransaction writes transaction to the given address, unless the address already has a transaction with its hash
 	AddTransaction(ctx context.Context, address string, transaction domain.Transaction) error
 
 	// GetTransactions returns a list of transactions
 	GetTransactions(ctx context.Context, address string) ([]domain.Transaction, error)
 
+	// QueryTransactions returns the page of the transactions of query.Address that matches the query
+	QueryTransactions(ctx context.Context, query domain.TransactionQuery) (domain.TransactionPage, error)
+
+	// AddTokenTransfer writes token transfer to the given address, unless the address already has the transfer
+	// with its transaction hash and log index
+	AddTokenTransfer(ctx context.Context, address string, transfer domain.TokenTransfer) error
+
+	// QueryTokenTransfers returns the page of the token transfers of query.Address that matches the query
+	QueryTokenTransfers(ctx context.Context, query domain.TokenTransferQuery) (domain.TokenTransferPage, error)
+
+	// AddNFTTransfer writes nft transfer to the given address, unless the address already has the transfer with its
+	// transaction hash, log index and batch index
+	AddNFTTransfer(ctx context.Context, address string, transfer domain.NFTTransfer) error
+
+	// QueryNFTTransfers returns the page of the nft transfers of query.Address that matches the query
+	QueryNFTTransfers(ctx context.Context, query domain.NFTTransferQuery) (domain.NFTTransferPage, error)
+
 	// SetBlockNumber sets the block number
 	SetBlockNumber(ctx context.Context, blockNumber int) error
 
 	// GetBlockNumber gets the current block number.
 	GetBlockNumber(ctx context.Context) (int, error)
 
+	// SetBlockHash stores the hash of the processed block with the given number
+	SetBlockHash(ctx context.Context, blockNumber int, hash string) error
+
+	// GetBlockHash returns the stored hash of the block with the given number
+	GetBlockHash(ctx context.Context, blockNumber int) (string, error)
+
+	// RemoveBlocks removes the transactions, token and nft transfers and hashes of the blocks starting from the given block number
+	RemoveBlocks(ctx context.Context, fromBlock int) error
+
+	// PruneBlockHashes removes the hashes of the blocks before the given block number
+	PruneBlockHashes(ctx context.Context, beforeBlock int) error
+
 	// AddAddress add the given address to repository
 	AddAddress(ctx context.Context, address string) error
 
+	// RemoveAddress unsubscribes the given address. Its transactions and transfers are removed if purge is set,
+	// otherwise they remain readable and are kept if the address is added again. Kept records can be purged later on.
+	RemoveAddress(ctx context.Context, address string, purge bool) error
+
 	// GetAddresses returns the list of addresses
 	GetAddresses(ctx context.Context) ([]string, error)
 
+	// SetAddressLabel sets the label of the given subscribed address. An empty label removes it.
+	// Labels are removed when the address is removed.
+	SetAddressLabel(ctx context.Context, address string, label string) error
+
+	// GetSubscriptions returns the subscribed addresses with their labels, ordered by address
+	GetSubscriptions(ctx context.Context) ([]domain.Subscription, error)
+
 	// NewTransaction creates a new transaction
 	NewTransaction(ctx context.Context) (Transaction, error)
 }
@@ -0,0 +1,976 @@
+// Package repotest provides a conformance test suite for implementations of repositories.Repository,
+// so that every backend is validated against the same contract.
+package repotest
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aniladanir/ethereum-blockchain-parser/internal/adapters/repositories"
+	"github.com/aniladanir/ethereum-blockchain-parser/internal/core/domain"
+	"github.com/aniladanir/ethereum-blockchain-parser/pkg/errs"
+)
+
+// NewRepository creates an empty repository for a single test. It should register cleanups with t.Cleanup.
+type NewRepository func(t *testing.T) repositories.Repository
+
+// Run runs the conformance tests against the repositories created by newRepo.
+// The tests are meant to be run with the race detector enabled.
+func Run(t *testing.T, newRepo NewRepository) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, newRepo NewRepository)
+	}{
+		{name: "Addresses", test: testAddresses},
+		{name: "Transactions", test: testTransactions},
+		{name: "TransactionDetails", test: testTransactionDetails},
+		{name: "DuplicateTransactions", test: testDuplicateTransactions},
+		{name: "RemoveAddress", test: testRemoveAddress},
+		{name: "RemoveAddressInTransaction", test: testRemoveAddressInTransaction},
+		{name: "Subscriptions", test: testSubscriptions},
+		{name: "SubscriptionsInTransaction", test: testSubscriptionsInTransaction},
+		{name: "TransactionOrder", test: testTransactionOrder},
+		{name: "QueryFilters", test: testQueryFilters},
+		{name: "QueryPagination", test: testQueryPagination},
+		{name: "TokenTransfers", test: tokenTransfers.testTransfers},
+		{name: "TokenTransferDetails", test: testTokenTransferDetails},
+		{name: "TokenTransferQueries", test: tokenTransfers.testTransferQueries},
+		{name: "DuplicateTokenTransfers", test: tokenTransfers.testDuplicateTransfers},
+		{name: "NFTTransfers", test: nftTransfers.testTransfers},
+		{name: "NFTTransferDetails", test: testNFTTransferDetails},
+		{name: "NFTTransferQueries", test: nftTransfers.testTransferQueries},
+		{name: "NFTTransferBatches", test: testNFTTransferBatches},
+		{name: "DuplicateNFTTransfers", test: nftTransfers.testDuplicateTransfers},
+		{name: "BlockNumber", test: testBlockNumber},
+		{name: "BlockHashes", test: testBlockHashes},
+		{name: "RemoveBlocks", test: testRemoveBlocks},
+		{name: "Commit", test: testCommit},
+		{name: "Rollback", test: testRollback},
+		{name: "TransactionDone", test: testTransactionDone},
+		{name: "ConcurrentAddAddress", test: testConcurrentAddAddress},
+		{name: "ConcurrentWrites", test: testConcurrentWrites},
+		{name: "ConcurrentReaders", test: testConcurrentReaders},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newRepo)
+		})
+	}
+}
+
+func transaction(hash string, block int) domain.Transaction {
+	return domain.Transaction{
+		Hash:        hash,
+		From:        "0xfrom",
+		To:          "0xto",
+		Value:       big.NewInt(1),
+		BlockNumber: uint64(block),
+	}
+}
+
+func hashes(transactions []domain.Transaction) []string {
+	result := make([]string, len(transactions))
+	for i := range transactions {
+		result[i] = transactions[i].Hash
+	}
+	return result
+}
+
+func testAddresses(t *testing.T, newRepo NewRepository) {
+	ctx := context.Background()
+	repo := newRepo(t)
+
+	addresses, err := repo.GetAddresses(ctx)
+	if err != nil || addresses == nil || len(addresses) != 0 {
+		t.Errorf("expected no addresses, got %v, %v", addresses, err)
+	}
+
+	for _, address := range []string{"0xb", "0xa", "0xc"} {
+		if err := repo.AddAddress(ctx, address); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+	if err := repo.AddAddress(ctx, "0xa"); !errs.IsAlreadyExistErr(err) {
+		t.Errorf("expected already exist error, got %v", err)
+	}
+
+	addresses, err = repo.GetAddresses(ctx)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if expected := []string{"0xa", "0xb", "0xc"}; !reflect.DeepEqual(addresses, expected) {
+		t.Errorf("expected sorted addresses %v, got %v", expected, addresses)
+	}
+}
+
+func testTransactions(t *testing.T, newRepo NewRepository) {
+	ctx := context.Background()
+	repo := newRepo(t)
+
+	if _, err := repo.GetTransactions(ctx, "0xa"); !errs.IsNotFoundErr(err) {
+		t.Errorf("expected not found error for unknown address, got %v", err)
+	}
+	if err := repo.AddTransaction(ctx, "0xa", transaction("hash1", 1)); !errs.IsNotFoundErr(err) {
+		t.Errorf("expected not found error for unknown address, got %v", err)
+	}
+
+	_ = repo.AddAddress(ctx, "0xa")
+	_ = repo.AddAddress(ctx, "0xb")
+	transactions, err := repo.GetTransactions(ctx, "0xa")
+	if err != nil || transactions == nil || len(transactions) != 0 {
+		t.Errorf("expected empty transactions, got %v, %v", transactions, err)
+	}
+
+	expected := transaction("hash1", 1)
+	if err := repo.AddTransaction(ctx, "0xa", expected); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	transactions, _ = repo.GetTransactions(ctx, "0xa")
+	if len(transactions) != 1 || !reflect.DeepEqual(transactions[0], expected) {
+		t.Errorf("expected transaction %v, got %v", expected, transactions)
+	}
+	if transactions, _ := repo.GetTransactions(ctx, "0xb"); len(transactions) != 0 {
+		t.Errorf("expected transactions of other addresses to be unaffected, got %v", transactions)
+	}
+
+	// returned slices must not share memory with the repository
+	transactions[0].Hash = "modified"
+	if transactions, _ := repo.GetTransactions(ctx, "0xa"); transactions[0].Hash != "hash1" {
+		t.Errorf("expected stored transaction to be unaffected by modifications of the result, got %v", transactions)
+	}
+}
+
+func testTransactionDetails(t *testing.T, newRepo NewRepository) {
+	ctx := context.Background()
+	repo := newRepo(t)
+	_ = repo.AddAddress(ctx, "0xa")
+
+	expected := domain.Transaction{
+		Hash:                 "hash1",
+		From:                 "0xfrom",
+		To:                   "0xa",
+		Value:                big.NewInt(1),
+		BlockNumber:          1,
+		BlockHash:            "0xblockhash",
+		TransactionIndex:     2,
+		Type:                 3,
+		ChainID:              1,
+		Nonce:                7,
+		Gas:                  21000,
+		GasPrice:             big.NewInt(1_000_000_000),
+		MaxFeePerGas:         big.NewInt(2_000_000_000),
+		MaxPriorityFeePerGas: big.NewInt(1_000_000_000),
+		AccessList: []domain.AccessTuple{
+			{Address: "0xcontract", StorageKeys: []string{"0xkey1", "0xkey2"}},
+		},
+		MaxFeePerBlobGas:    big.NewInt(1),
+		BlobVersionedHashes: []string{"0x01hash"},
+		Input:               "0xa9059cbb",
+		V:                   "0x1",
+		R:                   "0xr",
+		S:                   "0xs",
+		YParity:             "0x1",
+		Receipt: &domain.Receipt{
+			TransactionHash:   "hash1",
+			Status:            domain.ReceiptStatusSuccessful,
+			GasUsed:           21000,
+			CumulativeGasUsed: 42000,
+			EffectiveGasPrice: big.NewInt(1_500_000_000),
+			Logs: []domain.Log{
+				{Address: "0xcontract", Topics: []string{"0xtopic0", "0xtopic1"}, Data: "0xdata", LogIndex: 3},
+			},
+		},
+	}
+	if err := repo.AddTransaction(ctx, "0xa", expected); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	transactions, err := repo.GetTransactions(ctx, "0xa")
+	if err != nil || len(transactions) != 1 || !reflect.DeepEqual(transactions[0], expected) {
+		t.Errorf("expected transaction %+v, got %+v, %v", expected, transactions, err)
+	}
+	page, err := repo.QueryTransactions(ctx, domain.TransactionQuery{Address: "0xa"})
+	if err != nil || len(page.Transactions) != 1 || !reflect.DeepEqual(page.Transactions[0], expected) {
+		t.Errorf("expected queried transaction %+v, got %+v, %v", expected, page.Transactions, err)
+	}
+}
+
+func testDuplicateTransactions(t *testing.T, newRepo NewRepository) {
+	ctx := context.Background()
+	repo := newRepo(t)
+	_ = repo.AddAddress(ctx, "0xa")
+	_ = repo.AddAddress(ctx, "0xb")
+
+	// a transaction is stored once per address, so that it can be used as pagination key
+	for _, address := range []string{"0xa", "0xa", "0xb"} {
+		if err := repo.AddTransaction(ctx, address, transaction("hash1", 1)); err != nil {
+			t.Fatalf("expected no error adding transaction to %s, got %v", address, err)
+		}
+	}
+	if transactions, _ := repo.GetTransactions(ctx, "0xa"); !reflect.DeepEqual(hashes(transactions), []string{"hash1"}) {
+		t.Errorf("expected duplicate transaction to be skipped, got %v", hashes(transactions))
+	}
+	if transactions, _ := repo.GetTransactions(ctx, "0xb"); !reflect.DeepEqual(hashes(transactions), []string{"hash1"}) {
+		t.Errorf("expected transaction of other address, got %v", hashes(transactions))
+	}
+
+	// the kept transactions of a subscribed again address are not duplicated
+	_ = repo.RemoveAddress(ctx, "0xa", false)
+	_ = repo.AddAddress(ctx, "0xa")
+	if err := repo.AddTransaction(ctx, "0xa", transaction("hash1", 1)); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// duplicates of committed and buffered transactions are skipped in transactions
+	tx, _ := repo.NewTransaction(ctx)
+	for _, hash := range []string{"hash1", "hash2", "hash2"} {
+		if err := tx.AddTransaction(ctx, "0xa", transaction(hash, 2)); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+	if transactions, _ := tx.GetTransactions(ctx, "0xa"); !reflect.DeepEqual(hashes(transactions), []string{"hash1", "hash2"}) {
+		t.Errorf("expected transactions without duplicates in transaction, got %v", hashes(transactions))
+	}
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// transactions of removed blocks can be added again
+	tx, _ = repo.NewTransaction(ctx)
+	_ = tx.RemoveBlocks(ctx, 2)
+	if err := tx.AddTransaction(ctx, "0xa", transaction("hash2", 2)); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	expected := []string{"hash1", "hash2"}
+	if transactions, _ := repo.GetTransactions(ctx, "0xa"); !reflect.DeepEqual(hashes(transactions), expected) {
+		t.Errorf("expected transactions %v, got %v", expected, hashes(transactions))
+	}
+	var paged []string
+	query := domain.TransactionQuery{Address: "0xa", Limit: 1}
+	for {
+		page, err := repo.QueryTransactions(ctx, query)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		paged = append(paged, hashes(page.Transactions)...)
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+	if !reflect.DeepEqual(paged, expected) {
+		t.Errorf("expected paged transactions %v, got %v", expected, paged)
+	}
+}
+
+func testRemoveAddress(t *testing.T, newRepo NewRepository) {
+	ctx := context.Background()
+	repo := newRepo(t)
+
+	if err := repo.RemoveAddress(ctx, "0xa", false); !errs.IsNotFoundErr(err) {
+		t.Errorf("expected not found error for unknown address, got %v", err)
+	}
+
+	for _, address := range []string{"0xa", "0xb", "0xc"} {
+		_ = repo.AddAddress(ctx, address)
+		_ = repo.AddTransaction(ctx, address, transaction("hash-"+address, 1))
+	}
+
+	// unsubscribed addresses keep their transactions unless purged
+	if err := repo.RemoveAddress(ctx, "0xa", false); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := repo.RemoveAddress(ctx, "0xb", true); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if addresses, _ := repo.GetAddresses(ctx); !reflect.DeepEqual(addresses, []string{"0xc"}) {
+		t.Errorf("expected addresses [0xc], got %v", addresses)
+	}
+	if transactions, err := repo.GetTransactions(ctx, "0xa"); err != nil || len(transactions) != 1 {
+		t.Errorf("expected kept transaction, got %v, %v", transactions, err)
+	}
+	if _, err := repo.GetTransactions(ctx, "0xb"); !errs.IsNotFoundErr(err) {
+		t.Errorf("expected not found error for purged address, got %v", err)
+	}
+	if err := repo.AddTransaction(ctx, "0xa", transaction("hash2", 2)); !errs.IsNotFoundErr(err) {
+		t.Errorf("expected not found error for unsubscribed address, got %v", err)
+	}
+	if err := repo.RemoveAddress(ctx, "0xa", false); !errs.IsNotFoundErr(err) {
+		t.Errorf("expected not found error for unsubscribed address, got %v", err)
+	}
+	if err := repo.RemoveAddress(ctx, "0xb", true); !errs.IsNotFoundErr(err) {
+		t.Errorf("expected not found error for purged address, got %v", err)
+	}
+
+	// subscribing again continues the kept transactions
+	if err := repo.AddAddress(ctx, "0xa"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := repo.AddAddress(ctx, "0xb"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	_ = repo.AddTransaction(ctx, "0xa", transaction("hash2", 2))
+	if transactions, _ := repo.GetTransactions(ctx, "0xa"); !reflect.DeepEqual(hashes(transactions), []string{"hash-0xa", "hash2"}) {
+		t.Errorf("expected kept and new transactions, got %v", hashes(transactions))
+	}
+	if transactions, _ := repo.GetTransactions(ctx, "0xb"); len(transactions) != 0 {
+		t.Errorf("expected no transactions of purged address, got %v", transactions)
+	}
+
+	// kept transactions can be purged later on
+	_ = repo.RemoveAddress(ctx, "0xc", false)
+	if err := repo.RemoveAddress(ctx, "0xc", true); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if _, err := repo.GetTransactions(ctx, "0xc"); !errs.IsNotFoundErr(err) {
+		t.Errorf("expected not found error for purged address, got %v", err)
+	}
+}
+
+func testRemoveAddressInTransaction(t *testing.T, newRepo NewRepository) {
+	ctx := context.Background()
+	repo := newRepo(t)
+	for _, address := range []string{"0xa", "0xb"} {
+		_ = repo.AddAddress(ctx, address)
+		_ = repo.AddTransaction(ctx, address, transaction("hash-"+address, 1))
+	}
+
+	tx, err := repo.NewTransaction(ctx)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := tx.RemoveAddress(ctx, "0xa", false); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := tx.RemoveAddress(ctx, "0xb", true); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if addresses, _ := tx.GetAddresses(ctx); len(addresses) != 0 {
+		t.Errorf("expected no addresses in transaction, got %v", addresses)
+	}
+	if err := tx.AddTransaction(ctx, "0xa", transaction("hash2", 2)); !errs.IsNotFoundErr(err) {
+		t.Errorf("expected not found error for unsubscribed address, got %v", err)
+	}
+	if transactions, err := tx.GetTransactions(ctx, "0xa"); err != nil || len(transactions) != 1 {
+		t.Errorf("expected kept transaction in transaction, got %v, %v", transactions, err)
+	}
+	if _, err := tx.GetTransactions(ctx, "0xb"); !errs.IsNotFoundErr(err) {
+		t.Errorf("expected not found error for purged address in transaction, got %v", err)
+	}
+
+	// subscribing again within the transaction does not bring back purged transactions
+	if err := tx.AddAddress(ctx, "0xb"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if transactions, err := tx.GetTransactions(ctx, "0xb"); err != nil || len(transactions) != 0 {
+		t.Errorf("expected no transactions, got %v, %v", transactions, err)
+	}
+
+	// the removal is not visible before commit
+	if addresses, _ := repo.GetAddresses(ctx); !reflect.DeepEqual(addresses, []string{"0xa", "0xb"}) {
+		t.Errorf("expected addresses [0xa 0xb] before commit, got %v", addresses)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if addresses, _ := repo.GetAddresses(ctx); !reflect.DeepEqual(addresses, []string{"0xb"}) {
+		t.Errorf("expected addresses [0xb], got %v", addresses)
+	}
+	if transactions, _ := repo.GetTransactions(ctx, "0xa"); len(transactions) != 1 {
+		t.Errorf("expected kept transaction, got %v", transactions)
+	}
+	if transactions, _ := repo.GetTransactions(ctx, "0xb"); len(transactions) != 0 {
+		t.Errorf("expected no transactions, got %v", transactions)
+	}
+}
+
+func testSubscriptions(t *testing.T, newRepo NewRepository) {
+	ctx := context.Background()
+	repo := newRepo(t)
+
+	subscriptions, err := repo.GetSubscriptions(ctx)
+	if err != nil || subscriptions == nil || len(subscriptions) != 0 {
+		t.Errorf("expected no subscriptions, got %v, %v", subscriptions, err)
+	}
+	if err := repo.SetAddressLabel(ctx, "0xa", "label"); !errs.IsNotFoundErr(err) {
+		t.Errorf("expected not found error for unknown address, got %v", err)
+	}
+
+	for _, address := range []string{"0xb", "0xa", "0xc"} {
+		_ = repo.AddAddress(ctx, address)
+	}
+	if err := repo.SetAddressLabel(ctx, "0xa", "exchange"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	_ = repo.SetAddressLabel(ctx, "0xb", "treasury")
+	_ = repo.SetAddressLabel(ctx, "0xc", "cold wallet")
+	_ = repo.SetAddressLabel(ctx, "0xc", "")
+
+	expected := []domain.Subscription{{Address: "0xa", Label: "exchange"}, {Address: "0xb", Label: "treasury"}, {Address: "0xc"}}
+	if subscriptions, _ := repo.GetSubscriptions(ctx); !reflect.DeepEqual(subscriptions, expected) {
+		t.Errorf("expected subscriptions %v, got %v", expected, subscriptions)
+	}
+
+	// labels are removed with the address
+	_ = repo.RemoveAddress(ctx, "0xa", false)
+	if err := repo.SetAddressLabel(ctx, "0xa", "exchange"); !errs.IsNotFoundErr(err) {
+		t.Errorf("expected not found error for unsubscribed address, got %v", err)
+	}
+	_ = repo.AddAddress(ctx, "0xa")
+	expected[0].Label = ""
+	if subscriptions, _ := repo.GetSubscriptions(ctx); !reflect.DeepEqual(subscriptions, expected) {
+		t.Errorf("expected subscriptions %v, got %v", expected, subscriptions)
+	}
+}
+
+func testSubscriptionsInTransaction(t *testing.T, newRepo NewRepository) {
+	ctx := context.Background()
+	repo := newRepo(t)
+	_ = repo.AddAddress(ctx, "0xa")
+	_ = repo.SetAddressLabel(ctx, "0xa", "committed")
+	_ = repo.AddAddress(ctx, "0xb")
+	_ = repo.SetAddressLabel(ctx, "0xb", "removed")
+
+	tx, err := repo.NewTransaction(ctx)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	_ = tx.AddAddress(ctx, "0xc")
+	if err := tx.SetAddressLabel(ctx, "0xc", "added"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	_ = tx.RemoveAddress(ctx, "0xb", false)
+	_ = tx.AddAddress(ctx, "0xb")
+
+	expected := []domain.Subscription{{Address: "0xa", Label: "committed"}, {Address: "0xb"}, {Address: "0xc", Label: "added"}}
+	if subscriptions, _ := tx.GetSubscriptions(ctx); !reflect.DeepEqual(subscriptions, expected) {
+		t.Errorf("expected subscriptions %v in transaction, got %v", expected, subscriptions)
+	}
+	if subscriptions, _ := repo.GetSubscriptions(ctx); len(subscriptions) != 2 || subscriptions[1].Label != "removed" {
+		t.Errorf("expected committed subscriptions before commit, got %v", subscriptions)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if subscriptions, _ := repo.GetSubscriptions(ctx); !reflect.DeepEqual(subscriptions, expected) {
+		t.Errorf("expected subscriptions %v, got %v", expected, subscriptions)
+	}
+}
+
+func testTransactionOrder(t *testing.T, newRepo NewRepository) {
+	ctx := context.Background()
+	repo := newRepo(t)
+	_ = repo.AddAddress(ctx, "0xa")
+
+	// transactions are returned in insertion order, regardless of their hashes and blocks
+	expected := []string{"hash3", "hash1", "hash2", "hash5", "hash4"}
+	for i, hash := range expected {
+		if err := repo.AddTransaction(ctx, "0xa", transaction(hash, 10-i)); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+	transactions, _ := repo.GetTransactions(ctx, "0xa")
+	if actual := hashes(transactions); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected transactions %v, got %v", expected, actual)
+	}
+}
+
+func testQueryFilters(t *testing.T, newRepo NewRepository) {
+	ctx := context.Background()
+	repo := newRepo(t)
+
+	const address, other = "0xaa", "0xbb"
+	if _, err := repo.QueryTransactions(ctx, domain.TransactionQuery{Address: address}); !errs.IsNotFoundErr(err) {
+		t.Errorf("expected not found error for unknown address, got %v", err)
+	}
+
+	_ = repo.AddAddress(ctx, address)
+	for _, tx := range []domain.Transaction{
+		{Hash: "in1", From: other, To: address, Value: big.NewInt(0), BlockNumber: 1},
+		{Hash: "out2", From: address, To: "0xcc", Value: big.NewInt(1_000_000_000_000_000_000), BlockNumber: 2},
+		{Hash: "in3", From: "0xCC", To: "0xAA", Value: big.NewInt(0x10), BlockNumber: 3},
+		{Hash: "out4", From: address, To: other, Value: big.NewInt(0x100), BlockNumber: 4},
+	} {
+		if err := repo.AddTransaction(ctx, address, tx); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+
+	block := func(n int) *int { return &n }
+	tests := []struct {
+		name     string
+		query    domain.TransactionQuery
+		expected []string
+	}{
+		{name: "NoFilter", query: domain.TransactionQuery{}, expected: []string{"in1", "out2", "in3", "out4"}},
+		{name: "FromBlock", query: domain.TransactionQuery{FromBlock: block(3)}, expected: []string{"in3", "out4"}},
+		{name: "ToBlock", query: domain.TransactionQuery{ToBlock: block(2)}, expected: []string{"in1", "out2"}},
+		{name: "BlockRange", query: domain.TransactionQuery{FromBlock: block(2), ToBlock: block(3)}, expected: []string{"out2", "in3"}},
+		{name: "DirectionIn", query: domain.TransactionQuery{Direction: domain.DirectionIn}, expected: []string{"in1", "in3"}},
+		{name: "DirectionOut", query: domain.TransactionQuery{Direction: domain.DirectionOut}, expected: []string{"out2", "out4"}},
+		{name: "Counterparty", query: domain.TransactionQuery{Counterparty: "0xcc"}, expected: []string{"out2", "in3"}},
+		{name: "CounterpartyIn", query: domain.TransactionQuery{Direction: domain.DirectionIn, Counterparty: other}, expected: []string{"in1"}},
+		{name: "CounterpartyOut", query: domain.TransactionQuery{Direction: domain.DirectionOut, Counterparty: other}, expected: []string{"out4"}},
+		{name: "MinValue", query: domain.TransactionQuery{MinValue: big.NewInt(0x100)}, expected: []string{"out2", "out4"}},
+		{name: "Descending", query: domain.TransactionQuery{Order: domain.SortDescending}, expected: []string{"out4", "in3", "out2", "in1"}},
+		{name: "Combined", query: domain.TransactionQuery{Direction: domain.DirectionOut, MinValue: big.NewInt(1), ToBlock: block(3)}, expected: []string{"out2"}},
+		{name: "NoMatch", query: domain.TransactionQuery{FromBlock: block(5)}, expected: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.query.Address = address
+			page, err := repo.QueryTransactions(ctx, tt.query)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if got := hashes(page.Transactions); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected transactions %v, got %v", tt.expected, got)
+			}
+			if page.NextCursor != "" {
+				t.Errorf("expected no next cursor, got %q", page.NextCursor)
+			}
+		})
+	}
+}
+
+func testQueryPagination(t *testing.T, newRepo NewRepository) {
+	ctx := context.Background()
+	repo := newRepo(t)
+
+	_ = repo.AddAddress(ctx, "0xa")
+	// added out of order, with several transactions in the same block
+	for _, tx := range []domain.Transaction{
+		transaction("hash3b", 3), transaction("hash1", 1), transaction("hash3a", 3),
+		transaction("hash2", 2), transaction("hash4", 4),
+	} {
+		if err := repo.AddTransaction(ctx, "0xa", tx); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		order    domain.SortOrder
+		expected []string
+	}{
+		{name: "Ascending", order: domain.SortAscending, expected: []string{"hash1", "hash2", "hash3a", "hash3b", "hash4"}},
+		{name: "Descending", order: domain.SortDescending, expected: []string{"hash4", "hash3b", "hash3a", "hash2", "hash1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			query := domain.TransactionQuery{Address: "0xa", Order: tt.order, Limit: 2}
+			for pages := 1; ; pages++ {
+				page, err := repo.QueryTransactions(ctx, query)
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				if len(page.Transactions) > query.Limit {
+					t.Fatalf("expected at most %d transactions, got %d", query.Limit, len(page.Transactions))
+				}
+				got = append(got, hashes(page.Transactions)...)
+				if page.NextCursor == "" {
+					if pages != 3 {
+						t.Errorf("expected 3 pages, got %d", pages)
+					}
+					break
+				}
+				if pages == 3 {
+					t.Fatalf("expected no next cursor on the last page")
+				}
+				query.Cursor = page.NextCursor
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected transactions %v, got %v", tt.expected, got)
+			}
+		})
+	}
+
+	// a cursor stays valid when transactions are added after it
+	page, _ := repo.QueryTransactions(ctx, domain.TransactionQuery{Address: "0xa", Limit: 2})
+	_ = repo.AddTransaction(ctx, "0xa", transaction("hash0", 0))
+	_ = repo.AddTransaction(ctx, "0xa", transaction("hash5", 5))
+	page, err := repo.QueryTransactions(ctx, domain.TransactionQuery{Address: "0xa", Limit: 2, Cursor: page.NextCursor})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if expected := []string{"hash3a", "hash3b"}; !reflect.DeepEqual(hashes(page.Transactions), expected) {
+		t.Errorf("expected transactions %v, got %v", expected, hashes(page.Transactions))
+	}
+
+	if _, err := repo.QueryTransactions(ctx, domain.TransactionQuery{Address: "0xa", Cursor: "not a cursor"}); !errs.IsInvalidCursorErr(err) {
+		t.Errorf("expected invalid cursor error, got %v", err)
+	}
+
+	// queries of a transaction see its uncommitted writes
+	tx, err := repo.NewTransaction(ctx)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	_ = tx.AddTransaction(ctx, "0xa", transaction("hash6", 6))
+	page, err = tx.QueryTransactions(ctx, domain.TransactionQuery{Address: "0xa", Order: domain.SortDescending, Limit: 1})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if expected := []string{"hash6"}; !reflect.DeepEqual(hashes(page.Transactions), expected) {
+		t.Errorf("expected transactions %v, got %v", expected, hashes(page.Transactions))
+	}
+}
+
+func testBlockNumber(t *testing.T, newRepo NewRepository) {
+	ctx := context.Background()
+	repo := newRepo(t)
+
+	if blockNumber, err := repo.GetBlockNumber(ctx); err != nil || blockNumber != 0 {
+		t.Errorf("expected block number 0, got %d, %v", blockNumber, err)
+	}
+	for _, expected := range []int{5, 3} {
+		if err := repo.SetBlockNumber(ctx, expected); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if blockNumber, _ := repo.GetBlockNumber(ctx); blockNumber != expected {
+			t.Errorf("expected block number %d, got %d", expected, blockNumber)
+		}
+	}
+}
+
+func testBlockHashes(t *testing.T, newRepo NewRepository) {
+	ctx := context.Background()
+	repo := newRepo(t)
+
+	if _, err := repo.GetBlockHash(ctx, 1); !errs.IsNotFoundErr(err) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+	for block := 1; block <= 5; block++ {
+		_ = repo.SetBlockHash(ctx, block, fmt.Sprintf("block%d", block))
+	}
+	_ = repo.SetBlockHash(ctx, 5, "replaced")
+	if hash, _ := repo.GetBlockHash(ctx, 5); hash != "replaced" {
+		t.Errorf("expected replaced block hash, got %q", hash)
+	}
+
+	if err := repo.PruneBlockHashes(ctx, 3); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	for block := 1; block <= 4; block++ {
+		hash, err := repo.GetBlockHash(ctx, block)
+		if block < 3 && !errs.IsNotFoundErr(err) {
+			t.Errorf("expected hash of block %d to be pruned, got %q, %v", block, hash, err)
+		}
+		if block >= 3 && hash != fmt.Sprintf("block%d", block) {
+			t.Errorf("expected hash of block %d to be kept, got %q, %v", block, hash, err)
+		}
+	}
+}
+
+func testRemoveBlocks(t *testing.T, newRepo NewRepository) {
+	ctx := context.Background()
+	repo := newRepo(t)
+	_ = repo.AddAddress(ctx, "0xa")
+	_ = repo.AddAddress(ctx, "0xb")
+	for block := 1; block <= 4; block++ {
+		_ = repo.SetBlockHash(ctx, block, fmt.Sprintf("block%d", block))
+		_ = repo.AddTransaction(ctx, "0xa", transaction(fmt.Sprintf("a%d", block), block))
+		_ = repo.AddTransaction(ctx, "0xb", transaction(fmt.Sprintf("b%d", block), block))
+	}
+
+	if err := repo.RemoveBlocks(ctx, 3); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	for address, expected := range map[string][]string{"0xa": {"a1", "a2"}, "0xb": {"b1", "b2"}} {
+		transactions, _ := repo.GetTransactions(ctx, address)
+		if actual := hashes(transactions); !reflect.DeepEqual(actual, expected) {
+			t.Errorf("expected transactions %v of %s, got %v", expected, address, actual)
+		}
+	}
+	if _, err := repo.GetBlockHash(ctx, 3); !errs.IsNotFoundErr(err) {
+		t.Errorf("expected hash of block 3 to be removed, got %v", err)
+	}
+	if hash, _ := repo.GetBlockHash(ctx, 2); hash != "block2" {
+		t.Errorf("expected hash of block 2 to be kept, got %q", hash)
+	}
+}
+
+func testCommit(t *testing.T, newRepo NewRepository) {
+	ctx := context.Background()
+	repo := newRepo(t)
+	_ = repo.AddAddress(ctx, "0xa")
+
+	repoTx, err := repo.NewTransaction(ctx)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	_ = repoTx.AddAddress(ctx, "0xb")
+	_ = repoTx.AddTransaction(ctx, "0xa", transaction("hash1", 1))
+	_ = repoTx.AddTransaction(ctx, "0xb", transaction("hash2", 1))
+	_ = repoTx.SetBlockHash(ctx, 1, "block1")
+	_ = repoTx.SetBlockNumber(ctx, 1)
+
+	// the transaction reads its own writes
+	if blockNumber, _ := repoTx.GetBlockNumber(ctx); blockNumber != 1 {
+		t.Errorf("expected block number 1 within transaction, got %d", blockNumber)
+	}
+	if transactions, _ := repoTx.GetTransactions(ctx, "0xb"); len(transactions) != 1 {
+		t.Errorf("expected transaction of added address within transaction, got %v", transactions)
+	}
+	if hash, _ := repoTx.GetBlockHash(ctx, 1); hash != "block1" {
+		t.Errorf("expected block hash within transaction, got %q", hash)
+	}
+
+	// others do not observe uncommitted writes
+	if blockNumber, _ := repo.GetBlockNumber(ctx); blockNumber != 0 {
+		t.Errorf("expected uncommitted block number to be invisible, got %d", blockNumber)
+	}
+	if transactions, _ := repo.GetTransactions(ctx, "0xa"); len(transactions) != 0 {
+		t.Errorf("expected uncommitted transaction to be invisible, got %v", transactions)
+	}
+	if _, err := repo.GetTransactions(ctx, "0xb"); !errs.IsNotFoundErr(err) {
+		t.Errorf("expected uncommitted address to be invisible, got %v", err)
+	}
+	if _, err := repo.GetBlockHash(ctx, 1); !errs.IsNotFoundErr(err) {
+		t.Errorf("expected uncommitted block hash to be invisible, got %v", err)
+	}
+
+	if err := repoTx.Commit(ctx); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if blockNumber, _ := repo.GetBlockNumber(ctx); blockNumber != 1 {
+		t.Errorf("expected committed block number 1, got %d", blockNumber)
+	}
+	if addresses, _ := repo.GetAddresses(ctx); !reflect.DeepEqual(addresses, []string{"0xa", "0xb"}) {
+		t.Errorf("expected committed address, got %v", addresses)
+	}
+	for _, address := range []string{"0xa", "0xb"} {
+		if transactions, _ := repo.GetTransactions(ctx, address); len(transactions) != 1 {
+			t.Errorf("expected committed transaction of %s, got %v", address, transactions)
+		}
+	}
+	if hash, _ := repo.GetBlockHash(ctx, 1); hash != "block1" {
+		t.Errorf("expected committed block hash, got %q", hash)
+	}
+}
+
+func testRollback(t *testing.T, newRepo NewRepository) {
+	ctx := context.Background()
+	repo := newRepo(t)
+	_ = repo.AddAddress(ctx, "0xa")
+	_ = repo.AddTransaction(ctx, "0xa", transaction("hash1", 1))
+	_ = repo.SetBlockHash(ctx, 1, "block1")
+	_ = repo.SetBlockNumber(ctx, 1)
+
+	repoTx, _ := repo.NewTransaction(ctx)
+	_ = repoTx.RemoveBlocks(ctx, 1)
+	_ = repoTx.AddAddress(ctx, "0xb")
+	_ = repoTx.AddTransaction(ctx, "0xa", transaction("hash2", 2))
+	_ = repoTx.SetBlockNumber(ctx, 2)
+
+	if transactions, _ := repoTx.GetTransactions(ctx, "0xa"); !reflect.DeepEqual(hashes(transactions), []string{"hash2"}) {
+		t.Errorf("expected removed transactions to be replaced within transaction, got %v", transactions)
+	}
+
+	if err := repoTx.Rollback(ctx); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if blockNumber, _ := repo.GetBlockNumber(ctx); blockNumber != 1 {
+		t.Errorf("expected block number 1 after rollback, got %d", blockNumber)
+	}
+	if transactions, _ := repo.GetTransactions(ctx, "0xa"); !reflect.DeepEqual(hashes(transactions), []string{"hash1"}) {
+		t.Errorf("expected transactions to be unchanged after rollback, got %v", transactions)
+	}
+	if hash, _ := repo.GetBlockHash(ctx, 1); hash != "block1" {
+		t.Errorf("expected block hash to be kept after rollback, got %q", hash)
+	}
+	if _, err := repo.GetTransactions(ctx, "0xb"); !errs.IsNotFoundErr(err) {
+		t.Errorf("expected address to be discarded after rollback, got %v", err)
+	}
+}
+
+func testTransactionDone(t *testing.T, newRepo NewRepository) {
+	ctx := context.Background()
+	repo := newRepo(t)
+	_ = repo.AddAddress(ctx, "0xa")
+
+	tests := []struct {
+		name     string
+		complete func(repoTx repositories.Transaction) error
+	}{
+		{
+			name:     "AfterCommit",
+			complete: func(repoTx repositories.Transaction) error { return repoTx.Commit(ctx) },
+		},
+		{
+			name:     "AfterRollback",
+			complete: func(repoTx repositories.Transaction) error { return repoTx.Rollback(ctx) },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repoTx, _ := repo.NewTransaction(ctx)
+			if err := tt.complete(repoTx); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			if err := repoTx.AddTransaction(ctx, "0xa", transaction("hash1", 1)); !errs.IsTransactionDoneErr(err) {
+				t.Errorf("expected transaction done error, got %v", err)
+			}
+			if err := repoTx.SetBlockNumber(ctx, 1); !errs.IsTransactionDoneErr(err) {
+				t.Errorf("expected transaction done error, got %v", err)
+			}
+			if _, err := repoTx.GetBlockNumber(ctx); !errs.IsTransactionDoneErr(err) {
+				t.Errorf("expected transaction done error, got %v", err)
+			}
+			if err := repoTx.Commit(ctx); !errs.IsTransactionDoneErr(err) {
+				t.Errorf("expected transaction done error, got %v", err)
+			}
+			if err := repoTx.Rollback(ctx); !errs.IsTransactionDoneErr(err) {
+				t.Errorf("expected transaction done error, got %v", err)
+			}
+			if transactions, _ := repo.GetTransactions(ctx, "0xa"); len(transactions) != 0 {
+				t.Errorf("expected writes after completion to be discarded, got %v", transactions)
+			}
+		})
+	}
+}
+
+func testConcurrentAddAddress(t *testing.T, newRepo NewRepository) {
+	ctx := context.Background()
+	repo := newRepo(t)
+
+	var wg sync.WaitGroup
+	var added atomic.Int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := repo.AddAddress(ctx, "0xa")
+			if err == nil {
+				added.Add(1)
+			} else if !errs.IsAlreadyExistErr(err) {
+				t.Errorf("expected already exist error, got %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if added.Load() != 1 {
+		t.Errorf("expected the address to be added exactly once, got %d", added.Load())
+	}
+}
+
+func testConcurrentWrites(t *testing.T, newRepo NewRepository) {
+	ctx := context.Background()
+	repo := newRepo(t)
+	_ = repo.AddAddress(ctx, "0xa")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				if err := repo.AddTransaction(ctx, "0xa", transaction(fmt.Sprintf("hash%d-%d", i, j), 1)); err != nil {
+					t.Errorf("expected no error, got %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if transactions, _ := repo.GetTransactions(ctx, "0xa"); len(transactions) != 100 {
+		t.Errorf("expected 100 transactions, got %d", len(transactions))
+	}
+}
+
+func testConcurrentReaders(t *testing.T, newRepo NewRepository) {
+	ctx := context.Background()
+	repo := newRepo(t)
+	_ = repo.AddAddress(ctx, "0xa")
+	_ = repo.AddAddress(ctx, "0xb")
+
+	const blocks = 50
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+
+	// every committed block adds a transaction to both addresses, so readers must never observe
+	// fewer transactions than the block number read before them, nor the second address behind the first one
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				blockNumber, err := repo.GetBlockNumber(ctx)
+				if err != nil {
+					t.Errorf("expected no error, got %v", err)
+					return
+				}
+				first, _ := repo.GetTransactions(ctx, "0xa")
+				second, _ := repo.GetTransactions(ctx, "0xb")
+				if len(first) < blockNumber || len(second) < len(first) {
+					t.Errorf("observed partial commit: block number %d with %d and %d transactions", blockNumber, len(first), len(second))
+					return
+				}
+				for i := range first {
+					if first[i].Hash == "rolled back" {
+						t.Errorf("observed rolled back transaction of block %d", first[i].BlockNumber)
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	for block := 1; block <= blocks; block++ {
+		// a rolled back transaction must never be observed
+		rolledBack, err := repo.NewTransaction(ctx)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		_ = rolledBack.AddTransaction(ctx, "0xa", transaction("rolled back", block))
+		_ = rolledBack.SetBlockNumber(ctx, block+100)
+		if err := rolledBack.Rollback(ctx); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		repoTx, err := repo.NewTransaction(ctx)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		for _, address := range []string{"0xa", "0xb"} {
+			if err := repoTx.AddTransaction(ctx, address, transaction(fmt.Sprintf("%s-%d", address, block), block)); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+		}
+		_ = repoTx.SetBlockNumber(ctx, block)
+		if err := repoTx.Commit(ctx); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	for _, address := range []string{"0xa", "0xb"} {
+		if transactions, _ := repo.GetTransactions(ctx, address); len(transactions) != blocks {
+			t.Errorf("expected %d transactions of %s, got %d", blocks, address, len(transactions))
+		}
+	}
+}
@@ -0,0 +1,431 @@
+package repotest
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math/big"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/aniladanir/ethereum-blockchain-parser/internal/adapters/repositories"
+	"github.com/aniladanir/ethereum-blockchain-parser/internal/core/domain"
+	"github.com/aniladanir/ethereum-blockchain-parser/pkg/errs"
+)
+
+// transferKind adapts a kind of transfer to the transfer tests, which token and nft transfers share
+type transferKind struct {
+	// name names the transfers in test failures
+	name string
+	// add writes the transfer to the given address
+	add func(ctx context.Context, repo repositories.Repository, address string, transfer testTransfer) error
+	// query returns the positions of the page of the transfers that matches the query, and its next cursor.
+	// The positions are nil if the page has no transfer list.
+	query func(ctx context.Context, repo repositories.Repository, query transferQuery) ([]string, string, error)
+}
+
+// testTransfer is a transfer of any kind, with a default contract unless contract is set
+type testTransfer struct {
+	from       string
+	to         string
+	contract   string
+	block      int
+	logIndex   uint64
+	batchIndex uint64
+}
+
+// transferQuery is a query of transfers of any kind
+type transferQuery struct {
+	Address   string
+	Contract  string
+	FromBlock *int
+	ToBlock   *int
+	Direction domain.Direction
+	Order     domain.SortOrder
+	Limit     int
+	Cursor    string
+}
+
+var tokenTransfers = transferKind{
+	name: "token transfers",
+	add: func(ctx context.Context, repo repositories.Repository, address string, transfer testTransfer) error {
+		return repo.AddTokenTransfer(ctx, address, tokenTransfer(transfer))
+	},
+	query: func(ctx context.Context, repo repositories.Repository, query transferQuery) ([]string, string, error) {
+		page, err := repo.QueryTokenTransfers(ctx, domain.TokenTransferQuery{
+			Address:   query.Address,
+			Token:     query.Contract,
+			FromBlock: query.FromBlock,
+			ToBlock:   query.ToBlock,
+			Direction: query.Direction,
+			Order:     query.Order,
+			Limit:     query.Limit,
+			Cursor:    query.Cursor,
+		})
+		return transferPositions(page.TokenTransfers, func(transfer *domain.TokenTransfer) (uint64, uint64, uint64) {
+			return transfer.BlockNumber, transfer.LogIndex, 0
+		}), page.NextCursor, err
+	},
+}
+
+var nftTransfers = transferKind{
+	name: "nft transfers",
+	add: func(ctx context.Context, repo repositories.Repository, address string, transfer testTransfer) error {
+		return repo.AddNFTTransfer(ctx, address, nftTransfer(transfer))
+	},
+	query: func(ctx context.Context, repo repositories.Repository, query transferQuery) ([]string, string, error) {
+		page, err := repo.QueryNFTTransfers(ctx, domain.NFTTransferQuery{
+			Address:   query.Address,
+			Contract:  query.Contract,
+			FromBlock: query.FromBlock,
+			ToBlock:   query.ToBlock,
+			Direction: query.Direction,
+			Order:     query.Order,
+			Limit:     query.Limit,
+			Cursor:    query.Cursor,
+		})
+		return transferPositions(page.NFTTransfers, func(transfer *domain.NFTTransfer) (uint64, uint64, uint64) {
+			return transfer.BlockNumber, transfer.LogIndex, transfer.BatchIndex
+		}), page.NextCursor, err
+	},
+}
+
+func tokenTransfer(transfer testTransfer) domain.TokenTransfer {
+	return domain.TokenTransfer{
+		Token:           cmp.Or(transfer.contract, "0xtoken"),
+		From:            transfer.from,
+		To:              transfer.to,
+		Value:           big.NewInt(1),
+		BlockNumber:     uint64(transfer.block),
+		BlockHash:       fmt.Sprintf("block%d", transfer.block),
+		TransactionHash: fmt.Sprintf("hash%d", transfer.block),
+		LogIndex:        transfer.logIndex,
+	}
+}
+
+func nftTransfer(transfer testTransfer) domain.NFTTransfer {
+	return domain.NFTTransfer{
+		Contract:        cmp.Or(transfer.contract, "0xcontract"),
+		Standard:        domain.NFTStandardERC1155,
+		Operator:        transfer.from,
+		From:            transfer.from,
+		To:              transfer.to,
+		TokenID:         big.NewInt(int64(transfer.batchIndex)),
+		Amount:          big.NewInt(1),
+		BlockNumber:     uint64(transfer.block),
+		BlockHash:       fmt.Sprintf("block%d", transfer.block),
+		TransactionHash: fmt.Sprintf("hash%d", transfer.block),
+		LogIndex:        transfer.logIndex,
+		BatchIndex:      transfer.batchIndex,
+	}
+}
+
+// transferPositions returns the block number, log index and batch index of the transfers as
+// "block/logIndex.batchIndex", or nil if transfers is nil
+func transferPositions[T any](transfers []T, position func(transfer *T) (uint64, uint64, uint64)) []string {
+	if transfers == nil {
+		return nil
+	}
+	result := make([]string, len(transfers))
+	for i := range transfers {
+		block, logIndex, batchIndex := position(&transfers[i])
+		result[i] = fmt.Sprintf("%d/%d.%d", block, logIndex, batchIndex)
+	}
+	return result
+}
+
+func (kind transferKind) testTransfers(t *testing.T, newRepo NewRepository) {
+	ctx := context.Background()
+	repo := newRepo(t)
+
+	if _, _, err := kind.query(ctx, repo, transferQuery{Address: "0xa"}); !errs.IsNotFoundErr(err) {
+		t.Errorf("expected not found error for unknown address, got %v", err)
+	}
+	if err := kind.add(ctx, repo, "0xa", testTransfer{from: "0xa", to: "0xb", block: 1}); !errs.IsNotFoundErr(err) {
+		t.Errorf("expected not found error for unknown address, got %v", err)
+	}
+
+	for _, address := range []string{"0xa", "0xb", "0xc"} {
+		_ = repo.AddAddress(ctx, address)
+	}
+	positions, _, err := kind.query(ctx, repo, transferQuery{Address: "0xa"})
+	if err != nil || positions == nil || len(positions) != 0 {
+		t.Errorf("expected empty %s, got %v, %v", kind.name, positions, err)
+	}
+
+	for _, address := range []string{"0xa", "0xb"} {
+		if err := kind.add(ctx, repo, address, testTransfer{from: "0xa", to: "0xb", block: 1, logIndex: 7}); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+	for block := 2; block <= 3; block++ {
+		_ = kind.add(ctx, repo, "0xa", testTransfer{from: "0xc", to: "0xa", block: block})
+		_ = kind.add(ctx, repo, "0xc", testTransfer{from: "0xc", to: "0xa", block: block})
+	}
+	positions, _, err = kind.query(ctx, repo, transferQuery{Address: "0xb"})
+	if err != nil || !reflect.DeepEqual(positions, []string{"1/7.0"}) {
+		t.Errorf("expected %s [1/7.0], got %v, %v", kind.name, positions, err)
+	}
+
+	// transfers of orphaned blocks are removed
+	if err := repo.RemoveBlocks(ctx, 3); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	for address, expected := range map[string][]string{"0xa": {"1/7.0", "2/0.0"}, "0xc": {"2/0.0"}} {
+		if actual, _, _ := kind.query(ctx, repo, transferQuery{Address: address}); !reflect.DeepEqual(actual, expected) {
+			t.Errorf("expected %s %v of %s, got %v", kind.name, expected, address, actual)
+		}
+	}
+
+	// unsubscribed addresses keep their transfers unless purged
+	_ = repo.RemoveAddress(ctx, "0xa", false)
+	_ = repo.RemoveAddress(ctx, "0xc", true)
+	if positions, _, err := kind.query(ctx, repo, transferQuery{Address: "0xa"}); err != nil || len(positions) != 2 {
+		t.Errorf("expected kept %s, got %v, %v", kind.name, positions, err)
+	}
+	if err := kind.add(ctx, repo, "0xa", testTransfer{from: "0xa", to: "0xb", block: 4}); !errs.IsNotFoundErr(err) {
+		t.Errorf("expected not found error for unsubscribed address, got %v", err)
+	}
+	_ = repo.AddAddress(ctx, "0xc")
+	if positions, _, _ := kind.query(ctx, repo, transferQuery{Address: "0xc"}); len(positions) != 0 {
+		t.Errorf("expected no %s of purged address, got %v", kind.name, positions)
+	}
+
+	// transfers are written and read through transactions
+	tx, err := repo.NewTransaction(ctx)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	_ = kind.add(ctx, tx, "0xb", testTransfer{from: "0xb", to: "0xd", block: 5, logIndex: 1})
+	if positions, _, _ := kind.query(ctx, tx, transferQuery{Address: "0xb"}); len(positions) != 2 {
+		t.Errorf("expected uncommitted transfer to be visible in the transaction, got %v", positions)
+	}
+	if positions, _, _ := kind.query(ctx, repo, transferQuery{Address: "0xb"}); len(positions) != 1 {
+		t.Errorf("expected uncommitted transfer to be invisible outside of the transaction, got %v", positions)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if positions, _, _ := kind.query(ctx, repo, transferQuery{Address: "0xb"}); !reflect.DeepEqual(positions, []string{"1/7.0", "5/1.0"}) {
+		t.Errorf("expected committed %s, got %v", kind.name, positions)
+	}
+}
+
+func (kind transferKind) testTransferQueries(t *testing.T, newRepo NewRepository) {
+	ctx := context.Background()
+	repo := newRepo(t)
+
+	const address = "0xaa"
+	_ = repo.AddAddress(ctx, address)
+	// added out of order, with several transfers in the same block
+	for _, transfer := range []testTransfer{
+		{from: "0xbb", to: address, block: 3, logIndex: 10}, {from: "0xbb", to: address, block: 1},
+		{from: address, to: "0xbb", contract: "0xother", block: 2, logIndex: 3},
+		{from: address, to: "0xcc", block: 3, logIndex: 2}, {from: "0xcc", to: address, block: 4},
+	} {
+		if err := kind.add(ctx, repo, address, transfer); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+
+	block := func(n int) *int { return &n }
+	tests := []struct {
+		name     string
+		query    transferQuery
+		expected []string
+	}{
+		{name: "NoFilter", query: transferQuery{}, expected: []string{"1/0.0", "2/3.0", "3/2.0", "3/10.0", "4/0.0"}},
+		{name: "BlockRange", query: transferQuery{FromBlock: block(2), ToBlock: block(3)}, expected: []string{"2/3.0", "3/2.0", "3/10.0"}},
+		{name: "Contract", query: transferQuery{Contract: "0xOTHER"}, expected: []string{"2/3.0"}},
+		{name: "DirectionIn", query: transferQuery{Direction: domain.DirectionIn}, expected: []string{"1/0.0", "3/10.0", "4/0.0"}},
+		{name: "DirectionOut", query: transferQuery{Direction: domain.DirectionOut}, expected: []string{"2/3.0", "3/2.0"}},
+		{name: "Descending", query: transferQuery{Order: domain.SortDescending}, expected: []string{"4/0.0", "3/10.0", "3/2.0", "2/3.0", "1/0.0"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.query.Address = address
+			got, _, err := kind.query(ctx, repo, tt.query)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected %s %v, got %v", kind.name, tt.expected, got)
+			}
+		})
+	}
+
+	for _, order := range []domain.SortOrder{domain.SortAscending, domain.SortDescending} {
+		t.Run("Pagination"+string(order), func(t *testing.T) {
+			var got []string
+			query := transferQuery{Address: address, Order: order, Limit: 2}
+			for pages := 1; pages <= 3; pages++ {
+				positions, nextCursor, err := kind.query(ctx, repo, query)
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				got = append(got, positions...)
+				if (nextCursor == "") != (pages == 3) {
+					t.Fatalf("expected a next cursor on all but the third page, got %q on page %d", nextCursor, pages)
+				}
+				query.Cursor = nextCursor
+			}
+			expected := []string{"1/0.0", "2/3.0", "3/2.0", "3/10.0", "4/0.0"}
+			if order == domain.SortDescending {
+				slices.Reverse(expected)
+			}
+			if !reflect.DeepEqual(got, expected) {
+				t.Errorf("expected %s %v, got %v", kind.name, expected, got)
+			}
+		})
+	}
+
+	if _, _, err := kind.query(ctx, repo, transferQuery{Address: address, Cursor: "not a cursor"}); !errs.IsInvalidCursorErr(err) {
+		t.Errorf("expected invalid cursor error, got %v", err)
+	}
+}
+
+func (kind transferKind) testDuplicateTransfers(t *testing.T, newRepo NewRepository) {
+	ctx := context.Background()
+	repo := newRepo(t)
+	_ = repo.AddAddress(ctx, "0xa")
+
+	// a transfer is stored once per address, so that it can be used as pagination key
+	for i := 0; i < 2; i++ {
+		for logIndex := uint64(0); logIndex < 2; logIndex++ {
+			if err := kind.add(ctx, repo, "0xa", testTransfer{from: "0xa", to: "0xb", block: 1, logIndex: logIndex}); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+		}
+	}
+
+	// the kept transfers of a subscribed again address are not duplicated
+	_ = repo.RemoveAddress(ctx, "0xa", false)
+	_ = repo.AddAddress(ctx, "0xa")
+	tx, _ := repo.NewTransaction(ctx)
+	for _, logIndex := range []uint64{1, 2, 2} {
+		if err := kind.add(ctx, tx, "0xa", testTransfer{from: "0xa", to: "0xb", block: 1, logIndex: logIndex}); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+	expected := []string{"1/0.0", "1/1.0", "1/2.0"}
+	if positions, _, _ := kind.query(ctx, tx, transferQuery{Address: "0xa"}); !reflect.DeepEqual(positions, expected) {
+		t.Errorf("expected %s %v in transaction, got %v", kind.name, expected, positions)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if paged := kind.queryAll(t, repo, transferQuery{Address: "0xa", Limit: 1}); !reflect.DeepEqual(paged, expected) {
+		t.Errorf("expected paged %s %v, got %v", kind.name, expected, paged)
+	}
+}
+
+// queryAll returns the positions of the transfers of every page of the query
+func (kind transferKind) queryAll(t *testing.T, repo repositories.Repository, query transferQuery) []string {
+	var paged []string
+	for {
+		positions, nextCursor, err := kind.query(context.Background(), repo, query)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		paged = append(paged, positions...)
+		if nextCursor == "" {
+			return paged
+		}
+		query.Cursor = nextCursor
+	}
+}
+
+func testTokenTransferDetails(t *testing.T, newRepo NewRepository) {
+	ctx := context.Background()
+	repo := newRepo(t)
+	_ = repo.AddAddress(ctx, "0xa")
+
+	expected := domain.TokenTransfer{
+		Token:           "0xtoken",
+		From:            "0xa",
+		To:              "0xb",
+		Value:           new(big.Int).Lsh(big.NewInt(1), 255),
+		BlockNumber:     1,
+		BlockHash:       "0xblockhash",
+		TransactionHash: "0xhash",
+		LogIndex:        7,
+	}
+	if err := repo.AddTokenTransfer(ctx, "0xa", expected); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	page, err := repo.QueryTokenTransfers(ctx, domain.TokenTransferQuery{Address: "0xa"})
+	if err != nil || len(page.TokenTransfers) != 1 || !reflect.DeepEqual(page.TokenTransfers[0], expected) {
+		t.Errorf("expected token transfer %+v, got %+v, %v", expected, page.TokenTransfers, err)
+	}
+}
+
+func testNFTTransferDetails(t *testing.T, newRepo NewRepository) {
+	ctx := context.Background()
+	repo := newRepo(t)
+	_ = repo.AddAddress(ctx, "0xa")
+
+	expected := []domain.NFTTransfer{
+		{
+			Contract:        "0xkitties",
+			Standard:        domain.NFTStandardERC721,
+			From:            "0xa",
+			To:              "0xb",
+			TokenID:         new(big.Int).Lsh(big.NewInt(1), 255),
+			Amount:          big.NewInt(1),
+			BlockNumber:     1,
+			BlockHash:       "0xblockhash",
+			TransactionHash: "0xhash",
+			LogIndex:        7,
+		},
+		{
+			Contract:        "0xitems",
+			Standard:        domain.NFTStandardERC1155,
+			Operator:        "0xoperator",
+			From:            "0xa",
+			To:              "0xb",
+			TokenID:         big.NewInt(3),
+			Amount:          new(big.Int).Lsh(big.NewInt(1), 128),
+			BlockNumber:     1,
+			BlockHash:       "0xblockhash",
+			TransactionHash: "0xhash",
+			LogIndex:        8,
+			BatchIndex:      2,
+		},
+	}
+	for _, transfer := range expected {
+		if err := repo.AddNFTTransfer(ctx, "0xa", transfer); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+	page, err := repo.QueryNFTTransfers(ctx, domain.NFTTransferQuery{Address: "0xa"})
+	if err != nil || !reflect.DeepEqual(page.NFTTransfers, expected) {
+		t.Errorf("expected nft transfers %+v, got %+v, %v", expected, page.NFTTransfers, err)
+	}
+}
+
+func testNFTTransferBatches(t *testing.T, newRepo NewRepository) {
+	ctx := context.Background()
+	repo := newRepo(t)
+	_ = repo.AddAddress(ctx, "0xa")
+
+	// the transfers of a batch are ordered, paged and stored once by their batch index
+	for _, batchIndex := range []uint64{2, 0, 1, 0} {
+		if err := nftTransfers.add(ctx, repo, "0xa", testTransfer{from: "0xa", to: "0xb", block: 1, batchIndex: batchIndex}); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+	_ = nftTransfers.add(ctx, repo, "0xa", testTransfer{from: "0xa", to: "0xb", block: 1, logIndex: 1})
+
+	expected := []string{"1/0.0", "1/0.1", "1/0.2", "1/1.0"}
+	for _, order := range []domain.SortOrder{domain.SortAscending, domain.SortDescending} {
+		paged := nftTransfers.queryAll(t, repo, transferQuery{Address: "0xa", Order: order, Limit: 1})
+		if order == domain.SortDescending {
+			slices.Reverse(paged)
+		}
+		if !reflect.DeepEqual(paged, expected) {
+			t.Errorf("expected %s paged nft transfers %v, got %v", order, expected, paged)
+		}
+	}
+}
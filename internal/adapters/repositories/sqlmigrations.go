@@ -0,0 +1,185 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// migration upgrades the schema by one version. Migrations are never changed once released,
+// schema changes are made by appending new migrations.
+type migration func(d dialect) []string
+
+var migrations = []migration{
+	// 1: initial schema
+	func(d dialect) []string {
+		return []string{
+			`CREATE TABLE addresses (
+				address TEXT PRIMARY KEY
+			)`,
+			fmt.Sprintf(`CREATE TABLE transactions (
+				id %s,
+				address TEXT NOT NULL,
+				hash TEXT NOT NULL,
+				from_address TEXT NOT NULL,
+				to_address TEXT NOT NULL,
+				value TEXT NOT NULL,
+				block_number TEXT NOT NULL,
+				block_height BIGINT
+			)`, d.autoIncrementPrimaryKey()),
+			`CREATE INDEX transactions_address_idx ON transactions (address, id)`,
+			`CREATE INDEX transactions_block_height_idx ON transactions (block_height)`,
+			`CREATE INDEX transactions_hash_idx ON transactions (hash)`,
+			`CREATE TABLE block_hashes (
+				block_number BIGINT PRIMARY KEY,
+				hash TEXT NOT NULL
+			)`,
+			`CREATE TABLE parser_state (
+				name TEXT PRIMARY KEY,
+				value BIGINT NOT NULL
+			)`,
+		}
+	},
+	// 2: transaction queries
+	func(d dialect) []string {
+		backfill := `UPDATE transactions SET value_key = substr('` + strings.Repeat("0", 64) + `' || lower(substr(value, 3)), -64, 64) WHERE value LIKE '0x%'`
+		if d == dialectPostgres {
+			backfill = `UPDATE transactions SET value_key = lpad(lower(substr(value, 3)), 64, '0') WHERE value LIKE '0x%'`
+		}
+		return []string{
+			// value_key is the value as zero padded hex, so that values can be compared as text
+			`ALTER TABLE transactions ADD COLUMN value_key TEXT`,
+			backfill,
+			`CREATE INDEX transactions_address_block_idx ON transactions (address, block_height, hash)`,
+		}
+	},
+	// 3: unsubscribed addresses keeping their transactions
+	func(d dialect) []string {
+		return []string{
+			`ALTER TABLE addresses ADD COLUMN subscribed BOOLEAN NOT NULL DEFAULT TRUE`,
+		}
+	},
+	// 4: address labels
+	func(d dialect) []string {
+		return []string{
+			`ALTER TABLE addresses ADD COLUMN label TEXT NOT NULL DEFAULT ''`,
+		}
+	},
+	// 5: transaction details
+	func(d dialect) []string {
+		columns := []string{
+			"block_hash", "transaction_index", "tx_type", "chain_id", "nonce", "gas", "gas_price",
+			"max_fee_per_gas", "max_priority_fee_per_gas", "access_list", "max_fee_per_blob_gas",
+			"blob_versioned_hashes", "input", "v", "r", "s", "y_parity",
+		}
+		statements := make([]string, 0, len(columns))
+		for _, column := range columns {
+			statements = append(statements, fmt.Sprintf(`ALTER TABLE transactions ADD COLUMN %s TEXT NOT NULL DEFAULT ''`, column))
+		}
+		return statements
+	},
+	// 6: transaction receipts, stored as json with the numbers as json-rpc quantities
+	func(d dialect) []string {
+		return []string{
+			`ALTER TABLE transactions ADD COLUMN receipt TEXT NOT NULL DEFAULT ''`,
+		}
+	},
+	// 7: token transfers, with the value stored as json-rpc quantity
+	func(d dialect) []string {
+		return []string{
+			fmt.Sprintf(`CREATE TABLE token_transfers (
+				id %s,
+				address TEXT NOT NULL,
+				token TEXT NOT NULL,
+				from_address TEXT NOT NULL,
+				to_address TEXT NOT NULL,
+				value TEXT NOT NULL,
+				block_height BIGINT NOT NULL,
+				block_hash TEXT NOT NULL,
+				transaction_hash TEXT NOT NULL,
+				log_index BIGINT NOT NULL
+			)`, d.autoIncrementPrimaryKey()),
+			`CREATE INDEX token_transfers_address_block_idx ON token_transfers (address, block_height, log_index)`,
+			`CREATE INDEX token_transfers_block_height_idx ON token_transfers (block_height)`,
+		}
+	},
+	// 8: nft transfers, with the token id and amount stored as json-rpc quantities
+	func(d dialect) []string {
+		return []string{
+			fmt.Sprintf(`CREATE TABLE nft_transfers (
+				id %s,
+				address TEXT NOT NULL,
+				contract TEXT NOT NULL,
+				standard TEXT NOT NULL,
+				operator TEXT NOT NULL,
+				from_address TEXT NOT NULL,
+				to_address TEXT NOT NULL,
+				token_id TEXT NOT NULL,
+				amount TEXT NOT NULL,
+				block_height BIGINT NOT NULL,
+				block_hash TEXT NOT NULL,
+				transaction_hash TEXT NOT NULL,
+				log_index BIGINT NOT NULL,
+				batch_index BIGINT NOT NULL
+			)`, d.autoIncrementPrimaryKey()),
+			`CREATE INDEX nft_transfers_address_block_idx ON nft_transfers (address, block_height, log_index, batch_index)`,
+			`CREATE INDEX nft_transfers_block_height_idx ON nft_transfers (block_height)`,
+		}
+	},
+	// 9: unique transactions per address, keeping the first of the stored duplicates
+	func(d dialect) []string {
+		return []string{
+			`DELETE FROM transactions WHERE id NOT IN (SELECT MIN(id) FROM transactions GROUP BY address, hash)`,
+			`CREATE UNIQUE INDEX transactions_address_hash_idx ON transactions (address, hash)`,
+		}
+	},
+	// 10: unique token transfers per address, keeping the first of the stored duplicates
+	func(d dialect) []string {
+		return []string{
+			`DELETE FROM token_transfers WHERE id NOT IN (SELECT MIN(id) FROM token_transfers GROUP BY address, transaction_hash, log_index)`,
+			`CREATE UNIQUE INDEX token_transfers_address_log_idx ON token_transfers (address, transaction_hash, log_index)`,
+		}
+	},
+	// 11: unique nft transfers per address, keeping the first of the stored duplicates
+	func(d dialect) []string {
+		return []string{
+			`DELETE FROM nft_transfers WHERE id NOT IN
+				(SELECT MIN(id) FROM nft_transfers GROUP BY address, transaction_hash, log_index, batch_index)`,
+			`CREATE UNIQUE INDEX nft_transfers_address_log_idx ON nft_transfers (address, transaction_hash, log_index, batch_index)`,
+		}
+	},
+}
+
+// migrate applies the migrations newer than the current schema version, each in its own transaction
+func migrate(ctx context.Context, db *sql.DB, d dialect) error {
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`); err != nil {
+		return fmt.Errorf("could not create migrations table: %w", err)
+	}
+
+	var version int
+	if err := db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return fmt.Errorf("could not get schema version: %w", err)
+	}
+
+	for i := version; i < len(migrations); i++ {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("could not begin migration %d: %w", i+1, err)
+		}
+		for _, statement := range migrations[i](d) {
+			if _, err := tx.ExecContext(ctx, statement); err != nil {
+				_ = tx.Rollback()
+				return fmt.Errorf("could not apply migration %d: %w", i+1, err)
+			}
+		}
+		if _, err := tx.ExecContext(ctx, d.rebind(`INSERT INTO schema_migrations (version) VALUES (?)`), i+1); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("could not record migration %d: %w", i+1, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("could not commit migration %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
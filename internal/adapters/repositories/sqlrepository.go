@@ -0,0 +1,846 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/aniladanir/ethereum-blockchain-parser/internal/core/domain"
+	"github.com/aniladanir/ethereum-blockchain-parser/pkg/errs"
+)
+
+const blockNumberStateName = "block_number"
+
+// transactionColumns are the columns of a transaction, in the order of transactionFields
+const transactionColumns = `hash, from_address, to_address, value, block_number, block_hash, transaction_index,
+	tx_type, chain_id, nonce, gas, gas_price, max_fee_per_gas, max_priority_fee_per_gas, access_list,
+	max_fee_per_blob_gas, blob_versioned_hashes, input, v, r, s, y_parity, receipt`
+
+// tokenTransferColumns are the columns of a token transfer, in the order of scanTokenTransfer
+const tokenTransferColumns = `token, from_address, to_address, value, block_height, block_hash, transaction_hash, log_index`
+
+// nftTransferColumns are the columns of an nft transfer, in the order of scanNFTTransfer
+const nftTransferColumns = `contract, standard, operator, from_address, to_address, token_id, amount, block_height,
+	block_hash, transaction_hash, log_index, batch_index`
+
+var (
+	_ Repository  = (*sqlRepository)(nil)
+	_ Transaction = (*sqlTransaction)(nil)
+)
+
+// dialect covers the differences between the supported sql databases
+type dialect string
+
+const (
+	dialectSqlite   dialect = "sqlite"
+	dialectPostgres dialect = "postgres"
+)
+
+func dialectOf(driverName string) (dialect, error) {
+	switch driverName {
+	case "sqlite3", "sqlite":
+		return dialectSqlite, nil
+	case "postgres", "pgx":
+		return dialectPostgres, nil
+	default:
+		return "", fmt.Errorf("unsupported sql driver %q", driverName)
+	}
+}
+
+func (d dialect) autoIncrementPrimaryKey() string {
+	if d == dialectPostgres {
+		return "BIGSERIAL PRIMARY KEY"
+	}
+	return "INTEGER PRIMARY KEY AUTOINCREMENT"
+}
+
+// rebind replaces the '?' placeholders of the query with the placeholders of the dialect
+func (d dialect) rebind(query string) string {
+	if d != dialectPostgres {
+		return query
+	}
+	var sb strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			sb.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		sb.WriteRune(c)
+	}
+	return sb.String()
+}
+
+// querier is implemented by both *sql.DB and *sql.Tx
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type sqlRepository struct {
+	db      *sql.DB
+	dialect dialect
+	// q is the database, or the database transaction if the repository belongs to a transaction
+	q querier
+}
+
+type sqlTransaction struct {
+	*sqlRepository
+	tx *sql.Tx
+}
+
+// NewSqlRepository opens the database with the given database/sql driver and migrates it to the latest schema.
+// Supported drivers are sqlite3 and postgres, which must be registered by the caller.
+// SQLite databases should be opened in WAL mode with a busy timeout and immediate transactions,
+// e.g. "file:txparser.db?_journal_mode=WAL&_busy_timeout=5000&_txlock=immediate".
+func NewSqlRepository(ctx context.Context, driverName, dsn string) (*sqlRepository, error) {
+	d, err := dialectOf(driverName)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("could not open database: %w", err)
+	}
+	if err := db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("could not connect to database: %w", err)
+	}
+	if err := migrate(ctx, db, d); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return &sqlRepository{db: db, dialect: d, q: db}, nil
+}
+
+// Close closes the database
+func (sr *sqlRepository) Close() error {
+	return sr.db.Close()
+}
+
+func (sr *sqlRepository) NewTransaction(ctx context.Context) (Transaction, error) {
+	if _, ok := sr.q.(*sql.Tx); ok {
+		return nil, errors.New("nested transactions are not supported")
+	}
+	tx, err := sr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not begin transaction: %w", err)
+	}
+	return &sqlTransaction{
+		sqlRepository: &sqlRepository{db: sr.db, dialect: sr.dialect, q: tx},
+		tx:            tx,
+	}, nil
+}
+
+func (sr *sqlRepository) GetBlockNumber(ctx context.Context) (int, error) {
+	var blockNumber int
+	err := sr.q.QueryRowContext(ctx, sr.dialect.rebind(`SELECT value FROM parser_state WHERE name = ?`), blockNumberStateName).Scan(&blockNumber)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, wrapSqlErr("could not get block number", err)
+	}
+	return blockNumber, nil
+}
+
+func (sr *sqlRepository) SetBlockNumber(ctx context.Context, blockNumber int) error {
+	_, err := sr.q.ExecContext(ctx, sr.dialect.rebind(`INSERT INTO parser_state (name, value) VALUES (?, ?)
+		ON CONFLICT (name) DO UPDATE SET value = excluded.value`), blockNumberStateName, blockNumber)
+	return wrapSqlErr("could not set block number", err)
+}
+
+func (sr *sqlRepository) SetBlockHash(ctx context.Context, blockNumber int, hash string) error {
+	_, err := sr.q.ExecContext(ctx, sr.dialect.rebind(`INSERT INTO block_hashes (block_number, hash) VALUES (?, ?)
+		ON CONFLICT (block_number) DO UPDATE SET hash = excluded.hash`), blockNumber, hash)
+	return wrapSqlErr("could not set block hash", err)
+}
+
+func (sr *sqlRepository) GetBlockHash(ctx context.Context, blockNumber int) (string, error) {
+	var hash string
+	err := sr.q.QueryRowContext(ctx, sr.dialect.rebind(`SELECT hash FROM block_hashes WHERE block_number = ?`), blockNumber).Scan(&hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", errs.NotFoundErr()
+	}
+	if err != nil {
+		return "", wrapSqlErr("could not get block hash", err)
+	}
+	return hash, nil
+}
+
+func (sr *sqlRepository) RemoveBlocks(ctx context.Context, fromBlock int) error {
+	return sr.inTransaction(ctx, func(q querier) error {
+		if _, err := q.ExecContext(ctx, sr.dialect.rebind(`DELETE FROM block_hashes WHERE block_number >= ?`), fromBlock); err != nil {
+			return wrapSqlErr("could not remove block hashes", err)
+		}
+		if _, err := q.ExecContext(ctx, sr.dialect.rebind(`DELETE FROM transactions WHERE block_height >= ?`), fromBlock); err != nil {
+			return wrapSqlErr("could not remove transactions", err)
+		}
+		if _, err := q.ExecContext(ctx, sr.dialect.rebind(`DELETE FROM token_transfers WHERE block_height >= ?`), fromBlock); err != nil {
+			return wrapSqlErr("could not remove token transfers", err)
+		}
+		if _, err := q.ExecContext(ctx, sr.dialect.rebind(`DELETE FROM nft_transfers WHERE block_height >= ?`), fromBlock); err != nil {
+			return wrapSqlErr("could not remove nft transfers", err)
+		}
+		return nil
+	})
+}
+
+func (sr *sqlRepository) PruneBlockHashes(ctx context.Context, beforeBlock int) error {
+	_, err := sr.q.ExecContext(ctx, sr.dialect.rebind(`DELETE FROM block_hashes WHERE block_number < ?`), beforeBlock)
+	return wrapSqlErr("could not prune block hashes", err)
+}
+
+func (sr *sqlRepository) GetTransactions(ctx context.Context, address string) ([]domain.Transaction, error) {
+	if err := sr.checkAddress(ctx, address); err != nil {
+		return nil, err
+	}
+
+	rows, err := sr.q.QueryContext(ctx, sr.dialect.rebind(`SELECT `+transactionColumns+`
+		FROM transactions WHERE address = ? ORDER BY id`), address)
+	if err != nil {
+		return nil, wrapSqlErr("could not get transactions", err)
+	}
+	defer rows.Close()
+
+	transactions := make([]domain.Transaction, 0)
+	for rows.Next() {
+		tx, err := scanTransaction(rows)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, tx)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, wrapSqlErr("could not get transactions", err)
+	}
+	return transactions, nil
+}
+
+func (sr *sqlRepository) QueryTransactions(ctx context.Context, query domain.TransactionQuery) (domain.TransactionPage, error) {
+	var cursor *transactionCursor
+	if query.Cursor != "" {
+		c, err := decodeCursor(query.Cursor)
+		if err != nil {
+			return domain.TransactionPage{}, err
+		}
+		cursor = &c
+	}
+	if err := sr.checkAddress(ctx, query.Address); err != nil {
+		return domain.TransactionPage{}, err
+	}
+
+	var sb strings.Builder
+	args := []any{query.Address}
+	sb.WriteString(`SELECT ` + transactionColumns + `, block_height FROM transactions WHERE address = ?`)
+	if query.FromBlock != nil {
+		sb.WriteString(` AND block_height >= ?`)
+		args = append(args, *query.FromBlock)
+	}
+	if query.ToBlock != nil {
+		sb.WriteString(` AND block_height <= ?`)
+		args = append(args, *query.ToBlock)
+	}
+	address, counterparty := strings.ToLower(query.Address), strings.ToLower(query.Counterparty)
+	switch {
+	case query.Direction == domain.DirectionIn && counterparty != "":
+		sb.WriteString(` AND lower(to_address) = ? AND lower(from_address) = ?`)
+		args = append(args, address, counterparty)
+	case query.Direction == domain.DirectionIn:
+		sb.WriteString(` AND lower(to_address) = ?`)
+		args = append(args, address)
+	case query.Direction == domain.DirectionOut && counterparty != "":
+		sb.WriteString(` AND lower(from_address) = ? AND lower(to_address) = ?`)
+		args = append(args, address, counterparty)
+	case query.Direction == domain.DirectionOut:
+		sb.WriteString(` AND lower(from_address) = ?`)
+		args = append(args, address)
+	case counterparty != "":
+		sb.WriteString(` AND ((lower(to_address) = ? AND lower(from_address) = ?) OR (lower(from_address) = ? AND lower(to_address) = ?))`)
+		args = append(args, address, counterparty, address, counterparty)
+	}
+	if query.MinValue != nil {
+		sb.WriteString(` AND value_key >= ?`)
+		args = append(args, valueKey(query.MinValue))
+	}
+	order, comparison := "ASC", ">"
+	if query.Order == domain.SortDescending {
+		order, comparison = "DESC", "<"
+	}
+	if cursor != nil {
+		sb.WriteString(fmt.Sprintf(` AND (block_height, hash) %s (?, ?)`, comparison))
+		args = append(args, cursor.blockNumber, cursor.hash)
+	}
+	sb.WriteString(fmt.Sprintf(` ORDER BY block_height %s, hash %s`, order, order))
+	if query.Limit > 0 {
+		// fetch one more transaction to know whether there is a next page
+		sb.WriteString(` LIMIT ?`)
+		args = append(args, query.Limit+1)
+	}
+
+	rows, err := sr.q.QueryContext(ctx, sr.dialect.rebind(sb.String()), args...)
+	if err != nil {
+		return domain.TransactionPage{}, wrapSqlErr("could not query transactions", err)
+	}
+	defer rows.Close()
+
+	page := domain.TransactionPage{Transactions: make([]domain.Transaction, 0)}
+	var last transactionCursor
+	for rows.Next() {
+		if query.Limit > 0 && len(page.Transactions) == query.Limit {
+			page.NextCursor = encodeCursor(last)
+			break
+		}
+		var blockHeight sql.NullInt64
+		tx, err := scanTransaction(rows, &blockHeight)
+		if err != nil {
+			return domain.TransactionPage{}, err
+		}
+		page.Transactions = append(page.Transactions, tx)
+		last = transactionCursor{blockNumber: int(blockHeight.Int64), hash: tx.Hash}
+	}
+	if err := rows.Err(); err != nil {
+		return domain.TransactionPage{}, wrapSqlErr("could not query transactions", err)
+	}
+	return page, nil
+}
+
+func (sr *sqlRepository) AddTransaction(ctx context.Context, address string, transaction domain.Transaction) error {
+	blockHeight := sql.NullInt64{Int64: int64(transaction.BlockNumber), Valid: true}
+
+	var value sql.NullString
+	if transaction.Value != nil {
+		value = sql.NullString{String: valueKey(transaction.Value), Valid: true}
+	}
+
+	fields, err := transactionFields(&transaction)
+	if err != nil {
+		return err
+	}
+
+	// only insert if the address is subscribed and does not have the transaction yet
+	args := append([]any{address}, fields...)
+	args = append(args, blockHeight, value, address)
+	result, err := sr.q.ExecContext(ctx, sr.dialect.rebind(`INSERT INTO transactions
+		(address, `+transactionColumns+`, block_height, value_key)
+		SELECT ?, `+strings.Repeat("?, ", len(fields))+`CAST(? AS BIGINT), ? WHERE EXISTS (SELECT 1 FROM addresses WHERE address = ? AND subscribed)
+		ON CONFLICT (address, hash) DO NOTHING`),
+		args...)
+	if err != nil {
+		return wrapSqlErr("could not add transaction", err)
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return wrapSqlErr("could not add transaction", err)
+	} else if affected == 0 {
+		return sr.checkSubscribed(ctx, address)
+	}
+	return nil
+}
+
+func (sr *sqlRepository) AddTokenTransfer(ctx context.Context, address string, transfer domain.TokenTransfer) error {
+	// only insert if the address is subscribed and does not have the token transfer yet
+	result, err := sr.q.ExecContext(ctx, sr.dialect.rebind(`INSERT INTO token_transfers (address, `+tokenTransferColumns+`)
+		SELECT ?, ?, ?, ?, ?, CAST(? AS BIGINT), ?, ?, CAST(? AS BIGINT) WHERE EXISTS (SELECT 1 FROM addresses WHERE address = ? AND subscribed)
+		ON CONFLICT (address, transaction_hash, log_index) DO NOTHING`),
+		address, transfer.Token, transfer.From, transfer.To, domain.EncodeQuantity(transfer.Value), int64(transfer.BlockNumber),
+		transfer.BlockHash, transfer.TransactionHash, int64(transfer.LogIndex), address)
+	if err != nil {
+		return wrapSqlErr("could not add token transfer", err)
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return wrapSqlErr("could not add token transfer", err)
+	} else if affected == 0 {
+		return sr.checkSubscribed(ctx, address)
+	}
+	return nil
+}
+
+func (sr *sqlRepository) QueryTokenTransfers(ctx context.Context, query domain.TokenTransferQuery) (domain.TokenTransferPage, error) {
+	transfers, nextCursor, err := queryTransferRows(ctx, sr, tokenTransferTable, tokenTransferQuery(query), scanTokenTransfer, tokenTransferFields)
+	if err != nil {
+		return domain.TokenTransferPage{}, err
+	}
+	return domain.TokenTransferPage{TokenTransfers: transfers, NextCursor: nextCursor}, nil
+}
+
+func (sr *sqlRepository) AddNFTTransfer(ctx context.Context, address string, transfer domain.NFTTransfer) error {
+	// only insert if the address is subscribed and does not have the nft transfer yet
+	result, err := sr.q.ExecContext(ctx, sr.dialect.rebind(`INSERT INTO nft_transfers (address, `+nftTransferColumns+`)
+		SELECT ?, ?, ?, ?, ?, ?, ?, ?, CAST(? AS BIGINT), ?, ?, CAST(? AS BIGINT), CAST(? AS BIGINT)
+		WHERE EXISTS (SELECT 1 FROM addresses WHERE address = ? AND subscribed)
+		ON CONFLICT (address, transaction_hash, log_index, batch_index) DO NOTHING`),
+		address, transfer.Contract, string(transfer.Standard), transfer.Operator, transfer.From, transfer.To,
+		domain.EncodeQuantity(transfer.TokenID), domain.EncodeQuantity(transfer.Amount), int64(transfer.BlockNumber),
+		transfer.BlockHash, transfer.TransactionHash, int64(transfer.LogIndex), int64(transfer.BatchIndex), address)
+	if err != nil {
+		return wrapSqlErr("could not add nft transfer", err)
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return wrapSqlErr("could not add nft transfer", err)
+	} else if affected == 0 {
+		return sr.checkSubscribed(ctx, address)
+	}
+	return nil
+}
+
+func (sr *sqlRepository) QueryNFTTransfers(ctx context.Context, query domain.NFTTransferQuery) (domain.NFTTransferPage, error) {
+	transfers, nextCursor, err := queryTransferRows(ctx, sr, nftTransferTable, nftTransferQuery(query), scanNFTTransfer, nftTransferFields)
+	if err != nil {
+		return domain.NFTTransferPage{}, err
+	}
+	return domain.NFTTransferPage{NFTTransfers: transfers, NextCursor: nextCursor}, nil
+}
+
+// transferTable describes the table of a kind of transfer
+type transferTable struct {
+	name string
+	// kind names the transfers in errors
+	kind    string
+	columns string
+	// contractColumn is the column transferQuery.contract filters on
+	contractColumn string
+	// positionColumns are the columns of the fields of transferCursor that the transfers are ordered by
+	positionColumns []string
+}
+
+var (
+	tokenTransferTable = transferTable{
+		name:            "token_transfers",
+		kind:            "token transfers",
+		columns:         tokenTransferColumns,
+		contractColumn:  "token",
+		positionColumns: []string{"block_height", "log_index"},
+	}
+	nftTransferTable = transferTable{
+		name:            "nft_transfers",
+		kind:            "nft transfers",
+		columns:         nftTransferColumns,
+		contractColumn:  "contract",
+		positionColumns: []string{"block_height", "log_index", "batch_index"},
+	}
+)
+
+// queryTransferRows selects the page of the transfers in table that matches the query, and the cursor of the next page
+func queryTransferRows[T any](ctx context.Context, sr *sqlRepository, table transferTable, query transferQuery,
+	scan func(rows *sql.Rows) (T, error), fields func(transfer *T) transferFields) ([]T, string, error) {
+	var cursor *transferCursor
+	if query.cursor != "" {
+		c, err := decodeTransferCursor(query.cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		cursor = &c
+	}
+	if err := sr.checkAddress(ctx, query.address); err != nil {
+		return nil, "", err
+	}
+
+	var sb strings.Builder
+	args := []any{query.address}
+	sb.WriteString(`SELECT ` + table.columns + ` FROM ` + table.name + ` WHERE address = ?`)
+	if query.fromBlock != nil {
+		sb.WriteString(` AND block_height >= ?`)
+		args = append(args, *query.fromBlock)
+	}
+	if query.toBlock != nil {
+		sb.WriteString(` AND block_height <= ?`)
+		args = append(args, *query.toBlock)
+	}
+	if query.contract != "" {
+		sb.WriteString(` AND lower(` + table.contractColumn + `) = ?`)
+		args = append(args, strings.ToLower(query.contract))
+	}
+	switch query.direction {
+	case domain.DirectionIn:
+		sb.WriteString(` AND lower(to_address) = ?`)
+		args = append(args, strings.ToLower(query.address))
+	case domain.DirectionOut:
+		sb.WriteString(` AND lower(from_address) = ?`)
+		args = append(args, strings.ToLower(query.address))
+	}
+	order, comparison := "ASC", ">"
+	if query.order == domain.SortDescending {
+		order, comparison = "DESC", "<"
+	}
+	positionColumns := strings.Join(table.positionColumns, ", ")
+	if cursor != nil {
+		position := []any{cursor.blockNumber, int64(cursor.logIndex), int64(cursor.batchIndex)}[:len(table.positionColumns)]
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(position)), ", ")
+		sb.WriteString(fmt.Sprintf(` AND (%s) %s (%s)`, positionColumns, comparison, placeholders))
+		args = append(args, position...)
+	}
+	orderBy := make([]string, len(table.positionColumns))
+	for i, column := range table.positionColumns {
+		orderBy[i] = column + " " + order
+	}
+	sb.WriteString(` ORDER BY ` + strings.Join(orderBy, ", "))
+	if query.limit > 0 {
+		// fetch one more transfer to know whether there is a next page
+		sb.WriteString(` LIMIT ?`)
+		args = append(args, query.limit+1)
+	}
+
+	rows, err := sr.q.QueryContext(ctx, sr.dialect.rebind(sb.String()), args...)
+	if err != nil {
+		return nil, "", wrapSqlErr("could not query "+table.kind, err)
+	}
+	defer rows.Close()
+
+	transfers, nextCursor := make([]T, 0), ""
+	for rows.Next() {
+		if query.limit > 0 && len(transfers) == query.limit {
+			nextCursor = encodeTransferCursor(fields(&transfers[len(transfers)-1]).position)
+			break
+		}
+		transfer, err := scan(rows)
+		if err != nil {
+			return nil, "", err
+		}
+		transfers = append(transfers, transfer)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", wrapSqlErr("could not query "+table.kind, err)
+	}
+	return transfers, nextCursor, nil
+}
+
+func (sr *sqlRepository) AddAddress(ctx context.Context, address string) error {
+	// an unsubscribed address is subscribed again, keeping its transactions
+	result, err := sr.q.ExecContext(ctx, sr.dialect.rebind(`INSERT INTO addresses (address) VALUES (?)
+		ON CONFLICT (address) DO UPDATE SET subscribed = TRUE WHERE NOT addresses.subscribed`), address)
+	if err != nil {
+		return wrapSqlErr("could not add address", err)
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return wrapSqlErr("could not add address", err)
+	} else if affected == 0 {
+		return errs.AlreadyExistErr()
+	}
+	return nil
+}
+
+func (sr *sqlRepository) RemoveAddress(ctx context.Context, address string, purge bool) error {
+	if !purge {
+		result, err := sr.q.ExecContext(ctx, sr.dialect.rebind(`UPDATE addresses SET subscribed = FALSE, label = '' WHERE address = ? AND subscribed`), address)
+		if err != nil {
+			return wrapSqlErr("could not remove address", err)
+		}
+		if affected, err := result.RowsAffected(); err != nil {
+			return wrapSqlErr("could not remove address", err)
+		} else if affected == 0 {
+			return errs.NotFoundErr()
+		}
+		return nil
+	}
+
+	return sr.inTransaction(ctx, func(q querier) error {
+		result, err := q.ExecContext(ctx, sr.dialect.rebind(`DELETE FROM addresses WHERE address = ?`), address)
+		if err != nil {
+			return wrapSqlErr("could not remove address", err)
+		}
+		if affected, err := result.RowsAffected(); err != nil {
+			return wrapSqlErr("could not remove address", err)
+		} else if affected == 0 {
+			return errs.NotFoundErr()
+		}
+		if _, err := q.ExecContext(ctx, sr.dialect.rebind(`DELETE FROM transactions WHERE address = ?`), address); err != nil {
+			return wrapSqlErr("could not remove transactions", err)
+		}
+		if _, err := q.ExecContext(ctx, sr.dialect.rebind(`DELETE FROM token_transfers WHERE address = ?`), address); err != nil {
+			return wrapSqlErr("could not remove token transfers", err)
+		}
+		if _, err := q.ExecContext(ctx, sr.dialect.rebind(`DELETE FROM nft_transfers WHERE address = ?`), address); err != nil {
+			return wrapSqlErr("could not remove nft transfers", err)
+		}
+		return nil
+	})
+}
+
+func (sr *sqlRepository) GetAddresses(ctx context.Context) ([]string, error) {
+	rows, err := sr.q.QueryContext(ctx, `SELECT address FROM addresses WHERE subscribed ORDER BY address`)
+	if err != nil {
+		return nil, wrapSqlErr("could not get addresses", err)
+	}
+	defer rows.Close()
+
+	addresses := make([]string, 0)
+	for rows.Next() {
+		var address string
+		if err := rows.Scan(&address); err != nil {
+			return nil, wrapSqlErr("could not scan address", err)
+		}
+		addresses = append(addresses, address)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, wrapSqlErr("could not get addresses", err)
+	}
+	return addresses, nil
+}
+
+func (sr *sqlRepository) SetAddressLabel(ctx context.Context, address string, label string) error {
+	result, err := sr.q.ExecContext(ctx, sr.dialect.rebind(`UPDATE addresses SET label = ? WHERE address = ? AND subscribed`), label, address)
+	if err != nil {
+		return wrapSqlErr("could not set address label", err)
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return wrapSqlErr("could not set address label", err)
+	} else if affected == 0 {
+		return errs.NotFoundErr()
+	}
+	return nil
+}
+
+func (sr *sqlRepository) GetSubscriptions(ctx context.Context) ([]domain.Subscription, error) {
+	rows, err := sr.q.QueryContext(ctx, `SELECT address, label FROM addresses WHERE subscribed ORDER BY address`)
+	if err != nil {
+		return nil, wrapSqlErr("could not get subscriptions", err)
+	}
+	defer rows.Close()
+
+	subscriptions := make([]domain.Subscription, 0)
+	for rows.Next() {
+		var subscription domain.Subscription
+		if err := rows.Scan(&subscription.Address, &subscription.Label); err != nil {
+			return nil, wrapSqlErr("could not scan subscription", err)
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, wrapSqlErr("could not get subscriptions", err)
+	}
+	return subscriptions, nil
+}
+
+// transactionFields returns the values of the transactionColumns of a transaction.
+// Numbers are stored as json-rpc quantities, and absent amounts as empty strings.
+func transactionFields(tx *domain.Transaction) ([]any, error) {
+	accessList, err := marshalList(tx.AccessList)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode access list: %w", err)
+	}
+	blobVersionedHashes, err := marshalList(tx.BlobVersionedHashes)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode blob versioned hashes: %w", err)
+	}
+	var receipt string
+	if tx.Receipt != nil {
+		encoded, err := json.Marshal(tx.Receipt)
+		if err != nil {
+			return nil, fmt.Errorf("could not encode receipt: %w", err)
+		}
+		receipt = string(encoded)
+	}
+	var chainID string
+	if tx.ChainID != 0 {
+		chainID = domain.EncodeUint64Quantity(tx.ChainID)
+	}
+	return []any{
+		tx.Hash, tx.From, tx.To, domain.EncodeQuantity(tx.Value), domain.EncodeUint64Quantity(tx.BlockNumber), tx.BlockHash,
+		domain.EncodeUint64Quantity(tx.TransactionIndex), domain.EncodeUint64Quantity(uint64(tx.Type)), chainID,
+		domain.EncodeUint64Quantity(tx.Nonce), domain.EncodeUint64Quantity(tx.Gas), domain.EncodeQuantity(tx.GasPrice),
+		domain.EncodeQuantity(tx.MaxFeePerGas), domain.EncodeQuantity(tx.MaxPriorityFeePerGas), accessList,
+		domain.EncodeQuantity(tx.MaxFeePerBlobGas), blobVersionedHashes, tx.Input, tx.V, tx.R, tx.S, tx.YParity, receipt,
+	}, nil
+}
+
+// scanTransaction scans the transactionColumns of a row, followed by the extra destinations
+func scanTransaction(rows *sql.Rows, extra ...any) (domain.Transaction, error) {
+	var tx domain.Transaction
+	var value, blockNumber, transactionIndex, txType, chainID, nonce, gas, gasPrice, maxFeePerGas, maxPriorityFeePerGas,
+		accessList, maxFeePerBlobGas, blobVersionedHashes, receipt string
+	dest := []any{
+		&tx.Hash, &tx.From, &tx.To, &value, &blockNumber, &tx.BlockHash, &transactionIndex, &txType, &chainID,
+		&nonce, &gas, &gasPrice, &maxFeePerGas, &maxPriorityFeePerGas, &accessList,
+		&maxFeePerBlobGas, &blobVersionedHashes, &tx.Input, &tx.V, &tx.R, &tx.S, &tx.YParity, &receipt,
+	}
+	if err := rows.Scan(append(dest, extra...)...); err != nil {
+		return domain.Transaction{}, wrapSqlErr("could not scan transaction", err)
+	}
+
+	var err error
+	for _, field := range []struct {
+		value string
+		dest  *uint64
+	}{
+		{value: blockNumber, dest: &tx.BlockNumber},
+		{value: transactionIndex, dest: &tx.TransactionIndex},
+		{value: chainID, dest: &tx.ChainID},
+		{value: nonce, dest: &tx.Nonce},
+		{value: gas, dest: &tx.Gas},
+	} {
+		if *field.dest, err = parseStoredUint64(field.value); err != nil {
+			return domain.Transaction{}, fmt.Errorf("could not decode transaction %s: %w", tx.Hash, err)
+		}
+	}
+	typeNumber, err := parseStoredUint64(txType)
+	if err != nil || typeNumber > math.MaxUint8 {
+		return domain.Transaction{}, fmt.Errorf("could not decode type of transaction %s: %q", tx.Hash, txType)
+	}
+	tx.Type = uint8(typeNumber)
+	for _, field := range []struct {
+		value string
+		dest  **big.Int
+	}{
+		{value: value, dest: &tx.Value},
+		{value: gasPrice, dest: &tx.GasPrice},
+		{value: maxFeePerGas, dest: &tx.MaxFeePerGas},
+		{value: maxPriorityFeePerGas, dest: &tx.MaxPriorityFeePerGas},
+		{value: maxFeePerBlobGas, dest: &tx.MaxFeePerBlobGas},
+	} {
+		if field.value == "" {
+			continue
+		}
+		if *field.dest, err = domain.ParseQuantity(field.value); err != nil {
+			return domain.Transaction{}, fmt.Errorf("could not decode transaction %s: %w", tx.Hash, err)
+		}
+	}
+	if err := unmarshalList(accessList, &tx.AccessList); err != nil {
+		return domain.Transaction{}, fmt.Errorf("could not decode access list: %w", err)
+	}
+	if err := unmarshalList(blobVersionedHashes, &tx.BlobVersionedHashes); err != nil {
+		return domain.Transaction{}, fmt.Errorf("could not decode blob versioned hashes: %w", err)
+	}
+	if receipt != "" {
+		tx.Receipt = new(domain.Receipt)
+		if err := json.Unmarshal([]byte(receipt), tx.Receipt); err != nil {
+			return domain.Transaction{}, fmt.Errorf("could not decode receipt: %w", err)
+		}
+	}
+	return tx, nil
+}
+
+// scanTokenTransfer scans the tokenTransferColumns of a row
+func scanTokenTransfer(rows *sql.Rows) (domain.TokenTransfer, error) {
+	var transfer domain.TokenTransfer
+	var value string
+	var blockHeight, logIndex int64
+	if err := rows.Scan(&transfer.Token, &transfer.From, &transfer.To, &value, &blockHeight, &transfer.BlockHash,
+		&transfer.TransactionHash, &logIndex); err != nil {
+		return domain.TokenTransfer{}, wrapSqlErr("could not scan token transfer", err)
+	}
+	transfer.BlockNumber, transfer.LogIndex = uint64(blockHeight), uint64(logIndex)
+
+	var err error
+	if transfer.Value, err = domain.ParseQuantity(value); err != nil {
+		return domain.TokenTransfer{}, fmt.Errorf("could not decode token transfer %s/%d: %w", transfer.TransactionHash, transfer.LogIndex, err)
+	}
+	return transfer, nil
+}
+
+// scanNFTTransfer scans the nftTransferColumns of a row
+func scanNFTTransfer(rows *sql.Rows) (domain.NFTTransfer, error) {
+	var transfer domain.NFTTransfer
+	var standard, tokenID, amount string
+	var blockHeight, logIndex, batchIndex int64
+	if err := rows.Scan(&transfer.Contract, &standard, &transfer.Operator, &transfer.From, &transfer.To, &tokenID, &amount,
+		&blockHeight, &transfer.BlockHash, &transfer.TransactionHash, &logIndex, &batchIndex); err != nil {
+		return domain.NFTTransfer{}, wrapSqlErr("could not scan nft transfer", err)
+	}
+	transfer.Standard = domain.NFTStandard(standard)
+	transfer.BlockNumber, transfer.LogIndex, transfer.BatchIndex = uint64(blockHeight), uint64(logIndex), uint64(batchIndex)
+
+	var err error
+	if transfer.TokenID, err = domain.ParseQuantity(tokenID); err != nil {
+		return domain.NFTTransfer{}, fmt.Errorf("could not decode nft transfer %s/%d: %w", transfer.TransactionHash, transfer.LogIndex, err)
+	}
+	if transfer.Amount, err = domain.ParseQuantity(amount); err != nil {
+		return domain.NFTTransfer{}, fmt.Errorf("could not decode nft transfer %s/%d: %w", transfer.TransactionHash, transfer.LogIndex, err)
+	}
+	return transfer, nil
+}
+
+// parseStoredUint64 parses a quantity column, which is empty for rows stored before the column was added
+func parseStoredUint64(quantity string) (uint64, error) {
+	if quantity == "" {
+		return 0, nil
+	}
+	return domain.ParseUint64Quantity(quantity)
+}
+
+// marshalList encodes a list as json, and an empty list as empty string
+func marshalList[T any](list []T) (string, error) {
+	if len(list) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(list)
+	return string(data), err
+}
+
+func unmarshalList[T any](data string, list *[]T) error {
+	if data == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(data), list)
+}
+
+// checkAddress returns a not found error if the address is neither subscribed nor has kept transactions
+func (sr *sqlRepository) checkAddress(ctx context.Context, address string) error {
+	var exists bool
+	err := sr.q.QueryRowContext(ctx, sr.dialect.rebind(`SELECT EXISTS (SELECT 1 FROM addresses WHERE address = ?)`), address).Scan(&exists)
+	if err != nil {
+		return wrapSqlErr("could not get address", err)
+	}
+	if !exists {
+		return errs.NotFoundErr()
+	}
+	return nil
+}
+
+// checkSubscribed returns a not found error if the address is not subscribed
+func (sr *sqlRepository) checkSubscribed(ctx context.Context, address string) error {
+	var subscribed bool
+	err := sr.q.QueryRowContext(ctx, sr.dialect.rebind(`SELECT EXISTS (SELECT 1 FROM addresses WHERE address = ? AND subscribed)`), address).Scan(&subscribed)
+	if err != nil {
+		return wrapSqlErr("could not get address", err)
+	}
+	if !subscribed {
+		return errs.NotFoundErr()
+	}
+	return nil
+}
+
+// inTransaction runs fn in the transaction of the repository, or in a new one if the repository has none
+func (sr *sqlRepository) inTransaction(ctx context.Context, fn func(q querier) error) error {
+	if _, ok := sr.q.(*sql.Tx); ok {
+		return fn(sr.q)
+	}
+
+	tx, err := sr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return wrapSqlErr("could not commit transaction", tx.Commit())
+}
+
+func (st *sqlTransaction) Commit(ctx context.Context) error {
+	return wrapSqlErr("could not commit transaction", st.tx.Commit())
+}
+
+func (st *sqlTransaction) Rollback(ctx context.Context) error {
+	return wrapSqlErr("could not rollback transaction", st.tx.Rollback())
+}
+
+// wrapSqlErr adds context to the error, translating database/sql errors to the errors of the repository
+func wrapSqlErr(msg string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, sql.ErrTxDone) {
+		err = errs.TransactionDoneErr()
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
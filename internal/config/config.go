@@ -13,12 +13,89 @@ type Configurator interface {
 	GetHttpServerPort() int
 	// GetChainProcessInterval returns internal for chain process in milliseconds
 	GetChainProcessInterval() int
+	// GetMaxReorgDepth returns maximum number of blocks that can be rolled back on chain reorganization
+	GetMaxReorgDepth() int
+	// GetConfirmations returns number of blocks a block has to be behind the head before it is processed
+	GetConfirmations() int
+	// GetBlockTag returns block tag of the followed head block: latest, safe or finalized
+	GetBlockTag() string
+	// GetStartMode returns where to start processing on startup: resume, head or block
+	GetStartMode() string
+	// GetStartBlock returns first block to be processed when start mode is block
+	GetStartBlock() int
+	// GetFetchParallelism returns number of block ranges fetched concurrently while catching up
+	GetFetchParallelism() int
+	// GetFetchReceipts returns whether the receipts of the matched transactions are fetched
+	GetFetchReceipts() bool
+	// GetFetchTokenTransfers returns whether the erc-20 token transfers of the subscribed addresses are fetched
+	GetFetchTokenTransfers() bool
+	// GetFetchNFTTransfers returns whether the erc-721 and erc-1155 transfers of the subscribed addresses are fetched
+	GetFetchNFTTransfers() bool
+	// GetStorageType returns the repository backend: memory, file or sql
+	GetStorageType() string
+	// GetStorageFilePath returns the data directory of the file repository
+	GetStorageFilePath() string
+	// GetStorageSnapshotRecords returns number of committed transactions after which the file repository writes a snapshot
+	GetStorageSnapshotRecords() int
+	// GetStorageSqlDriver returns database/sql driver name of the sql repository: sqlite3 or postgres
+	GetStorageSqlDriver() string
+	// GetStorageSqlDsn returns data source name of the sql repository
+	GetStorageSqlDsn() string
+	// GetRpcEndpoints returns the blockchain rpc endpoints
+	GetRpcEndpoints() []RpcEndpoint
+	// GetRpcTimeout returns timeout for rpc requests in milliseconds
+	GetRpcTimeout() int
+	// GetRpcMaxBatchSize returns maximum number of calls in a single json-rpc batch request
+	GetRpcMaxBatchSize() int
+	// GetRpcMaxRetries returns number of retries for failed rpc requests
+	GetRpcMaxRetries() int
+	// GetRpcInitialBackoff returns delay before the first retry in milliseconds
+	GetRpcInitialBackoff() int
+	// GetRpcMaxBackoff returns maximum delay between retries in milliseconds
+	GetRpcMaxBackoff() int
+	// GetRpcRequestsPerSecond returns maximum rate of rpc requests, zero means unlimited
+	GetRpcRequestsPerSecond() float64
+	// GetRpcRateLimitBurst returns number of rpc requests allowed at once
+	GetRpcRateLimitBurst() int
+	// GetRpcWebsocketUrl returns url of the websocket endpoint used to subscribe to new blocks
+	GetRpcWebsocketUrl() string
+	// GetRpcWebsocketHeaders returns additional headers sent with the websocket handshake
+	GetRpcWebsocketHeaders() map[string]string
+}
+
+type RpcEndpoint struct {
+	Url     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
 }
 
 type Config struct {
 	Version              string `json:"version"`
 	ChainProcessInterval int    `json:"chainProcessInterval"`
-	Log                  struct {
+	MaxReorgDepth        int    `json:"maxReorgDepth"`
+	FetchParallelism     int    `json:"fetchParallelism"`
+	FetchReceipts        bool   `json:"fetchReceipts"`
+	FetchTokenTransfers  bool   `json:"fetchTokenTransfers"`
+	FetchNFTTransfers    bool   `json:"fetchNftTransfers"`
+	Finality             struct {
+		BlockTag      string `json:"blockTag"`
+		Confirmations int    `json:"confirmations"`
+	} `json:"finality"`
+	Start struct {
+		Mode  string `json:"mode"`
+		Block int    `json:"block"`
+	} `json:"start"`
+	Storage struct {
+		Type string `json:"type"`
+		File struct {
+			Path            string `json:"path"`
+			SnapshotRecords int    `json:"snapshotRecords"`
+		} `json:"file"`
+		Sql struct {
+			Driver string `json:"driver"`
+			Dsn    string `json:"dsn"`
+		} `json:"sql"`
+	} `json:"storage"`
+	Log struct {
 		File  string `json:"file"`
 		Level string `json:"level"`
 	} `json:"log"`
@@ -28,4 +105,21 @@ type Config struct {
 			Port      int    `json:"port"`
 		} `json:"server"`
 	} `json:"http"`
+	Rpc struct {
+		Url          string            `json:"url"`
+		Timeout      int               `json:"timeout"`
+		Headers      map[string]string `json:"headers"`
+		Endpoints    []RpcEndpoint     `json:"endpoints"`
+		MaxBatchSize int               `json:"maxBatchSize"`
+		Retry        struct {
+			MaxRetries     int `json:"maxRetries"`
+			InitialBackoff int `json:"initialBackoff"`
+			MaxBackoff     int `json:"maxBackoff"`
+		} `json:"retry"`
+		RateLimit struct {
+			RequestsPerSecond float64 `json:"requestsPerSecond"`
+			Burst             int     `json:"burst"`
+		} `json:"rateLimit"`
+		Websocket RpcEndpoint `json:"websocket"`
+	} `json:"rpc"`
 }
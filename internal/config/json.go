@@ -47,3 +47,107 @@ func (jc *jsonConfiguration) GetHttpServerPort() int {
 func (jc *jsonConfiguration) GetChainProcessInterval() int {
 	return jc.cfg.ChainProcessInterval
 }
+
+func (jc *jsonConfiguration) GetMaxReorgDepth() int {
+	return jc.cfg.MaxReorgDepth
+}
+
+func (jc *jsonConfiguration) GetConfirmations() int {
+	return jc.cfg.Finality.Confirmations
+}
+
+func (jc *jsonConfiguration) GetBlockTag() string {
+	return jc.cfg.Finality.BlockTag
+}
+
+func (jc *jsonConfiguration) GetStartMode() string {
+	return jc.cfg.Start.Mode
+}
+
+func (jc *jsonConfiguration) GetStartBlock() int {
+	return jc.cfg.Start.Block
+}
+
+func (jc *jsonConfiguration) GetFetchParallelism() int {
+	return jc.cfg.FetchParallelism
+}
+
+func (jc *jsonConfiguration) GetFetchReceipts() bool {
+	return jc.cfg.FetchReceipts
+}
+
+func (jc *jsonConfiguration) GetFetchTokenTransfers() bool {
+	return jc.cfg.FetchTokenTransfers
+}
+
+func (jc *jsonConfiguration) GetFetchNFTTransfers() bool {
+	return jc.cfg.FetchNFTTransfers
+}
+
+func (jc *jsonConfiguration) GetStorageType() string {
+	return jc.cfg.Storage.Type
+}
+
+func (jc *jsonConfiguration) GetStorageFilePath() string {
+	return jc.cfg.Storage.File.Path
+}
+
+func (jc *jsonConfiguration) GetStorageSnapshotRecords() int {
+	return jc.cfg.Storage.File.SnapshotRecords
+}
+
+func (jc *jsonConfiguration) GetStorageSqlDriver() string {
+	return jc.cfg.Storage.Sql.Driver
+}
+
+func (jc *jsonConfiguration) GetStorageSqlDsn() string {
+	return jc.cfg.Storage.Sql.Dsn
+}
+
+// GetRpcEndpoints returns the endpoint list, preceded by the single 'url' endpoint if it is set.
+func (jc *jsonConfiguration) GetRpcEndpoints() []RpcEndpoint {
+	endpoints := make([]RpcEndpoint, 0, len(jc.cfg.Rpc.Endpoints)+1)
+	if jc.cfg.Rpc.Url != "" {
+		endpoints = append(endpoints, RpcEndpoint{
+			Url:     jc.cfg.Rpc.Url,
+			Headers: jc.cfg.Rpc.Headers,
+		})
+	}
+	return append(endpoints, jc.cfg.Rpc.Endpoints...)
+}
+
+func (jc *jsonConfiguration) GetRpcTimeout() int {
+	return jc.cfg.Rpc.Timeout
+}
+
+func (jc *jsonConfiguration) GetRpcMaxBatchSize() int {
+	return jc.cfg.Rpc.MaxBatchSize
+}
+
+func (jc *jsonConfiguration) GetRpcMaxRetries() int {
+	return jc.cfg.Rpc.Retry.MaxRetries
+}
+
+func (jc *jsonConfiguration) GetRpcInitialBackoff() int {
+	return jc.cfg.Rpc.Retry.InitialBackoff
+}
+
+func (jc *jsonConfiguration) GetRpcMaxBackoff() int {
+	return jc.cfg.Rpc.Retry.MaxBackoff
+}
+
+func (jc *jsonConfiguration) GetRpcRequestsPerSecond() float64 {
+	return jc.cfg.Rpc.RateLimit.RequestsPerSecond
+}
+
+func (jc *jsonConfiguration) GetRpcRateLimitBurst() int {
+	return jc.cfg.Rpc.RateLimit.Burst
+}
+
+func (jc *jsonConfiguration) GetRpcWebsocketUrl() string {
+	return jc.cfg.Rpc.Websocket.Url
+}
+
+func (jc *jsonConfiguration) GetRpcWebsocketHeaders() map[string]string {
+	return jc.cfg.Rpc.Websocket.Headers
+}
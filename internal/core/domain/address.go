@@ -0,0 +1,73 @@
+package domain
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/sha3"
+
+	"github.com/aniladanir/ethereum-blockchain-parser/pkg/errs"
+)
+
+// AddressLength is the length of an address in bytes
+const AddressLength = 20
+
+// Address represents an ethereum account address in its canonical form, which is
+// 0x prefixed lowercase hex. Use ParseAddress to create an address from user input.
+type Address string
+
+// ParseAddress validates the given hex address and returns it in canonical form.
+// Mixed case addresses must have a valid EIP-55 checksum.
+func ParseAddress(s string) (Address, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "0x") && !strings.HasPrefix(s, "0X") {
+		return "", fmt.Errorf("%w: missing 0x prefix", errs.InvalidAddressErr())
+	}
+	hexPart := s[2:]
+	if len(hexPart) != AddressLength*2 {
+		return "", fmt.Errorf("%w: expected %d hex characters, got %d", errs.InvalidAddressErr(), AddressLength*2, len(hexPart))
+	}
+	if _, err := hex.DecodeString(hexPart); err != nil {
+		return "", fmt.Errorf("%w: %s", errs.InvalidAddressErr(), err)
+	}
+
+	addr := Address("0x" + strings.ToLower(hexPart))
+
+	// all lowercase or all uppercase addresses carry no checksum
+	if hexPart != strings.ToLower(hexPart) && hexPart != strings.ToUpper(hexPart) {
+		if addr.Checksum() != "0x"+hexPart {
+			return "", fmt.Errorf("%w: invalid checksum", errs.InvalidAddressErr())
+		}
+	}
+
+	return addr, nil
+}
+
+// String returns the canonical form of the address
+func (a Address) String() string {
+	return string(a)
+}
+
+// Checksum returns the address in EIP-55 mixed case checksum encoding
+func (a Address) Checksum() string {
+	lower := strings.ToLower(strings.TrimPrefix(string(a), "0x"))
+
+	hasher := sha3.NewLegacyKeccak256()
+	hasher.Write([]byte(lower))
+	hash := hasher.Sum(nil)
+
+	// uppercase every letter whose corresponding nibble of the hash is 8 or higher
+	checksummed := []byte(lower)
+	for i := range checksummed {
+		nibble := hash[i/2]
+		if i%2 == 0 {
+			nibble >>= 4
+		}
+		if checksummed[i] >= 'a' && checksummed[i] <= 'f' && nibble&0x0f >= 8 {
+			checksummed[i] -= 'a' - 'A'
+		}
+	}
+
+	return "0x" + string(checksummed)
+}
@@ -0,0 +1,87 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/aniladanir/ethereum-blockchain-parser/pkg/errs"
+)
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		name          string
+		input         string
+		expected      Address
+		expectInvalid bool
+	}{
+		{
+			name:     "Lowercase",
+			input:    "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed",
+			expected: "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed",
+		},
+		{
+			name:     "Uppercase",
+			input:    "0x5AAEB6053F3E94C9B9A09F33669435E7EF1BEAED",
+			expected: "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed",
+		},
+		{
+			name:     "ValidChecksum",
+			input:    "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+			expected: "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed",
+		},
+		{
+			name:          "InvalidChecksum",
+			input:         "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAeD",
+			expectInvalid: true,
+		},
+		{
+			name:          "TooShort",
+			input:         "0x123",
+			expectInvalid: true,
+		},
+		{
+			name:          "MissingPrefix",
+			input:         "5aaeb6053f3e94c9b9a09f33669435e7ef1beaed",
+			expectInvalid: true,
+		},
+		{
+			name:          "NonHex",
+			input:         "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaeg",
+			expectInvalid: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, err := ParseAddress(tt.input)
+			if tt.expectInvalid {
+				if !errs.IsInvalidAddressErr(err) {
+					t.Errorf("expected invalid address error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if addr != tt.expected {
+				t.Errorf("expected address %s, got %s", tt.expected, addr)
+			}
+		})
+	}
+}
+
+func TestAddressChecksum(t *testing.T) {
+	// test vectors from EIP-55
+	for _, expected := range []string{
+		"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+		"0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359",
+		"0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6FB",
+		"0xD1220A0cf47c7B9Be7A2E6BA89F429762e7b9aDb",
+	} {
+		addr, err := ParseAddress(expected)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if checksum := addr.Checksum(); checksum != expected {
+			t.Errorf("expected checksum %s, got %s", expected, checksum)
+		}
+	}
+}
@@ -2,6 +2,12 @@ package domain
 
 // Block represents a single block in the blockchain
 type Block struct {
-	Number       string        `json:"number"`
+	Number       uint64        `json:"number"`
+	Hash         string        `json:"hash"`
+	ParentHash   string        `json:"parentHash"`
 	Transactions []Transaction `json:"transactions"`
+	// TokenTransfers are the erc-20 transfers emitted in the block, nil if token transfers are not fetched
+	TokenTransfers []TokenTransfer `json:"tokenTransfers,omitempty"`
+	// NFTTransfers are the erc-721 and erc-1155 transfers emitted in the block, nil if nft transfers are not fetched
+	NFTTransfers []NFTTransfer `json:"nftTransfers,omitempty"`
 }
@@ -0,0 +1,214 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+var (
+	// TransferSingleEventTopic is the topic of the erc-1155 TransferSingle(address,address,address,uint256,uint256) event
+	TransferSingleEventTopic = EventTopic("TransferSingle(address,address,address,uint256,uint256)")
+	// TransferBatchEventTopic is the topic of the erc-1155 TransferBatch(address,address,address,uint256[],uint256[]) event
+	TransferBatchEventTopic = EventTopic("TransferBatch(address,address,address,uint256[],uint256[])")
+)
+
+// NFTStandard is the token standard of an nft contract
+type NFTStandard string
+
+const (
+	NFTStandardERC721  NFTStandard = "erc721"
+	NFTStandardERC1155 NFTStandard = "erc1155"
+)
+
+// NFTTransfer is a transfer of an erc-721 token, or of an amount of an erc-1155 token
+type NFTTransfer struct {
+	// Contract is the address of the nft contract
+	Contract string
+	Standard NFTStandard
+	// Operator is the address that executed an erc-1155 transfer, empty for erc-721 transfers
+	Operator string
+	From     string
+	To       string
+	TokenID  *big.Int
+	// Amount is the number of transferred tokens, which is 1 for erc-721 transfers
+	Amount          *big.Int
+	BlockNumber     uint64
+	BlockHash       string
+	TransactionHash string
+	// LogIndex is the position of the event in its block
+	LogIndex uint64
+	// BatchIndex is the position of the transfer in its TransferBatch event, 0 for other events
+	BatchIndex uint64
+}
+
+// DecodeNFTTransfers decodes an erc-721 Transfer, or an erc-1155 TransferSingle or TransferBatch event into
+// its transfers. It returns false if the log is not an nft transfer, which includes erc-20 transfers that
+// share the topic of erc-721 transfers but do not index the value.
+func DecodeNFTTransfers(log *Log) ([]NFTTransfer, bool) {
+	if len(log.Topics) != 4 {
+		return nil, false
+	}
+	transfer := NFTTransfer{
+		Contract:        strings.ToLower(log.Address),
+		BlockNumber:     log.BlockNumber,
+		BlockHash:       log.BlockHash,
+		TransactionHash: log.TransactionHash,
+		LogIndex:        log.LogIndex,
+	}
+
+	switch log.Topics[0] {
+	case TransferEventTopic:
+		from, fromOk := topicAddress(log.Topics[1])
+		to, toOk := topicAddress(log.Topics[2])
+		tokenID, tokenOk := decodeWord(log.Topics[3])
+		if !fromOk || !toOk || !tokenOk || log.Data != "0x" {
+			return nil, false
+		}
+		transfer.Standard = NFTStandardERC721
+		transfer.From, transfer.To = from, to
+		transfer.TokenID, transfer.Amount = tokenID, big.NewInt(1)
+		return []NFTTransfer{transfer}, true
+
+	case TransferSingleEventTopic, TransferBatchEventTopic:
+		operator, operatorOk := topicAddress(log.Topics[1])
+		from, fromOk := topicAddress(log.Topics[2])
+		to, toOk := topicAddress(log.Topics[3])
+		data, dataOk := decodeHexData(log.Data)
+		if !operatorOk || !fromOk || !toOk || !dataOk {
+			return nil, false
+		}
+		transfer.Standard = NFTStandardERC1155
+		transfer.Operator, transfer.From, transfer.To = operator, from, to
+
+		var ids, amounts []*big.Int
+		if log.Topics[0] == TransferSingleEventTopic {
+			if len(data) != 64 {
+				return nil, false
+			}
+			ids = []*big.Int{new(big.Int).SetBytes(data[:32])}
+			amounts = []*big.Int{new(big.Int).SetBytes(data[32:])}
+		} else {
+			var idsOk, amountsOk bool
+			ids, idsOk = decodeUintArray(data, 0)
+			amounts, amountsOk = decodeUintArray(data, 1)
+			if !idsOk || !amountsOk || len(ids) != len(amounts) {
+				return nil, false
+			}
+		}
+
+		transfers := make([]NFTTransfer, len(ids))
+		for i := range ids {
+			transfers[i] = transfer
+			transfers[i].TokenID, transfers[i].Amount = ids[i], amounts[i]
+			transfers[i].BatchIndex = uint64(i)
+		}
+		return transfers, true
+	}
+	return nil, false
+}
+
+// decodeUintArray decodes the abi encoded uint256[] parameter at the given position of the data
+func decodeUintArray(data []byte, position int) ([]*big.Int, bool) {
+	offset, ok := readUint(data, position*32)
+	if !ok {
+		return nil, false
+	}
+	length, ok := readUint(data, offset)
+	if !ok || length > (len(data)-offset-32)/32 {
+		return nil, false
+	}
+	values := make([]*big.Int, length)
+	for i := range values {
+		start := offset + 32 + i*32
+		values[i] = new(big.Int).SetBytes(data[start : start+32])
+	}
+	return values, true
+}
+
+// readUint reads the 32 byte word at the given byte offset of the data as int, failing if it does not fit in the data
+func readUint(data []byte, offset int) (int, bool) {
+	if offset < 0 || offset > len(data)-32 {
+		return 0, false
+	}
+	word := new(big.Int).SetBytes(data[offset : offset+32])
+	if !word.IsInt64() || word.Int64() > int64(len(data)) {
+		return 0, false
+	}
+	return int(word.Int64()), true
+}
+
+// nftTransferJSON is the json encoding of an nft transfer, which encodes numbers as json-rpc quantities
+type nftTransferJSON struct {
+	Contract        string `json:"contract"`
+	Standard        string `json:"standard"`
+	Operator        string `json:"operator,omitempty"`
+	From            string `json:"from"`
+	To              string `json:"to"`
+	TokenID         string `json:"tokenId"`
+	Amount          string `json:"amount"`
+	BlockNumber     string `json:"blockNumber"`
+	BlockHash       string `json:"blockHash"`
+	TransactionHash string `json:"transactionHash"`
+	LogIndex        string `json:"logIndex"`
+	BatchIndex      string `json:"batchIndex"`
+}
+
+// MarshalJSON encodes the nft transfer with its numbers as json-rpc quantities
+func (t NFTTransfer) MarshalJSON() ([]byte, error) {
+	return json.Marshal(nftTransferJSON{
+		Contract:        t.Contract,
+		Standard:        string(t.Standard),
+		Operator:        t.Operator,
+		From:            t.From,
+		To:              t.To,
+		TokenID:         EncodeQuantity(t.TokenID),
+		Amount:          EncodeQuantity(t.Amount),
+		BlockNumber:     EncodeUint64Quantity(t.BlockNumber),
+		BlockHash:       t.BlockHash,
+		TransactionHash: t.TransactionHash,
+		LogIndex:        EncodeUint64Quantity(t.LogIndex),
+		BatchIndex:      EncodeUint64Quantity(t.BatchIndex),
+	})
+}
+
+// UnmarshalJSON decodes an nft transfer encoded by MarshalJSON
+func (t *NFTTransfer) UnmarshalJSON(data []byte) error {
+	var encoded nftTransferJSON
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+
+	decoded := NFTTransfer{
+		Contract:        encoded.Contract,
+		Standard:        NFTStandard(encoded.Standard),
+		Operator:        encoded.Operator,
+		From:            encoded.From,
+		To:              encoded.To,
+		BlockHash:       encoded.BlockHash,
+		TransactionHash: encoded.TransactionHash,
+	}
+	if decoded.Standard != NFTStandardERC721 && decoded.Standard != NFTStandardERC1155 {
+		return fmt.Errorf("invalid nft standard %q", encoded.Standard)
+	}
+	var err error
+	if decoded.TokenID, err = ParseQuantity(encoded.TokenID); err != nil {
+		return err
+	}
+	if decoded.Amount, err = ParseQuantity(encoded.Amount); err != nil {
+		return err
+	}
+	if decoded.BlockNumber, err = ParseUint64Quantity(encoded.BlockNumber); err != nil {
+		return err
+	}
+	if decoded.LogIndex, err = ParseUint64Quantity(encoded.LogIndex); err != nil {
+		return err
+	}
+	if decoded.BatchIndex, err = ParseUint64Quantity(encoded.BatchIndex); err != nil {
+		return err
+	}
+
+	*t = decoded
+	return nil
+}
@@ -0,0 +1,107 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeNFTTransfers(t *testing.T) {
+	topic := func(addr string) string { return "0x000000000000000000000000" + addr[2:] }
+	word := func(n int) string { return fmt.Sprintf("%064x", n) }
+	operator, from, to := "0x"+strings.Repeat("a", 40), "0x"+strings.Repeat("b", 40), "0x"+strings.Repeat("c", 40)
+
+	// describe returns the standard, parties, token id and amount of the transfers
+	describe := func(transfers []NFTTransfer) []string {
+		described := make([]string, 0)
+		for _, transfer := range transfers {
+			described = append(described, fmt.Sprintf("%s %s %s->%s #%s x%s @%d.%d", transfer.Standard, transfer.Operator,
+				transfer.From[:3], transfer.To[:3], transfer.TokenID, transfer.Amount, transfer.LogIndex, transfer.BatchIndex))
+		}
+		return described
+	}
+
+	tests := []struct {
+		name     string
+		log      Log
+		expected []string
+	}{
+		{
+			name:     "ERC721",
+			log:      Log{Topics: []string{TransferEventTopic, topic(from), topic(to), "0x" + word(42)}, Data: "0x"},
+			expected: []string{"erc721  0xb->0xc #42 x1 @7.0"},
+		},
+		{
+			name: "ERC20",
+			log:  Log{Topics: []string{TransferEventTopic, topic(from), topic(to)}, Data: "0x" + word(42)},
+		},
+		{
+			name:     "TransferSingle",
+			log:      Log{Topics: []string{TransferSingleEventTopic, topic(operator), topic(from), topic(to)}, Data: "0x" + word(5) + word(10)},
+			expected: []string{"erc1155 " + operator + " 0xb->0xc #5 x10 @7.0"},
+		},
+		{
+			name: "TransferBatch",
+			log: Log{
+				Topics: []string{TransferBatchEventTopic, topic(operator), topic(from), topic(to)},
+				Data:   "0x" + word(64) + word(160) + word(2) + word(1) + word(2) + word(2) + word(30) + word(40),
+			},
+			expected: []string{"erc1155 " + operator + " 0xb->0xc #1 x30 @7.0", "erc1155 " + operator + " 0xb->0xc #2 x40 @7.1"},
+		},
+		{
+			name: "TransferBatchLengthMismatch",
+			log: Log{
+				Topics: []string{TransferBatchEventTopic, topic(operator), topic(from), topic(to)},
+				Data:   "0x" + word(64) + word(160) + word(2) + word(1) + word(2) + word(1) + word(30),
+			},
+		},
+		{
+			name: "TransferBatchOutOfBounds",
+			log: Log{
+				Topics: []string{TransferBatchEventTopic, topic(operator), topic(from), topic(to)},
+				Data:   "0x" + word(64) + word(128) + word(1000) + word(1),
+			},
+		},
+		{
+			name: "TransferSingleShortData",
+			log:  Log{Topics: []string{TransferSingleEventTopic, topic(operator), topic(from), topic(to)}, Data: "0x" + word(5)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.log.Address = "0xC02AAA39B223FE8D0A0E5C4F27EAD9083C756CC2"
+			tt.log.BlockNumber, tt.log.BlockHash, tt.log.TransactionHash, tt.log.LogIndex = 5, "0xblock", "0xtx", 7
+
+			transfers, ok := DecodeNFTTransfers(&tt.log)
+			if ok != (tt.expected != nil) {
+				t.Fatalf("expected decoded %t, got %t", tt.expected != nil, ok)
+			}
+			if !ok {
+				return
+			}
+			if got := describe(transfers); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected transfers %v, got %v", tt.expected, got)
+			}
+			for _, transfer := range transfers {
+				if transfer.Contract != "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2" || transfer.BlockNumber != 5 ||
+					transfer.BlockHash != "0xblock" || transfer.TransactionHash != "0xtx" {
+					t.Errorf("unexpected transfer %+v", transfer)
+				}
+
+				encoded, err := json.Marshal(transfer)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var decoded NFTTransfer
+				if err := json.Unmarshal(encoded, &decoded); err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(decoded, transfer) {
+					t.Errorf("expected %+v after json round trip, got %+v", transfer, decoded)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package domain
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/aniladanir/ethereum-blockchain-parser/pkg/errs"
+)
+
+// weiPerEther is the number of wei in one ether
+var weiPerEther = big.NewInt(1_000_000_000_000_000_000)
+
+// ParseQuantity parses a json-rpc quantity, which is a 0x prefixed lowercase hex number without leading zeros
+func ParseQuantity(s string) (*big.Int, error) {
+	if err := validateQuantity(s); err != nil {
+		return nil, err
+	}
+	value, ok := new(big.Int).SetString(s[2:], 16)
+	if !ok {
+		return nil, fmt.Errorf("%w %q: invalid hex number", errs.InvalidQuantityErr(), s)
+	}
+	return value, nil
+}
+
+// ParseUint64Quantity parses a json-rpc quantity that fits into 64 bits, such as a block number or an index
+func ParseUint64Quantity(s string) (uint64, error) {
+	if err := validateQuantity(s); err != nil {
+		return 0, err
+	}
+	value, err := strconv.ParseUint(s[2:], 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w %q: %s", errs.InvalidQuantityErr(), s, err)
+	}
+	return value, nil
+}
+
+func validateQuantity(s string) error {
+	if !strings.HasPrefix(s, "0x") {
+		return fmt.Errorf("%w %q: missing 0x prefix", errs.InvalidQuantityErr(), s)
+	}
+	digits := s[2:]
+	if digits == "" {
+		return fmt.Errorf("%w %q: no digits", errs.InvalidQuantityErr(), s)
+	}
+	if len(digits) > 1 && digits[0] == '0' {
+		return fmt.Errorf("%w %q: leading zero", errs.InvalidQuantityErr(), s)
+	}
+	for i := 0; i < len(digits); i++ {
+		if !(digits[i] >= '0' && digits[i] <= '9' || digits[i] >= 'a' && digits[i] <= 'f') {
+			return fmt.Errorf("%w %q: invalid hex digit %q", errs.InvalidQuantityErr(), s, digits[i])
+		}
+	}
+	return nil
+}
+
+// EncodeQuantity returns the value as json-rpc quantity, or an empty string if the value is nil
+func EncodeQuantity(value *big.Int) string {
+	if value == nil {
+		return ""
+	}
+	return "0x" + value.Text(16)
+}
+
+// EncodeUint64Quantity returns the value as json-rpc quantity
+func EncodeUint64Quantity(value uint64) string {
+	return "0x" + strconv.FormatUint(value, 16)
+}
+
+// FormatEther formats an amount of wei as decimal amount of ether, without trailing zeros
+func FormatEther(wei *big.Int) string {
+	quotient, remainder := new(big.Int).QuoRem(wei, weiPerEther, new(big.Int))
+	sign := ""
+	if wei.Sign() < 0 {
+		sign = "-"
+		quotient.Abs(quotient)
+		remainder.Abs(remainder)
+	}
+	if remainder.Sign() == 0 {
+		return sign + quotient.String()
+	}
+	fraction := strings.TrimRight(fmt.Sprintf("%018s", remainder.String()), "0")
+	return sign + quotient.String() + "." + fraction
+}
@@ -0,0 +1,170 @@
+package domain
+
+import (
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/aniladanir/ethereum-blockchain-parser/pkg/errs"
+)
+
+func TestParseQuantity(t *testing.T) {
+	tests := []struct {
+		name          string
+		input         string
+		expected      string
+		expectInvalid bool
+	}{
+		{name: "Zero", input: "0x0", expected: "0"},
+		{name: "OneEther", input: "0xde0b6b3a7640000", expected: "1000000000000000000"},
+		{name: "Uint256", input: "0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff", expected: new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1)).String()},
+		{name: "MissingPrefix", input: "de0b6b3a7640000", expectInvalid: true},
+		{name: "Empty", input: "0x", expectInvalid: true},
+		{name: "LeadingZero", input: "0x0400", expectInvalid: true},
+		{name: "Uppercase", input: "0xDE0B", expectInvalid: true},
+		{name: "Decimal", input: "1000", expectInvalid: true},
+		{name: "Negative", input: "-0x1", expectInvalid: true},
+		{name: "InvalidDigit", input: "0x1g", expectInvalid: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := ParseQuantity(tt.input)
+			if tt.expectInvalid {
+				if !errs.IsInvalidQuantityErr(err) {
+					t.Errorf("expected invalid quantity error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if value.String() != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, value)
+			}
+			if encoded := EncodeQuantity(value); encoded != tt.input {
+				t.Errorf("expected encoding %s, got %s", tt.input, encoded)
+			}
+		})
+	}
+}
+
+func TestParseUint64Quantity(t *testing.T) {
+	tests := []struct {
+		name          string
+		input         string
+		expected      uint64
+		expectInvalid bool
+	}{
+		{name: "Zero", input: "0x0", expected: 0},
+		{name: "BlockNumber", input: "0x12d687", expected: 1234567},
+		{name: "Max", input: "0xffffffffffffffff", expected: 1<<64 - 1},
+		{name: "Overflow", input: "0x10000000000000000", expectInvalid: true},
+		{name: "LeadingZero", input: "0x01", expectInvalid: true},
+		{name: "MissingPrefix", input: "12", expectInvalid: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := ParseUint64Quantity(tt.input)
+			if tt.expectInvalid {
+				if !errs.IsInvalidQuantityErr(err) {
+					t.Errorf("expected invalid quantity error, got %v", err)
+				}
+				return
+			}
+			if err != nil || value != tt.expected {
+				t.Errorf("expected %d, got %d, %v", tt.expected, value, err)
+			}
+		})
+	}
+}
+
+func TestFormatEther(t *testing.T) {
+	tests := []struct {
+		name     string
+		wei      *big.Int
+		expected string
+	}{
+		{name: "Zero", wei: big.NewInt(0), expected: "0"},
+		{name: "OneWei", wei: big.NewInt(1), expected: "0.000000000000000001"},
+		{name: "OneEther", wei: big.NewInt(1_000_000_000_000_000_000), expected: "1"},
+		{name: "Fraction", wei: big.NewInt(1_500_000_000_000_000_000), expected: "1.5"},
+		{name: "Negative", wei: big.NewInt(-250_000_000_000_000_000), expected: "-0.25"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if formatted := FormatEther(tt.wei); formatted != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, formatted)
+			}
+		})
+	}
+}
+
+func TestTransactionJSON(t *testing.T) {
+	transaction := Transaction{
+		Hash:             "0xhash",
+		From:             "0xfrom",
+		To:               "0xto",
+		Value:            big.NewInt(1_000_000_000_000_000_000),
+		BlockNumber:      1234567,
+		TransactionIndex: 3,
+		Type:             2,
+		ChainID:          1,
+		Nonce:            7,
+		Gas:              21000,
+		GasPrice:         big.NewInt(1_000_000_000),
+		MaxFeePerGas:     big.NewInt(2_000_000_000),
+	}
+	expected := `{"hash":"0xhash","from":"0xfrom","to":"0xto","value":"0xde0b6b3a7640000","blockNumber":"0x12d687",` +
+		`"transactionIndex":"0x3","type":"0x2","chainId":"0x1","nonce":"0x7","gas":"0x5208","gasPrice":"0x3b9aca00",` +
+		`"maxFeePerGas":"0x77359400"}`
+
+	encoded, err := json.Marshal(transaction)
+	if err != nil || string(encoded) != expected {
+		t.Fatalf("expected %s, got %s, %v", expected, encoded, err)
+	}
+	var decoded Transaction
+	if err := json.Unmarshal(encoded, &decoded); err != nil || !reflect.DeepEqual(decoded, transaction) {
+		t.Errorf("expected %+v, got %+v, %v", transaction, decoded, err)
+	}
+
+	// transactions stored before the transaction details were added only have the basic fields
+	var legacy Transaction
+	if err := json.Unmarshal([]byte(`{"hash":"0xhash","from":"0xfrom","to":"0xto","value":"0x1","blockNumber":"0x2"}`), &legacy); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if legacy.Value.Cmp(big.NewInt(1)) != 0 || legacy.BlockNumber != 2 || legacy.GasPrice != nil {
+		t.Errorf("unexpected legacy transaction %+v", legacy)
+	}
+
+	if err := json.Unmarshal([]byte(`{"hash":"0xhash","value":"100"}`), &legacy); !errs.IsInvalidQuantityErr(err) {
+		t.Errorf("expected invalid quantity error, got %v", err)
+	}
+}
+
+func TestReceiptJSON(t *testing.T) {
+	receipt := Receipt{
+		TransactionHash:   "0xhash",
+		BlockHash:         "0xblock",
+		Status:            ReceiptStatusSuccessful,
+		GasUsed:           21000,
+		CumulativeGasUsed: 42000,
+		EffectiveGasPrice: big.NewInt(1_000_000_000),
+		Logs:              []Log{{Address: "0xcontract", Topics: []string{"0xtopic"}, Data: "0x", LogIndex: 1}},
+	}
+	expected := `{"transactionHash":"0xhash","blockHash":"0xblock","status":"0x1","gasUsed":"0x5208","cumulativeGasUsed":"0xa410",` +
+		`"effectiveGasPrice":"0x3b9aca00","logs":[{"address":"0xcontract","topics":["0xtopic"],"data":"0x","logIndex":"0x1"}]}`
+
+	encoded, err := json.Marshal(receipt)
+	if err != nil || string(encoded) != expected {
+		t.Fatalf("expected %s, got %s, %v", expected, encoded, err)
+	}
+	var decoded Receipt
+	if err := json.Unmarshal(encoded, &decoded); err != nil || !reflect.DeepEqual(decoded, receipt) {
+		t.Errorf("expected %+v, got %+v, %v", receipt, decoded, err)
+	}
+
+	if err := json.Unmarshal([]byte(`{"transactionHash":"0xhash","status":"0x2","gasUsed":"0x0","cumulativeGasUsed":"0x0"}`), &decoded); !errs.IsInvalidQuantityErr(err) {
+		t.Errorf("expected invalid quantity error, got %v", err)
+	}
+}
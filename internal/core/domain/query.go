@@ -0,0 +1,101 @@
+package domain
+
+import "math/big"
+
+// SortOrder is the order of the transactions in a query result
+type SortOrder string
+
+const (
+	SortAscending  SortOrder = "asc"
+	SortDescending SortOrder = "desc"
+)
+
+// Valid reports whether the order is supported
+func (o SortOrder) Valid() bool {
+	return o == SortAscending || o == SortDescending
+}
+
+// Direction selects transactions sent from or to the queried address
+type Direction string
+
+const (
+	DirectionAny Direction = ""
+	DirectionIn  Direction = "in"
+	DirectionOut Direction = "out"
+)
+
+// Valid reports whether the direction is supported
+func (d Direction) Valid() bool {
+	return d == DirectionAny || d == DirectionIn || d == DirectionOut
+}
+
+// TransactionQuery selects a page of the transactions of an address. Transactions are ordered by block number,
+// and by hash within a block. Zero values of the filters match every transaction.
+type TransactionQuery struct {
+	Address string
+	// FromBlock and ToBlock limit the inclusive block range of the transactions
+	FromBlock *int
+	ToBlock   *int
+	Direction Direction
+	// Counterparty is the address on the other side of the transactions
+	Counterparty string
+	// MinValue is the minimum transferred value in wei
+	MinValue *big.Int
+	Order    SortOrder
+	Limit    int
+	// Cursor is the NextCursor of the previous page, empty for the first page
+	Cursor string
+}
+
+// TransactionPage is a page of a transaction query
+type TransactionPage struct {
+	Transactions []Transaction `json:"transactions"`
+	// NextCursor is empty if there are no more transactions
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// TokenTransferQuery selects a page of the token transfers of an address. Transfers are ordered by block number,
+// and by log index within a block. Zero values of the filters match every transfer.
+type TokenTransferQuery struct {
+	Address string
+	// Token is the address of the token contract, empty for the transfers of every token
+	Token string
+	// FromBlock and ToBlock limit the inclusive block range of the transfers
+	FromBlock *int
+	ToBlock   *int
+	Direction Direction
+	Order     SortOrder
+	Limit     int
+	// Cursor is the NextCursor of the previous page, empty for the first page
+	Cursor string
+}
+
+// TokenTransferPage is a page of a token transfer query
+type TokenTransferPage struct {
+	TokenTransfers []TokenTransfer `json:"tokenTransfers"`
+	// NextCursor is empty if there are no more transfers
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// NFTTransferQuery selects a page of the nft transfers of an address. Transfers are ordered by block number,
+// by log index within a block and by position within an erc-1155 batch. Zero values of the filters match every transfer.
+type NFTTransferQuery struct {
+	Address string
+	// Contract is the address of the nft contract, empty for the transfers of every contract
+	Contract string
+	// FromBlock and ToBlock limit the inclusive block range of the transfers
+	FromBlock *int
+	ToBlock   *int
+	Direction Direction
+	Order     SortOrder
+	Limit     int
+	// Cursor is the NextCursor of the previous page, empty for the first page
+	Cursor string
+}
+
+// NFTTransferPage is a page of an nft transfer query
+type NFTTransferPage struct {
+	NFTTransfers []NFTTransfer `json:"nftTransfers"`
+	// NextCursor is empty if there are no more transfers
+	NextCursor string `json:"nextCursor,omitempty"`
+}
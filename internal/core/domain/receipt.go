@@ -0,0 +1,186 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/aniladanir/ethereum-blockchain-parser/pkg/errs"
+)
+
+// ReceiptStatus is the outcome of the execution of a transaction
+type ReceiptStatus uint8
+
+const (
+	// ReceiptStatusUnknown is the status of receipts before the Byzantium fork, which carry a state root instead
+	ReceiptStatusUnknown ReceiptStatus = iota
+	ReceiptStatusFailed
+	ReceiptStatusSuccessful
+)
+
+// String returns the name of the status
+func (s ReceiptStatus) String() string {
+	switch s {
+	case ReceiptStatusFailed:
+		return "failed"
+	case ReceiptStatusSuccessful:
+		return "success"
+	default:
+		return "unknown"
+	}
+}
+
+// Receipt is the result of the execution of a transaction included in a block
+type Receipt struct {
+	TransactionHash string
+	// BlockHash is the hash of the block the receipt belongs to, which differs between forks
+	BlockHash string
+	Status    ReceiptStatus
+	GasUsed   uint64
+	// CumulativeGasUsed is the gas used by the transaction and all transactions before it in the block
+	CumulativeGasUsed uint64
+	// EffectiveGasPrice is the price per gas paid by the sender in wei
+	EffectiveGasPrice *big.Int
+	// ContractAddress is the address of the created contract, empty if the transaction did not create one
+	ContractAddress string
+	Logs            []Log
+}
+
+// Log is an event emitted by a contract during the execution of a transaction
+type Log struct {
+	Address string
+	Topics  []string
+	Data    string
+	// LogIndex is the position of the log in its block
+	LogIndex        uint64
+	BlockNumber     uint64
+	BlockHash       string
+	TransactionHash string
+}
+
+// receiptJSON is the json encoding of a receipt, which encodes numbers as json-rpc quantities
+type receiptJSON struct {
+	TransactionHash   string    `json:"transactionHash"`
+	BlockHash         string    `json:"blockHash,omitempty"`
+	Status            string    `json:"status,omitempty"`
+	GasUsed           string    `json:"gasUsed"`
+	CumulativeGasUsed string    `json:"cumulativeGasUsed"`
+	EffectiveGasPrice string    `json:"effectiveGasPrice,omitempty"`
+	ContractAddress   string    `json:"contractAddress,omitempty"`
+	Logs              []logJSON `json:"logs,omitempty"`
+}
+
+type logJSON struct {
+	Address         string   `json:"address"`
+	Topics          []string `json:"topics"`
+	Data            string   `json:"data"`
+	LogIndex        string   `json:"logIndex"`
+	BlockNumber     string   `json:"blockNumber,omitempty"`
+	BlockHash       string   `json:"blockHash,omitempty"`
+	TransactionHash string   `json:"transactionHash,omitempty"`
+}
+
+// EncodeReceiptStatus returns the status as json-rpc quantity, or an empty string if it is unknown
+func EncodeReceiptStatus(status ReceiptStatus) string {
+	switch status {
+	case ReceiptStatusFailed:
+		return "0x0"
+	case ReceiptStatusSuccessful:
+		return "0x1"
+	default:
+		return ""
+	}
+}
+
+// ParseReceiptStatus parses a json-rpc receipt status, where an empty status is unknown
+func ParseReceiptStatus(s string) (ReceiptStatus, error) {
+	switch s {
+	case "":
+		return ReceiptStatusUnknown, nil
+	case "0x0":
+		return ReceiptStatusFailed, nil
+	case "0x1":
+		return ReceiptStatusSuccessful, nil
+	default:
+		return ReceiptStatusUnknown, fmt.Errorf("%w %q: invalid receipt status", errs.InvalidQuantityErr(), s)
+	}
+}
+
+// MarshalJSON encodes the receipt with its numbers as json-rpc quantities
+func (r Receipt) MarshalJSON() ([]byte, error) {
+	encoded := receiptJSON{
+		TransactionHash:   r.TransactionHash,
+		BlockHash:         r.BlockHash,
+		Status:            EncodeReceiptStatus(r.Status),
+		GasUsed:           EncodeUint64Quantity(r.GasUsed),
+		CumulativeGasUsed: EncodeUint64Quantity(r.CumulativeGasUsed),
+		EffectiveGasPrice: EncodeQuantity(r.EffectiveGasPrice),
+		ContractAddress:   r.ContractAddress,
+	}
+	for i := range r.Logs {
+		log := logJSON{
+			Address:         r.Logs[i].Address,
+			Topics:          r.Logs[i].Topics,
+			Data:            r.Logs[i].Data,
+			LogIndex:        EncodeUint64Quantity(r.Logs[i].LogIndex),
+			BlockHash:       r.Logs[i].BlockHash,
+			TransactionHash: r.Logs[i].TransactionHash,
+		}
+		// the block number is unknown for logs stored before it was added
+		if r.Logs[i].BlockNumber != 0 {
+			log.BlockNumber = EncodeUint64Quantity(r.Logs[i].BlockNumber)
+		}
+		encoded.Logs = append(encoded.Logs, log)
+	}
+	return json.Marshal(encoded)
+}
+
+// UnmarshalJSON decodes a receipt encoded by MarshalJSON
+func (r *Receipt) UnmarshalJSON(data []byte) error {
+	var encoded receiptJSON
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+
+	decoded := Receipt{
+		TransactionHash: encoded.TransactionHash,
+		BlockHash:       encoded.BlockHash,
+		ContractAddress: encoded.ContractAddress,
+	}
+	var err error
+	if decoded.Status, err = ParseReceiptStatus(encoded.Status); err != nil {
+		return err
+	}
+	if decoded.GasUsed, err = ParseUint64Quantity(encoded.GasUsed); err != nil {
+		return err
+	}
+	if decoded.CumulativeGasUsed, err = ParseUint64Quantity(encoded.CumulativeGasUsed); err != nil {
+		return err
+	}
+	if encoded.EffectiveGasPrice != "" {
+		if decoded.EffectiveGasPrice, err = ParseQuantity(encoded.EffectiveGasPrice); err != nil {
+			return err
+		}
+	}
+	for i := range encoded.Logs {
+		log := Log{
+			Address:         encoded.Logs[i].Address,
+			Topics:          encoded.Logs[i].Topics,
+			Data:            encoded.Logs[i].Data,
+			BlockHash:       encoded.Logs[i].BlockHash,
+			TransactionHash: encoded.Logs[i].TransactionHash,
+		}
+		if log.LogIndex, err = ParseUint64Quantity(encoded.Logs[i].LogIndex); err != nil {
+			return err
+		}
+		if encoded.Logs[i].BlockNumber != "" {
+			if log.BlockNumber, err = ParseUint64Quantity(encoded.Logs[i].BlockNumber); err != nil {
+				return err
+			}
+		}
+		decoded.Logs = append(decoded.Logs, log)
+	}
+
+	*r = decoded
+	return nil
+}
@@ -0,0 +1,7 @@
+package domain
+
+// Subscription represents an address observed by the transaction parser
+type Subscription struct {
+	Address Address `json:"address"`
+	Label   string  `json:"label,omitempty"`
+}
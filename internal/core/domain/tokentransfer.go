@@ -0,0 +1,166 @@
+package domain
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"strings"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// TransferEventTopic is the topic of the Transfer(address,address,uint256) event, emitted by erc-20 and erc-721 tokens
+var TransferEventTopic = EventTopic("Transfer(address,address,uint256)")
+
+// EventTopic returns the topic identifying the event with the given signature, which is the keccak-256 hash
+// of the signature as 0x prefixed hex
+func EventTopic(signature string) string {
+	hasher := sha3.NewLegacyKeccak256()
+	hasher.Write([]byte(signature))
+	return "0x" + hex.EncodeToString(hasher.Sum(nil))
+}
+
+// TokenTransfer is a transfer of erc-20 tokens, decoded from a Transfer event
+type TokenTransfer struct {
+	// Token is the address of the token contract
+	Token string
+	From  string
+	To    string
+	// Value is the transferred amount in the smallest unit of the token
+	Value           *big.Int
+	BlockNumber     uint64
+	BlockHash       string
+	TransactionHash string
+	// LogIndex is the position of the event in its block
+	LogIndex uint64
+}
+
+// DecodeTokenTransfer decodes an erc-20 Transfer event. It returns false if the log is not an erc-20 transfer,
+// which includes erc-721 transfers that share the event topic but index the token id.
+func DecodeTokenTransfer(log *Log) (TokenTransfer, bool) {
+	if len(log.Topics) != 3 || log.Topics[0] != TransferEventTopic {
+		return TokenTransfer{}, false
+	}
+	from, ok := topicAddress(log.Topics[1])
+	if !ok {
+		return TokenTransfer{}, false
+	}
+	to, ok := topicAddress(log.Topics[2])
+	if !ok {
+		return TokenTransfer{}, false
+	}
+	value, ok := decodeWord(log.Data)
+	if !ok {
+		return TokenTransfer{}, false
+	}
+	return TokenTransfer{
+		Token:           strings.ToLower(log.Address),
+		From:            from,
+		To:              to,
+		Value:           value,
+		BlockNumber:     log.BlockNumber,
+		BlockHash:       log.BlockHash,
+		TransactionHash: log.TransactionHash,
+		LogIndex:        log.LogIndex,
+	}, true
+}
+
+// topicAddress returns the address of an indexed address parameter, which is left padded to 32 bytes
+func topicAddress(topic string) (string, bool) {
+	word, ok := decodeHexWord(topic)
+	if !ok {
+		return "", false
+	}
+	for _, b := range word[:32-AddressLength] {
+		if b != 0 {
+			return "", false
+		}
+	}
+	return "0x" + hex.EncodeToString(word[32-AddressLength:]), true
+}
+
+// decodeWord decodes data consisting of a single 32 byte unsigned integer
+func decodeWord(data string) (*big.Int, bool) {
+	word, ok := decodeHexWord(data)
+	if !ok {
+		return nil, false
+	}
+	return new(big.Int).SetBytes(word), true
+}
+
+// decodeHexWord decodes 0x prefixed hex of exactly 32 bytes
+func decodeHexWord(s string) ([]byte, bool) {
+	word, ok := decodeHexData(s)
+	if !ok || len(word) != 32 {
+		return nil, false
+	}
+	return word, true
+}
+
+// decodeHexData decodes 0x prefixed hex
+func decodeHexData(s string) ([]byte, bool) {
+	digits, found := strings.CutPrefix(s, "0x")
+	if !found {
+		return nil, false
+	}
+	data, err := hex.DecodeString(digits)
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// tokenTransferJSON is the json encoding of a token transfer, which encodes numbers as json-rpc quantities
+type tokenTransferJSON struct {
+	Token           string `json:"token"`
+	From            string `json:"from"`
+	To              string `json:"to"`
+	Value           string `json:"value"`
+	BlockNumber     string `json:"blockNumber"`
+	BlockHash       string `json:"blockHash"`
+	TransactionHash string `json:"transactionHash"`
+	LogIndex        string `json:"logIndex"`
+}
+
+// MarshalJSON encodes the token transfer with its numbers as json-rpc quantities
+func (t TokenTransfer) MarshalJSON() ([]byte, error) {
+	return json.Marshal(tokenTransferJSON{
+		Token:           t.Token,
+		From:            t.From,
+		To:              t.To,
+		Value:           EncodeQuantity(t.Value),
+		BlockNumber:     EncodeUint64Quantity(t.BlockNumber),
+		BlockHash:       t.BlockHash,
+		TransactionHash: t.TransactionHash,
+		LogIndex:        EncodeUint64Quantity(t.LogIndex),
+	})
+}
+
+// UnmarshalJSON decodes a token transfer encoded by MarshalJSON
+func (t *TokenTransfer) UnmarshalJSON(data []byte) error {
+	var encoded tokenTransferJSON
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+
+	decoded := TokenTransfer{
+		Token:           encoded.Token,
+		From:            encoded.From,
+		To:              encoded.To,
+		BlockHash:       encoded.BlockHash,
+		TransactionHash: encoded.TransactionHash,
+	}
+	var err error
+	if decoded.Value, err = ParseQuantity(encoded.Value); err != nil {
+		return err
+	}
+	if decoded.BlockNumber, err = ParseUint64Quantity(encoded.BlockNumber); err != nil {
+		return err
+	}
+	if decoded.LogIndex, err = ParseUint64Quantity(encoded.LogIndex); err != nil {
+		return err
+	}
+
+	*t = decoded
+	return nil
+}
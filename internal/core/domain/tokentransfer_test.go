@@ -0,0 +1,69 @@
+package domain
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEventTopics(t *testing.T) {
+	for topic, expected := range map[string]string{
+		TransferEventTopic:       "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef",
+		TransferSingleEventTopic: "0xc3d58168c5ae7397731d063d5bbf3d657854427343f4c083240f7aacaa2d0f62",
+		TransferBatchEventTopic:  "0x4a39dc06d4c0dbc64b70af90fd698a233a518aa5d07e595d983b8c0526c8f7fb",
+	} {
+		if topic != expected {
+			t.Errorf("expected topic %s, got %s", expected, topic)
+		}
+	}
+}
+
+func TestDecodeTokenTransfer(t *testing.T) {
+	from := "0x000000000000000000000000" + strings.Repeat("a", 40)
+	to := "0x000000000000000000000000" + strings.Repeat("b", 40)
+	value := "0x" + strings.Repeat("0", 48) + "0de0b6b3a7640000"
+
+	tests := []struct {
+		name     string
+		log      Log
+		expected bool
+	}{
+		{name: "Transfer", log: Log{Topics: []string{TransferEventTopic, from, to}, Data: value}, expected: true},
+		{name: "OtherEvent", log: Log{Topics: []string{EventTopic("Approval(address,address,uint256)"), from, to}, Data: value}},
+		{name: "ERC721", log: Log{Topics: []string{TransferEventTopic, from, to, value}, Data: "0x"}},
+		{name: "DirtyAddressPadding", log: Log{Topics: []string{TransferEventTopic, "0x1" + from[3:], to}, Data: value}},
+		{name: "ShortData", log: Log{Topics: []string{TransferEventTopic, from, to}, Data: "0x01"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.log.Address = "0xC02AAA39B223FE8D0A0E5C4F27EAD9083C756CC2"
+			tt.log.BlockNumber, tt.log.BlockHash, tt.log.TransactionHash, tt.log.LogIndex = 5, "0xblock", "0xtx", 7
+
+			transfer, ok := DecodeTokenTransfer(&tt.log)
+			if ok != tt.expected {
+				t.Fatalf("expected decoded %t, got %t", tt.expected, ok)
+			}
+			if !ok {
+				return
+			}
+			if transfer.Token != "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2" || transfer.From != "0x"+strings.Repeat("a", 40) ||
+				transfer.To != "0x"+strings.Repeat("b", 40) || transfer.Value.String() != "1000000000000000000" ||
+				transfer.BlockNumber != 5 || transfer.BlockHash != "0xblock" || transfer.TransactionHash != "0xtx" || transfer.LogIndex != 7 {
+				t.Errorf("unexpected transfer %+v", transfer)
+			}
+
+			encoded, err := json.Marshal(transfer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var decoded TokenTransfer
+			if err := json.Unmarshal(encoded, &decoded); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(decoded, transfer) {
+				t.Errorf("expected %+v after json round trip, got %+v", transfer, decoded)
+			}
+		})
+	}
+}
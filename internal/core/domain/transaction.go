@@ -1,10 +1,175 @@
 package domain
 
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/aniladanir/ethereum-blockchain-parser/pkg/errs"
+)
+
 // Transaction represents a transaction in the blockchain.
+// Amounts are in wei, and amounts that are not part of every transaction type are nil when absent.
 type Transaction struct {
-	Hash        string `json:"hash"`
-	From        string `json:"from"`
-	To          string `json:"to"`
-	Value       string `json:"value"`
-	BlockNumber string `json:"blockNumber"`
+	Hash             string
+	From             string
+	To               string
+	Value            *big.Int
+	BlockNumber      uint64
+	BlockHash        string
+	TransactionIndex uint64
+	Type             uint8
+	// ChainID is zero for legacy transactions without replay protection
+	ChainID uint64
+	Nonce   uint64
+	Gas     uint64
+	// GasPrice is the effective gas price for dynamic fee transactions included in a block
+	GasPrice *big.Int
+	// MaxFeePerGas and MaxPriorityFeePerGas are set for dynamic fee (EIP-1559) and blob transactions
+	MaxFeePerGas         *big.Int
+	MaxPriorityFeePerGas *big.Int
+	AccessList           []AccessTuple
+	// MaxFeePerBlobGas and BlobVersionedHashes are set for blob (EIP-4844) transactions
+	MaxFeePerBlobGas    *big.Int
+	BlobVersionedHashes []string
+	Input               string
+	V                   string
+	R                   string
+	S                   string
+	YParity             string
+	// Receipt is the result of the execution, nil if receipts are not fetched
+	Receipt *Receipt
+}
+
+// AccessTuple is an address and the storage keys a transaction declares to access (EIP-2930)
+type AccessTuple struct {
+	Address     string   `json:"address"`
+	StorageKeys []string `json:"storageKeys"`
+}
+
+// transactionJSON is the json encoding of a transaction, which encodes numbers as json-rpc quantities
+type transactionJSON struct {
+	Hash                 string        `json:"hash"`
+	From                 string        `json:"from"`
+	To                   string        `json:"to"`
+	Value                string        `json:"value"`
+	BlockNumber          string        `json:"blockNumber"`
+	BlockHash            string        `json:"blockHash,omitempty"`
+	TransactionIndex     string        `json:"transactionIndex,omitempty"`
+	Type                 string        `json:"type,omitempty"`
+	ChainID              string        `json:"chainId,omitempty"`
+	Nonce                string        `json:"nonce,omitempty"`
+	Gas                  string        `json:"gas,omitempty"`
+	GasPrice             string        `json:"gasPrice,omitempty"`
+	MaxFeePerGas         string        `json:"maxFeePerGas,omitempty"`
+	MaxPriorityFeePerGas string        `json:"maxPriorityFeePerGas,omitempty"`
+	AccessList           []AccessTuple `json:"accessList,omitempty"`
+	MaxFeePerBlobGas     string        `json:"maxFeePerBlobGas,omitempty"`
+	BlobVersionedHashes  []string      `json:"blobVersionedHashes,omitempty"`
+	Input                string        `json:"input,omitempty"`
+	V                    string        `json:"v,omitempty"`
+	R                    string        `json:"r,omitempty"`
+	S                    string        `json:"s,omitempty"`
+	YParity              string        `json:"yParity,omitempty"`
+	Receipt              *Receipt      `json:"receipt,omitempty"`
+}
+
+// MarshalJSON encodes the transaction with its numbers as json-rpc quantities
+func (t Transaction) MarshalJSON() ([]byte, error) {
+	encoded := transactionJSON{
+		Hash:                 t.Hash,
+		From:                 t.From,
+		To:                   t.To,
+		Value:                EncodeQuantity(t.Value),
+		BlockNumber:          EncodeUint64Quantity(t.BlockNumber),
+		BlockHash:            t.BlockHash,
+		TransactionIndex:     EncodeUint64Quantity(t.TransactionIndex),
+		Type:                 EncodeUint64Quantity(uint64(t.Type)),
+		Nonce:                EncodeUint64Quantity(t.Nonce),
+		Gas:                  EncodeUint64Quantity(t.Gas),
+		GasPrice:             EncodeQuantity(t.GasPrice),
+		MaxFeePerGas:         EncodeQuantity(t.MaxFeePerGas),
+		MaxPriorityFeePerGas: EncodeQuantity(t.MaxPriorityFeePerGas),
+		AccessList:           t.AccessList,
+		MaxFeePerBlobGas:     EncodeQuantity(t.MaxFeePerBlobGas),
+		BlobVersionedHashes:  t.BlobVersionedHashes,
+		Input:                t.Input,
+		V:                    t.V,
+		R:                    t.R,
+		S:                    t.S,
+		YParity:              t.YParity,
+		Receipt:              t.Receipt,
+	}
+	if t.ChainID != 0 {
+		encoded.ChainID = EncodeUint64Quantity(t.ChainID)
+	}
+	return json.Marshal(encoded)
+}
+
+// UnmarshalJSON decodes a transaction encoded by MarshalJSON. Absent quantities are decoded as zero or nil.
+func (t *Transaction) UnmarshalJSON(data []byte) error {
+	var encoded transactionJSON
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+
+	decoded := Transaction{
+		Hash:                encoded.Hash,
+		From:                encoded.From,
+		To:                  encoded.To,
+		BlockHash:           encoded.BlockHash,
+		AccessList:          encoded.AccessList,
+		BlobVersionedHashes: encoded.BlobVersionedHashes,
+		Input:               encoded.Input,
+		V:                   encoded.V,
+		R:                   encoded.R,
+		S:                   encoded.S,
+		YParity:             encoded.YParity,
+		Receipt:             encoded.Receipt,
+	}
+	var err error
+	var txType uint64
+	for _, field := range []struct {
+		value string
+		dest  *uint64
+	}{
+		{value: encoded.BlockNumber, dest: &decoded.BlockNumber},
+		{value: encoded.TransactionIndex, dest: &decoded.TransactionIndex},
+		{value: encoded.Type, dest: &txType},
+		{value: encoded.ChainID, dest: &decoded.ChainID},
+		{value: encoded.Nonce, dest: &decoded.Nonce},
+		{value: encoded.Gas, dest: &decoded.Gas},
+	} {
+		if field.value == "" {
+			continue
+		}
+		if *field.dest, err = ParseUint64Quantity(field.value); err != nil {
+			return err
+		}
+	}
+	if txType > math.MaxUint8 {
+		return fmt.Errorf("%w: transaction type %d out of range", errs.InvalidQuantityErr(), txType)
+	}
+	decoded.Type = uint8(txType)
+	for _, field := range []struct {
+		value string
+		dest  **big.Int
+	}{
+		{value: encoded.Value, dest: &decoded.Value},
+		{value: encoded.GasPrice, dest: &decoded.GasPrice},
+		{value: encoded.MaxFeePerGas, dest: &decoded.MaxFeePerGas},
+		{value: encoded.MaxPriorityFeePerGas, dest: &decoded.MaxPriorityFeePerGas},
+		{value: encoded.MaxFeePerBlobGas, dest: &decoded.MaxFeePerBlobGas},
+	} {
+		if field.value == "" {
+			continue
+		}
+		if *field.dest, err = ParseQuantity(field.value); err != nil {
+			return err
+		}
+	}
+
+	*t = decoded
+	return nil
 }
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"strings"
+
+	"github.com/aniladanir/ethereum-blockchain-parser/internal/core/domain"
+)
+
+// addressIndex maps normalized addresses to their subscribed form, so that transactions can be matched
+// against the subscriptions in constant time regardless of the number of subscribed addresses.
+type addressIndex map[string]string
+
+func newAddressIndex(addresses []string) addressIndex {
+	index := make(addressIndex, len(addresses))
+	for _, addr := range addresses {
+		index[normalizeAddress(addr)] = addr
+	}
+	return index
+}
+
+// match returns the subscribed addresses the transaction is sent from or to
+func (idx addressIndex) match(tx *domain.Transaction) []string {
+	return idx.matchParties(tx.From, tx.To)
+}
+
+// matchTokenTransfer returns the subscribed addresses the token transfer is sent from or to
+func (idx addressIndex) matchTokenTransfer(transfer *domain.TokenTransfer) []string {
+	return idx.matchParties(transfer.From, transfer.To)
+}
+
+// matchNFTTransfer returns the subscribed addresses the nft transfer is sent from or to
+func (idx addressIndex) matchNFTTransfer(transfer *domain.NFTTransfer) []string {
+	return idx.matchParties(transfer.From, transfer.To)
+}
+
+func (idx addressIndex) matchParties(from, to string) []string {
+	var matches []string
+	if addr, ok := idx[normalizeAddress(from)]; ok {
+		matches = append(matches, addr)
+	}
+	if addr, ok := idx[normalizeAddress(to)]; ok && (len(matches) == 0 || matches[0] != addr) {
+		matches = append(matches, addr)
+	}
+	return matches
+}
+
+// normalizeAddress returns the canonical lowercase form of a hex address
+func normalizeAddress(address string) string {
+	return strings.ToLower(address)
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aniladanir/ethereum-blockchain-parser/internal/core/domain"
+)
+
+func TestAddressIndexMatch(t *testing.T) {
+	index := newAddressIndex([]string{"0xAbC", "0xdef"})
+	tests := []struct {
+		name     string
+		tx       domain.Transaction
+		expected []string
+	}{
+		{
+			name:     "From",
+			tx:       domain.Transaction{From: "0xabc", To: "0x123"},
+			expected: []string{"0xAbC"},
+		},
+		{
+			name:     "ToDifferentCase",
+			tx:       domain.Transaction{From: "0x123", To: "0xDEF"},
+			expected: []string{"0xdef"},
+		},
+		{
+			name:     "FromAndTo",
+			tx:       domain.Transaction{From: "0xabc", To: "0xdef"},
+			expected: []string{"0xAbC", "0xdef"},
+		},
+		{
+			name:     "SelfTransfer",
+			tx:       domain.Transaction{From: "0xabc", To: "0xABC"},
+			expected: []string{"0xAbC"},
+		},
+		{
+			name:     "NoMatch",
+			tx:       domain.Transaction{From: "0x123", To: ""},
+			expected: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches := index.match(&tt.tx)
+			if len(matches) != len(tt.expected) {
+				t.Fatalf("expected matches %v, got %v", tt.expected, matches)
+			}
+			for i := range matches {
+				if matches[i] != tt.expected[i] {
+					t.Errorf("expected match %s, got %s", tt.expected[i], matches[i])
+				}
+			}
+		})
+	}
+}
+
+const (
+	benchSubscriptions = 100_000
+	benchBlockSize     = 200
+)
+
+func benchAddresses(n int) []string {
+	addresses := make([]string, n)
+	for i := range addresses {
+		addresses[i] = fmt.Sprintf("0x%040x", i)
+	}
+	return addresses
+}
+
+// benchTransactions returns transactions of which every tenth is sent to a subscribed address
+func benchTransactions(n int) []domain.Transaction {
+	transactions := make([]domain.Transaction, n)
+	for i := range transactions {
+		transactions[i] = domain.Transaction{
+			Hash: fmt.Sprintf("0x%064x", i),
+			From: fmt.Sprintf("0x%040x", benchSubscriptions+i),
+			To:   fmt.Sprintf("0x%040x", benchSubscriptions+i+1),
+		}
+		if i%10 == 0 {
+			transactions[i].To = fmt.Sprintf("0x%040x", i*100)
+		}
+	}
+	return transactions
+}
+
+func BenchmarkAddressIndexMatch(b *testing.B) {
+	index := newAddressIndex(benchAddresses(benchSubscriptions))
+	transactions := benchTransactions(benchBlockSize)
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for i := range transactions {
+			_ = index.match(&transactions[i])
+		}
+	}
+	b.ReportMetric(float64(b.N*len(transactions))/b.Elapsed().Seconds(), "txs/s")
+}
+
+// BenchmarkLinearMatch is the previous approach of comparing every transaction to every address, for reference
+func BenchmarkLinearMatch(b *testing.B) {
+	addresses := benchAddresses(benchSubscriptions)
+	transactions := benchTransactions(benchBlockSize)
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for i := range transactions {
+			for _, addr := range addresses {
+				if transactions[i].From == addr || transactions[i].To == addr {
+					break
+				}
+			}
+		}
+	}
+	b.ReportMetric(float64(b.N*len(transactions))/b.Elapsed().Seconds(), "txs/s")
+}
+
+func BenchmarkIngestBlocks(b *testing.B) {
+	const blocks = 100
+
+	ctx := context.Background()
+	tp, chain, repo := setupTest(b, 0)
+	for _, addr := range benchAddresses(benchSubscriptions) {
+		if err := repo.AddAddress(ctx, addr); err != nil {
+			b.Fatal(err)
+		}
+	}
+	parent := "a0"
+	for block := 1; block <= blocks; block++ {
+		parent = chain.addBlock(block, "a", parent, benchTransactions(benchBlockSize)...)
+	}
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if err := repo.SetBlockNumber(ctx, 0); err != nil {
+			b.Fatal(err)
+		}
+		tp.processBlocksUntil(ctx, blocks)
+	}
+	b.ReportMetric(float64(b.N*blocks*benchBlockSize)/b.Elapsed().Seconds(), "txs/s")
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+
+	"github.com/aniladanir/ethereum-blockchain-parser/internal/core/domain"
+)
+
+// blockRange is the result of fetching the blocks in the inclusive range [from, to]
+type blockRange struct {
+	from   int
+	to     int
+	blocks []*domain.Block
+	err    error
+}
+
+// fetchBlocks fetches the blocks in the inclusive range [from, to] in ranges of blockFetchRangeSize,
+// using up to fetchParallelism concurrent requests. Ranges are delivered in block order on the returned channel.
+// If receipts are enabled, the transactions matching the subscriptions come with their receipts.
+// If token or nft transfers are enabled, the blocks come with their transfers.
+//
+// At most fetchParallelism ranges are fetched or waiting to be consumed at any time, so memory stays bounded
+// no matter how far behind the parser is. The caller must cancel ctx if it stops consuming before the channel is closed.
+func (tp *transactionParser) fetchBlocks(ctx context.Context, from, to int, subscriptions addressIndex) <-chan blockRange {
+	// every range gets its own result channel, queued in order of the ranges
+	pending := make(chan chan blockRange, max(tp.fetchParallelism-1, 0))
+	go func() {
+		defer close(pending)
+		for rangeStart := from; rangeStart <= to; rangeStart += blockFetchRangeSize {
+			rangeEnd := min(rangeStart+blockFetchRangeSize-1, to)
+
+			result := make(chan blockRange, 1)
+			select {
+			case pending <- result:
+			case <-ctx.Done():
+				return
+			}
+
+			go func() {
+				blocks, err := tp.bcClient.FetchBlocksByRange(ctx, rangeStart, rangeEnd)
+				if err == nil && tp.receipts {
+					err = tp.fetchReceipts(ctx, blocks, subscriptions)
+				}
+				if err == nil && (tp.tokenTransfers || tp.nftTransfers) {
+					err = tp.fetchTransfers(ctx, blocks)
+				}
+				result <- blockRange{from: rangeStart, to: rangeEnd, blocks: blocks, err: err}
+			}()
+		}
+	}()
+
+	ranges := make(chan blockRange)
+	go func() {
+		defer close(ranges)
+		for result := range pending {
+			select {
+			case ranges <- <-result:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ranges
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aniladanir/ethereum-blockchain-parser/internal/core/domain"
+)
+
+// slowChain delays fetches of earlier ranges longer and records the peak number of concurrent fetches
+type slowChain struct {
+	*mockChain
+	inFlight atomic.Int32
+	peak     atomic.Int32
+}
+
+func (c *slowChain) FetchBlocksByRange(ctx context.Context, from, to int) ([]*domain.Block, error) {
+	current := c.inFlight.Add(1)
+	defer c.inFlight.Add(-1)
+	for {
+		peak := c.peak.Load()
+		if current <= peak || c.peak.CompareAndSwap(peak, current) {
+			break
+		}
+	}
+
+	time.Sleep(time.Millisecond * time.Duration(10-from/blockFetchRangeSize%10))
+	return c.mockChain.FetchBlocksByRange(ctx, from, to)
+}
+
+func TestFetchBlocks(t *testing.T) {
+	tp, chain, _ := setupTest(t, 0)
+	parent := "a0"
+	for block := 1; block <= 2000; block++ {
+		parent = chain.addBlock(block, "a", parent)
+	}
+	slow := &slowChain{mockChain: chain}
+	tp.bcClient = slow
+	tp.fetchParallelism = 3
+
+	next := 1
+	for fetched := range tp.fetchBlocks(context.Background(), 1, 2000, nil) {
+		if fetched.err != nil {
+			t.Fatalf("expected no error, got %v", fetched.err)
+		}
+		if fetched.from != next {
+			t.Fatalf("expected range starting at %d, got %d", next, fetched.from)
+		}
+		for i, block := range fetched.blocks {
+			if expected := uint64(fetched.from + i); block.Number != expected {
+				t.Fatalf("expected block %d, got %d", expected, block.Number)
+			}
+		}
+		next = fetched.to + 1
+	}
+
+	if next != 2001 {
+		t.Errorf("expected all blocks up to 2000 to be fetched, stopped at %d", next-1)
+	}
+	if peak := slow.peak.Load(); peak > 3 {
+		t.Errorf("expected at most 3 concurrent fetches, got %d", peak)
+	}
+}
+
+func TestFetchBlocksCancel(t *testing.T) {
+	tp, chain, _ := setupTest(t, 0)
+	parent := "a0"
+	for block := 1; block <= 1000; block++ {
+		parent = chain.addBlock(block, "a", parent)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ranges := tp.fetchBlocks(ctx, 1, 1000, nil)
+	<-ranges
+	cancel()
+
+	// the channel must be closed soon after cancellation
+	select {
+	case <-drain(ranges):
+	case <-time.After(time.Second * 5):
+		t.Fatal("expected fetch pipeline to stop after cancellation")
+	}
+}
+
+func drain(ranges <-chan blockRange) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		for range ranges {
+		}
+		close(done)
+	}()
+	return done
+}
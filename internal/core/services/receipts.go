@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aniladanir/ethereum-blockchain-parser/internal/core/domain"
+	"github.com/aniladanir/ethereum-blockchain-parser/pkg/errs"
+)
+
+// rpcMethodNotFound is the json-rpc error code of methods the node does not support
+const rpcMethodNotFound = -32601
+
+// fetchReceipts attaches the receipts of the transactions that match the subscriptions to the blocks.
+// Blocks with a single matching transaction fetch its receipt alone, other blocks fetch all receipts of the block
+// at once, falling back to one request per transaction if the node does not support eth_getBlockReceipts.
+func (tp *transactionParser) fetchReceipts(ctx context.Context, blocks []*domain.Block, subscriptions addressIndex) error {
+	for _, block := range blocks {
+		matched := make([]*domain.Transaction, 0)
+		for i := range block.Transactions {
+			if len(subscriptions.match(&block.Transactions[i])) > 0 {
+				matched = append(matched, &block.Transactions[i])
+			}
+		}
+		if len(matched) == 0 {
+			continue
+		}
+
+		if len(matched) > 1 {
+			receipts, err := tp.bcClient.FetchBlockReceipts(ctx, int(block.Number))
+			if err == nil {
+				if err := attachBlockReceipts(block, matched, receipts); err != nil {
+					return err
+				}
+				continue
+			}
+			if rpcErr, ok := errs.AsRpcErr(err); !ok || rpcErr.Code != rpcMethodNotFound {
+				return fmt.Errorf("could not fetch receipts of block %d: %w", block.Number, err)
+			}
+		}
+
+		for _, tx := range matched {
+			receipt, err := tp.bcClient.FetchTransactionReceipt(ctx, tx.Hash)
+			if errs.IsNotFoundErr(err) {
+				// the node has not indexed the block yet, it will be fetched again in the next cycle
+				return fmt.Errorf("receipt of transaction %s is not available: %w", tx.Hash, errs.BlockNotFoundErr())
+			}
+			if err != nil {
+				return fmt.Errorf("could not fetch receipt of transaction %s: %w", tx.Hash, err)
+			}
+			if err := checkReceiptBlock(block, receipt); err != nil {
+				return err
+			}
+			tx.Receipt = receipt
+		}
+	}
+	return nil
+}
+
+// attachBlockReceipts attaches the receipts of a block to the matched transactions of the block
+func attachBlockReceipts(block *domain.Block, matched []*domain.Transaction, receipts []domain.Receipt) error {
+	byHash := make(map[string]*domain.Receipt, len(receipts))
+	for i := range receipts {
+		if err := checkReceiptBlock(block, &receipts[i]); err != nil {
+			return err
+		}
+		byHash[receipts[i].TransactionHash] = &receipts[i]
+	}
+	for _, tx := range matched {
+		receipt, ok := byHash[tx.Hash]
+		if !ok {
+			return fmt.Errorf("receipts of block %d do not contain transaction %s", block.Number, tx.Hash)
+		}
+		tx.Receipt = receipt
+	}
+	return nil
+}
+
+// checkReceiptBlock fails with a block not found error if the receipt belongs to another fork than the block,
+// which happens if the block was reorganized between fetching the block and its receipts
+func checkReceiptBlock(block *domain.Block, receipt *domain.Receipt) error {
+	if receipt.BlockHash != block.Hash {
+		return fmt.Errorf("receipt of transaction %s belongs to block %s instead of %s: %w",
+			receipt.TransactionHash, receipt.BlockHash, block.Hash, errs.BlockNotFoundErr())
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/aniladanir/ethereum-blockchain-parser/internal/core/domain"
+)
+
+func TestProcessBlocksReceipts(t *testing.T) {
+	tests := []struct {
+		name                     string
+		receipts                 bool
+		blockReceiptsUnsupported bool
+		// transactions are the transactions of block 101 matching subscribed 0xa
+		transactions     []string
+		expectedReceipts bool
+		expectedRequests map[string]int
+	}{
+		{
+			name:             "Disabled",
+			transactions:     []string{"tx1", "tx2"},
+			expectedRequests: map[string]int{},
+		},
+		{
+			name:             "SingleTransaction",
+			receipts:         true,
+			transactions:     []string{"tx1"},
+			expectedReceipts: true,
+			expectedRequests: map[string]int{"eth_getTransactionReceipt": 1},
+		},
+		{
+			name:             "BlockReceipts",
+			receipts:         true,
+			transactions:     []string{"tx1", "tx2"},
+			expectedReceipts: true,
+			expectedRequests: map[string]int{"eth_getBlockReceipts": 1},
+		},
+		{
+			name:                     "BlockReceiptsUnsupported",
+			receipts:                 true,
+			blockReceiptsUnsupported: true,
+			transactions:             []string{"tx1", "tx2"},
+			expectedReceipts:         true,
+			expectedRequests:         map[string]int{"eth_getBlockReceipts": 1, "eth_getTransactionReceipt": 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			tp, chain, repo := setupTest(t, 100, "0xa")
+			WithReceipts(tt.receipts)(tp)
+			chain.blockReceiptsUnsupported = tt.blockReceiptsUnsupported
+
+			transactions := []domain.Transaction{{Hash: "unrelated", From: "0xb", To: "0xc"}}
+			for i, hash := range tt.transactions {
+				transactions = append(transactions, domain.Transaction{Hash: hash, From: "0xb", To: "0xa"})
+				chain.receipts[hash] = domain.Receipt{
+					TransactionHash:   hash,
+					Status:            domain.ReceiptStatusSuccessful,
+					GasUsed:           21000,
+					CumulativeGasUsed: uint64(21000 * (i + 2)),
+					EffectiveGasPrice: big.NewInt(1_000_000_000),
+				}
+			}
+			chain.receipts["unrelated"] = domain.Receipt{TransactionHash: "unrelated", Status: domain.ReceiptStatusFailed}
+			chain.addBlock(101, "a", "a100", transactions...)
+			tp.processNewBlocks(ctx)
+
+			stored, _ := repo.GetTransactions(ctx, "0xa")
+			if len(stored) != len(tt.transactions) {
+				t.Fatalf("expected %d transactions, got %d", len(tt.transactions), len(stored))
+			}
+			for i := range stored {
+				if !tt.expectedReceipts {
+					if stored[i].Receipt != nil {
+						t.Errorf("expected no receipt for %s, got %+v", stored[i].Hash, stored[i].Receipt)
+					}
+					continue
+				}
+				if stored[i].Receipt == nil || stored[i].Receipt.TransactionHash != stored[i].Hash ||
+					stored[i].Receipt.Status != domain.ReceiptStatusSuccessful {
+					t.Errorf("expected successful receipt for %s, got %+v", stored[i].Hash, stored[i].Receipt)
+				}
+			}
+			if len(chain.receiptRequests) != len(tt.expectedRequests) {
+				t.Errorf("expected receipt requests %v, got %v", tt.expectedRequests, chain.receiptRequests)
+			}
+			for method, count := range tt.expectedRequests {
+				if chain.receiptRequests[method] != count {
+					t.Errorf("expected %d %s requests, got %d", count, method, chain.receiptRequests[method])
+				}
+			}
+		})
+	}
+}
+
+func TestProcessBlocksMissingReceipt(t *testing.T) {
+	ctx := context.Background()
+	tp, chain, repo := setupTest(t, 100, "0xa")
+	WithReceipts(true)(tp)
+
+	chain.addBlock(101, "a", "a100", domain.Transaction{Hash: "tx1", From: "0xb", To: "0xa"})
+	tp.processNewBlocks(ctx)
+
+	// the block is retried in the next cycle once the node has the receipt
+	if blockNumber, _ := repo.GetBlockNumber(ctx); blockNumber != 100 {
+		t.Errorf("expected block number 100, got %d", blockNumber)
+	}
+	if transactions, _ := repo.GetTransactions(ctx, "0xa"); len(transactions) != 0 {
+		t.Errorf("expected no transactions, got %v", transactions)
+	}
+
+	chain.receipts["tx1"] = domain.Receipt{TransactionHash: "tx1", Status: domain.ReceiptStatusFailed, GasUsed: 30000}
+	tp.processNewBlocks(ctx)
+
+	transactions, _ := repo.GetTransactions(ctx, "0xa")
+	if len(transactions) != 1 || transactions[0].Receipt == nil || transactions[0].Receipt.Status != domain.ReceiptStatusFailed {
+		t.Errorf("expected transaction with failed receipt, got %+v", transactions)
+	}
+}
+
+func TestProcessBlocksReceiptsReorg(t *testing.T) {
+	tests := []struct {
+		name         string
+		transactions []string
+	}{
+		{
+			name:         "TransactionReceipt",
+			transactions: []string{"tx1"},
+		},
+		{
+			name:         "BlockReceipts",
+			transactions: []string{"tx1", "tx2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			tp, chain, repo := setupTest(t, 100, "0xa")
+			WithReceipts(true)(tp)
+
+			// the receipts are fetched from a fork that replaced the block in the meantime
+			transactions := make([]domain.Transaction, 0)
+			for _, hash := range tt.transactions {
+				transactions = append(transactions, domain.Transaction{Hash: hash, From: "0xb", To: "0xa"})
+				chain.receipts[hash] = domain.Receipt{TransactionHash: hash, BlockHash: "b101", Status: domain.ReceiptStatusSuccessful}
+			}
+			chain.addBlock(101, "a", "a100", transactions...)
+			tp.processNewBlocks(ctx)
+
+			if blockNumber, _ := repo.GetBlockNumber(ctx); blockNumber != 100 {
+				t.Errorf("expected block to be fetched again, got block number %d", blockNumber)
+			}
+			if transactions, _ := repo.GetTransactions(ctx, "0xa"); len(transactions) != 0 {
+				t.Errorf("expected no transactions with receipts of another fork, got %+v", transactions)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aniladanir/ethereum-blockchain-parser/internal/adapters/blockchain"
+	"github.com/aniladanir/ethereum-blockchain-parser/internal/core/domain"
+	"github.com/aniladanir/ethereum-blockchain-parser/pkg/errs"
+)
+
+// transferLogTopics returns the topics of the logs the enabled kinds of transfers are decoded from.
+// The Transfer event is shared by erc-20 and erc-721 tokens.
+func (tp *transactionParser) transferLogTopics() [][]string {
+	topics := []string{domain.TransferEventTopic}
+	if tp.nftTransfers {
+		topics = append(topics, domain.TransferSingleEventTopic, domain.TransferBatchEventTopic)
+	}
+	return [][]string{topics}
+}
+
+// fetchTransfers attaches the token and nft transfers emitted in the consecutive blocks to them, depending on which
+// kinds of transfers are enabled. Transfers are fetched for all addresses, as nodes can not match the senders and
+// recipients of every subscription at once.
+func (tp *transactionParser) fetchTransfers(ctx context.Context, blocks []*domain.Block) error {
+	if len(blocks) == 0 {
+		return nil
+	}
+	from, to := int(blocks[0].Number), int(blocks[len(blocks)-1].Number)
+	logs, err := tp.fetchLogs(ctx, from, to, tp.transferLogTopics())
+	if err != nil {
+		return fmt.Errorf("could not fetch transfer logs of blocks %d-%d: %w", from, to, err)
+	}
+
+	logsByBlock := make(map[uint64][]*domain.Log)
+	for i := range logs {
+		logsByBlock[logs[i].BlockNumber] = append(logsByBlock[logs[i].BlockNumber], &logs[i])
+	}
+	for _, block := range blocks {
+		if tp.tokenTransfers {
+			block.TokenTransfers = make([]domain.TokenTransfer, 0)
+		}
+		if tp.nftTransfers {
+			block.NFTTransfers = make([]domain.NFTTransfer, 0)
+		}
+		for _, log := range logsByBlock[block.Number] {
+			// the block may have been reorganized between fetching the block and its logs
+			if log.BlockHash != block.Hash {
+				return fmt.Errorf("logs of block %d belong to block %s instead of %s: %w", block.Number, log.BlockHash, block.Hash, errs.BlockNotFoundErr())
+			}
+			if tp.tokenTransfers {
+				if transfer, ok := domain.DecodeTokenTransfer(log); ok {
+					block.TokenTransfers = append(block.TokenTransfers, transfer)
+				}
+			}
+			if tp.nftTransfers {
+				if transfers, ok := domain.DecodeNFTTransfers(log); ok {
+					block.NFTTransfers = append(block.NFTTransfers, transfers...)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// fetchLogs fetches the logs with the given topics of the blocks in the inclusive range [from, to].
+// Nodes limit the number of logs returned by a single request, so ranges rejected for returning too many logs
+// are split in halves. Other errors are returned as is.
+func (tp *transactionParser) fetchLogs(ctx context.Context, from, to int, topics [][]string) ([]domain.Log, error) {
+	logs, err := tp.bcClient.FetchLogs(ctx, blockchain.LogFilter{FromBlock: from, ToBlock: to, Topics: topics})
+	if !errs.IsResultLimitErr(err) || from == to {
+		return logs, err
+	}
+
+	mid := from + (to-from)/2
+	logs, err = tp.fetchLogs(ctx, from, mid, topics)
+	if err != nil {
+		return nil, err
+	}
+	upper, err := tp.fetchLogs(ctx, mid+1, to, topics)
+	if err != nil {
+		return nil, err
+	}
+	return append(logs, upper...), nil
+}
@@ -0,0 +1,301 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aniladanir/ethereum-blockchain-parser/internal/core/domain"
+	"github.com/aniladanir/ethereum-blockchain-parser/pkg/errs"
+)
+
+var (
+	alice = "0x" + strings.Repeat("a", 40)
+	bob   = "0x" + strings.Repeat("b", 40)
+	token = "0x" + strings.Repeat("c", 40)
+)
+
+// transferLog returns the log of an erc-20 transfer of the token
+func transferLog(from, to string, value int64, logIndex uint64) domain.Log {
+	return domain.Log{
+		Address: token,
+		Topics: []string{
+			domain.TransferEventTopic,
+			"0x000000000000000000000000" + from[2:],
+			"0x000000000000000000000000" + to[2:],
+		},
+		Data:     fmt.Sprintf("0x%064x", value),
+		LogIndex: logIndex,
+	}
+}
+
+// transferValues returns the values of the stored token transfers of the address
+func transferValues(t *testing.T, tp *transactionParser, address string) []string {
+	t.Helper()
+	page, err := tp.QueryTokenTransfers(context.Background(), domain.TokenTransferQuery{Address: address})
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := make([]string, 0)
+	for _, transfer := range page.TokenTransfers {
+		values = append(values, transfer.Value.String())
+	}
+	return values
+}
+
+func TestProcessBlocksTokenTransfers(t *testing.T) {
+	tests := []struct {
+		name           string
+		tokenTransfers bool
+		expected       []string
+	}{
+		{
+			name:     "Disabled",
+			expected: []string{},
+		},
+		{
+			name:           "Enabled",
+			tokenTransfers: true,
+			expected:       []string{"100", "200"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			tp, chain, _ := setupTest(t, 100, alice)
+			WithTokenTransfers(tt.tokenTransfers)(tp)
+
+			unrelated := "0x" + strings.Repeat("d", 40)
+			chain.logs[101] = []domain.Log{transferLog(bob, alice, 100, 0), transferLog(bob, unrelated, 300, 1)}
+			chain.logs[102] = []domain.Log{transferLog(alice, bob, 200, 0)}
+			a101 := chain.addBlock(101, "a", "a100")
+			chain.addBlock(102, "a", a101)
+			tp.processNewBlocks(ctx)
+
+			if values := transferValues(t, tp, alice); !reflect.DeepEqual(values, tt.expected) {
+				t.Errorf("expected transfers %v, got %v", tt.expected, values)
+			}
+			if !tt.tokenTransfers && len(chain.logRequests) != 0 {
+				t.Errorf("expected no log requests, got %v", chain.logRequests)
+			}
+		})
+	}
+}
+
+func TestProcessBlocksTokenTransfersSplitRange(t *testing.T) {
+	ctx := context.Background()
+	tp, chain, _ := setupTest(t, 100, alice)
+	WithTokenTransfers(true)(tp)
+	chain.maxLogs = 1
+
+	parent := "a100"
+	for block := 101; block <= 103; block++ {
+		chain.logs[block] = []domain.Log{transferLog(bob, alice, int64(block), 0)}
+		parent = chain.addBlock(block, "a", parent)
+	}
+	tp.processNewBlocks(ctx)
+
+	if values := transferValues(t, tp, alice); !reflect.DeepEqual(values, []string{"101", "102", "103"}) {
+		t.Errorf("expected transfers of blocks 101-103, got %v", values)
+	}
+	expectedRequests := []string{"101-103", "101-102", "101-101", "102-102", "103-103"}
+	if !reflect.DeepEqual(chain.logRequests, expectedRequests) {
+		t.Errorf("expected log requests %v, got %v", expectedRequests, chain.logRequests)
+	}
+}
+
+func TestBackfillKeptTokenTransfers(t *testing.T) {
+	ctx := context.Background()
+	tp, chain, _ := setupTest(t, 0, alice)
+	WithTokenTransfers(true)(tp)
+
+	parent := "a0"
+	for block := 1; block <= 5; block++ {
+		chain.logs[block] = []domain.Log{transferLog(bob, alice, int64(block), 0)}
+		parent = chain.addBlock(block, "a", parent)
+	}
+	tp.processNewBlocks(ctx)
+
+	// subscribing again from a block covered by the kept token transfers does not duplicate them
+	if err := tp.Unsubscribe(ctx, alice, UnsubscribeOptions{}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	startBlock := 2
+	if err := tp.Subscribe(ctx, alice, SubscribeOptions{StartBlock: &startBlock}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	for _, job := range tp.backfillQueue {
+		if err := tp.backfill(ctx, job); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+
+	if values := transferValues(t, tp, alice); !reflect.DeepEqual(values, []string{"1", "2", "3", "4", "5"}) {
+		t.Errorf("expected transfers of blocks 1-5 once, got %v", values)
+	}
+}
+
+func TestFetchLogsErrors(t *testing.T) {
+	tests := []struct {
+		name             string
+		err              error
+		expectedRequests []string
+	}{
+		{
+			name:             "ResponseSizeExceeded",
+			err:              &errs.ErrorRpc{Code: -32000, Message: "response size exceeded"},
+			expectedRequests: []string{"101-103", "101-102", "101-101"},
+		},
+		{
+			name:             "MethodNotFound",
+			err:              &errs.ErrorRpc{Code: -32601, Message: "the method eth_getLogs does not exist"},
+			expectedRequests: []string{"101-103"},
+		},
+		{
+			name:             "InvalidParams",
+			err:              &errs.ErrorRpc{Code: -32602, Message: "invalid params"},
+			expectedRequests: []string{"101-103"},
+		},
+		{
+			name:             "RateLimited",
+			err:              &errs.ErrorRpc{Code: -32005, Message: "project ID request rate exceeded"},
+			expectedRequests: []string{"101-103"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			tp, chain, _ := setupTest(t, 100, alice)
+			chain.logsErr = tt.err
+
+			if _, err := tp.fetchLogs(ctx, 101, 103, [][]string{{domain.TransferEventTopic}}); err == nil {
+				t.Error("expected error, got nil")
+			}
+			if !reflect.DeepEqual(chain.logRequests, tt.expectedRequests) {
+				t.Errorf("expected log requests %v, got %v", tt.expectedRequests, chain.logRequests)
+			}
+		})
+	}
+}
+
+func TestProcessBlocksTokenTransfersReorg(t *testing.T) {
+	ctx := context.Background()
+	tp, chain, _ := setupTest(t, 100, alice)
+	WithTokenTransfers(true)(tp)
+
+	chain.logs[101] = []domain.Log{transferLog(bob, alice, 1, 0)}
+	chain.logs[102] = []domain.Log{transferLog(bob, alice, 2, 0)}
+	a101 := chain.addBlock(101, "a", "a100")
+	chain.addBlock(102, "a", a101)
+	tp.processNewBlocks(ctx)
+
+	// b102 replaces a102 with a different transfer
+	chain.logs[102] = []domain.Log{transferLog(alice, bob, 3, 0)}
+	b102 := chain.addBlock(102, "b", a101)
+	chain.addBlock(103, "b", b102)
+	tp.processNewBlocks(ctx)
+
+	if values := transferValues(t, tp, alice); !reflect.DeepEqual(values, []string{"1", "3"}) {
+		t.Errorf("expected transfers 1 and 3 after reorg, got %v", values)
+	}
+}
+
+// erc721TransferLog returns the log of an erc-721 transfer of the token
+func erc721TransferLog(from, to string, tokenID int64, logIndex uint64) domain.Log {
+	return domain.Log{
+		Address: token,
+		Topics: []string{
+			domain.TransferEventTopic,
+			"0x000000000000000000000000" + from[2:],
+			"0x000000000000000000000000" + to[2:],
+			fmt.Sprintf("0x%064x", tokenID),
+		},
+		Data:     "0x",
+		LogIndex: logIndex,
+	}
+}
+
+// erc1155TransferBatchLog returns the log of an erc-1155 batch transfer of the given amounts of the token ids 1, 2, ...
+func erc1155TransferBatchLog(from, to string, logIndex uint64, amounts ...int64) domain.Log {
+	ids, values := fmt.Sprintf("%064x", len(amounts)), fmt.Sprintf("%064x", len(amounts))
+	for i, amount := range amounts {
+		ids += fmt.Sprintf("%064x", i+1)
+		values += fmt.Sprintf("%064x", amount)
+	}
+	return domain.Log{
+		Address: token,
+		Topics: []string{
+			domain.TransferBatchEventTopic,
+			"0x000000000000000000000000" + from[2:],
+			"0x000000000000000000000000" + from[2:],
+			"0x000000000000000000000000" + to[2:],
+		},
+		Data:     fmt.Sprintf("0x%064x%064x", 64, 64+len(ids)/2) + ids + values,
+		LogIndex: logIndex,
+	}
+}
+
+func TestProcessBlocksNFTTransfers(t *testing.T) {
+	tests := []struct {
+		name           string
+		tokenTransfers bool
+		nftTransfers   bool
+		expectedTokens []string
+		expectedNFTs   []string
+	}{
+		{
+			name:           "TokenTransfersOnly",
+			tokenTransfers: true,
+			expectedTokens: []string{"100"},
+			expectedNFTs:   []string{},
+		},
+		{
+			name:           "NFTTransfersOnly",
+			nftTransfers:   true,
+			expectedTokens: []string{},
+			expectedNFTs:   []string{"erc721 #7 x1", "erc1155 #1 x10", "erc1155 #2 x20"},
+		},
+		{
+			name:           "Both",
+			tokenTransfers: true,
+			nftTransfers:   true,
+			expectedTokens: []string{"100"},
+			expectedNFTs:   []string{"erc721 #7 x1", "erc1155 #1 x10", "erc1155 #2 x20"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			tp, chain, _ := setupTest(t, 100, alice)
+			WithTokenTransfers(tt.tokenTransfers)(tp)
+			WithNFTTransfers(tt.nftTransfers)(tp)
+
+			chain.logs[101] = []domain.Log{
+				transferLog(bob, alice, 100, 0), erc721TransferLog(alice, bob, 7, 1), erc1155TransferBatchLog(bob, alice, 2, 10, 20),
+			}
+			chain.addBlock(101, "a", "a100")
+			tp.processNewBlocks(ctx)
+
+			if values := transferValues(t, tp, alice); !reflect.DeepEqual(values, tt.expectedTokens) {
+				t.Errorf("expected token transfers %v, got %v", tt.expectedTokens, values)
+			}
+			page, err := tp.QueryNFTTransfers(ctx, domain.NFTTransferQuery{Address: alice})
+			if err != nil {
+				t.Fatal(err)
+			}
+			nfts := make([]string, 0)
+			for _, transfer := range page.NFTTransfers {
+				nfts = append(nfts, fmt.Sprintf("%s #%s x%s", transfer.Standard, transfer.TokenID, transfer.Amount))
+			}
+			if !reflect.DeepEqual(nfts, tt.expectedNFTs) {
+				t.Errorf("expected nft transfers %v, got %v", tt.expectedNFTs, nfts)
+			}
+			// both kinds of transfers share a single request
+			if len(chain.logRequests) != 1 {
+				t.Errorf("expected a single log request, got %v", chain.logRequests)
+			}
+		})
+	}
+}
@@ -2,13 +2,17 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
+	"sync"
 	"time"
 
 	"github.com/aniladanir/ethereum-blockchain-parser/internal/adapters/blockchain"
 	"github.com/aniladanir/ethereum-blockchain-parser/internal/adapters/repositories"
 	"github.com/aniladanir/ethereum-blockchain-parser/internal/core/domain"
+	"github.com/aniladanir/ethereum-blockchain-parser/pkg/errs"
 )
 
 type TransactionParser interface {
@@ -21,144 +25,921 @@ type TransactionParser interface {
 	// GetCurrentBlock returns the last parsed block in the blockchain
 	GetCurrentBlock(ctx context.Context) (int, error)
 
-	// Subscribe adds the given address to be observed by the transaction service
-	Subscribe(ctx context.Context, address string) error
+	// Subscribe adds the given address to be observed by the transaction service.
+	// Malformed addresses are rejected with errs.ErrorInvalidAddress, valid ones are stored in canonical form.
+	// Labels longer than MaxLabelLength are rejected with errs.ErrorInvalidLabel.
+	// If a start block or time is given, historical transactions are backfilled in the background.
+	Subscribe(ctx context.Context, address string, opts SubscribeOptions) error
 
-	// GetTransactions returns a list of inbound and outbound transactions for a given address
+	// SubscribeBatch subscribes to the addresses of the requests at once, returning the result of each request in order.
+	// Invalid requests and already subscribed addresses do not affect the other requests.
+	// An error is only returned if the batch could not be stored, in which case no address is subscribed.
+	SubscribeBatch(ctx context.Context, requests []SubscribeRequest) ([]SubscribeResult, error)
+
+	// Unsubscribe stops observing the given address. Its stored transactions are kept unless opts.PurgeHistory is set.
+	// Kept transactions remain readable and are continued if the address is subscribed again.
+	// Returns errs.ErrorNotFound if the address is not subscribed.
+	Unsubscribe(ctx context.Context, address string, opts UnsubscribeOptions) error
+
+	// ListSubscriptions returns the observed addresses ordered by address
+	ListSubscriptions(ctx context.Context) ([]domain.Subscription, error)
+
+	// GetTransactions returns a list of inbound and outbound transactions for a given address.
+	// The address is matched case-insensitively.
 	GetTransactions(ctx context.Context, address string) ([]domain.Transaction, error)
+
+	// QueryTransactions returns a page of the transactions of query.Address that match the filters of the query.
+	// The address and counterparty are matched case-insensitively. The limit defaults to DefaultQueryLimit
+	// and is capped at MaxQueryLimit.
+	QueryTransactions(ctx context.Context, query domain.TransactionQuery) (domain.TransactionPage, error)
+
+	// QueryTokenTransfers returns a page of the erc-20 token transfers of query.Address that match the filters of the query.
+	// The address and token are matched case-insensitively. The limit defaults to DefaultQueryLimit
+	// and is capped at MaxQueryLimit. Token transfers are only recorded if enabled with WithTokenTransfers.
+	QueryTokenTransfers(ctx context.Context, query domain.TokenTransferQuery) (domain.TokenTransferPage, error)
+
+	// QueryNFTTransfers returns a page of the erc-721 and erc-1155 transfers of query.Address that match the filters
+	// of the query. The address and contract are matched case-insensitively. The limit defaults to DefaultQueryLimit
+	// and is capped at MaxQueryLimit. NFT transfers are only recorded if enabled with WithNFTTransfers.
+	QueryNFTTransfers(ctx context.Context, query domain.NFTTransferQuery) (domain.NFTTransferPage, error)
+
+	// GetRpcHealth returns health statistics of the blockchain rpc endpoints
+	GetRpcHealth(ctx context.Context) []blockchain.EndpointHealth
+}
+
+// StartMode determines the block the parser starts processing from on startup
+type StartMode string
+
+const (
+	// StartModeResume continues from the last block stored in the repository, or from the head if there is none
+	StartModeResume StartMode = "resume"
+	// StartModeHead skips to the current head of the chain
+	StartModeHead StartMode = "head"
+	// StartModeBlock starts from an explicit block number
+	StartModeBlock StartMode = "block"
+)
+
+// Valid reports whether the mode is one of the supported start modes
+func (m StartMode) Valid() bool {
+	return m == StartModeResume || m == StartModeHead || m == StartModeBlock
+}
+
+// SubscribeOptions holds optional settings of a subscription
+type SubscribeOptions struct {
+	// StartBlock is the block from which historical transactions are backfilled
+	StartBlock *int
+	// StartTime backfills historical transactions from the first block mined at or after the given time.
+	// It is ignored if StartBlock is set.
+	StartTime *time.Time
+	// Label is a free-form description of the address of at most MaxLabelLength bytes
+	Label string
+}
+
+// MaxLabelLength is the maximum length of a subscription label in bytes
+const MaxLabelLength = 256
+
+// SubscribeRequest is a single subscription of a batch
+type SubscribeRequest struct {
+	Address string
+	Options SubscribeOptions
+}
+
+// SubscribeStatus is the outcome of a subscription request of a batch
+type SubscribeStatus string
+
+const (
+	SubscribeStatusCreated       SubscribeStatus = "created"
+	SubscribeStatusAlreadyExists SubscribeStatus = "already_exists"
+	SubscribeStatusInvalid       SubscribeStatus = "invalid"
+)
+
+// SubscribeResult is the result of a subscription request of a batch
+type SubscribeResult struct {
+	// Address is the canonical form of the requested address, empty if it is invalid
+	Address domain.Address
+	Status  SubscribeStatus
+	// Err describes why an invalid request was rejected
+	Err error
+}
+
+// UnsubscribeOptions holds optional settings of an unsubscription
+type UnsubscribeOptions struct {
+	// PurgeHistory removes the stored transactions of the address
+	PurgeHistory bool
 }
 
 var _ TransactionParser = (*transactionParser)(nil)
 
+const (
+	// blockFetchRangeSize is the maximum number of blocks fetched at once while catching up
+	blockFetchRangeSize = 100
+	// defaultMaxReorgDepth is the default number of blocks that can be rolled back on chain reorganization
+	defaultMaxReorgDepth = 64
+	// maxReorgRetries is the number of reorganizations handled in a single process cycle
+	maxReorgRetries = 3
+	// defaultFetchParallelism is the default number of block ranges fetched concurrently
+	defaultFetchParallelism = 4
+)
+
+const (
+	// DefaultQueryLimit is the number of transactions returned by a query without a limit
+	DefaultQueryLimit = 100
+	// MaxQueryLimit is the maximum number of transactions returned by a query
+	MaxQueryLimit = 1000
+)
+
 type transactionParser struct {
-	logger   *slog.Logger
-	bcClient blockchain.Client
-	repo     repositories.Repository
+	logger         *slog.Logger
+	bcClient       blockchain.Client
+	headSubscriber blockchain.HeadSubscriber
+	repo           repositories.Repository
+	maxReorgDepth  int
+	confirmations  int
+	blockTag       blockchain.BlockTag
+	startMode      StartMode
+	startBlock     int
+	// fetchParallelism is the number of block ranges fetched concurrently while catching up
+	fetchParallelism int
+	// receipts enables fetching the receipts of the matched transactions
+	receipts bool
+	// tokenTransfers enables fetching the token transfers of the processed blocks
+	tokenTransfers bool
+	// nftTransfers enables fetching the nft transfers of the processed blocks
+	nftTransfers bool
+
+	// ingestMtx serializes ingestion cycles, so that subscriptions can find the
+	// last block processed without a newly subscribed address
+	ingestMtx sync.Mutex
+
+	backfillMtx    sync.Mutex
+	backfillQueue  []backfillJob
+	backfillSignal chan struct{}
+}
+
+// backfillJob is a request to scan historical blocks for the transactions of an address
+type backfillJob struct {
+	address    string
+	startBlock *int
+	startTime  *time.Time
+	// endBlock is the last block processed before the address was subscribed
+	endBlock int
 }
 
-func NewTransactionParser(repo repositories.Repository, bcClient blockchain.Client, logger *slog.Logger) TransactionParser {
-	return &transactionParser{
-		logger:   logger,
-		bcClient: bcClient,
-		repo:     repo,
+// Option configures optional behaviour of the transaction parser
+type Option func(tp *transactionParser)
+
+// WithHeadSubscriber makes the parser process new blocks as soon as they are announced by the subscriber.
+// Polling is only used while the subscription is down.
+func WithHeadSubscriber(headSubscriber blockchain.HeadSubscriber) Option {
+	return func(tp *transactionParser) {
+		tp.headSubscriber = headSubscriber
 	}
 }
 
+// WithMaxReorgDepth sets the maximum number of blocks that can be rolled back on chain reorganization
+func WithMaxReorgDepth(depth int) Option {
+	return func(tp *transactionParser) {
+		if depth > 0 {
+			tp.maxReorgDepth = depth
+		}
+	}
+}
+
+// WithConfirmations makes the parser only ingest blocks that are at least the given number of blocks behind the head
+func WithConfirmations(confirmations int) Option {
+	return func(tp *transactionParser) {
+		if confirmations > 0 {
+			tp.confirmations = confirmations
+		}
+	}
+}
+
+// WithBlockTag makes the parser follow the head block at the given finality level instead of the latest block
+func WithBlockTag(tag blockchain.BlockTag) Option {
+	return func(tp *transactionParser) {
+		if tag.Valid() {
+			tp.blockTag = tag
+		}
+	}
+}
+
+// WithStartMode sets where the parser starts processing on startup.
+// startBlock is the first block to be processed and only used with StartModeBlock.
+func WithStartMode(mode StartMode, startBlock int) Option {
+	return func(tp *transactionParser) {
+		if mode.Valid() {
+			tp.startMode = mode
+			tp.startBlock = startBlock
+		}
+	}
+}
+
+// WithFetchParallelism sets the number of block ranges fetched concurrently while catching up
+func WithFetchParallelism(parallelism int) Option {
+	return func(tp *transactionParser) {
+		if parallelism > 0 {
+			tp.fetchParallelism = parallelism
+		}
+	}
+}
+
+// WithReceipts makes the parser fetch the receipts of the matched transactions, which costs additional rpc requests
+// for every block containing a matched transaction
+func WithReceipts(enabled bool) Option {
+	return func(tp *transactionParser) {
+		tp.receipts = enabled
+	}
+}
+
+// WithTokenTransfers makes the parser record the erc-20 token transfers sent from or to the subscribed addresses,
+// which costs an additional eth_getLogs request for every fetched block range
+func WithTokenTransfers(enabled bool) Option {
+	return func(tp *transactionParser) {
+		tp.tokenTransfers = enabled
+	}
+}
+
+// WithNFTTransfers makes the parser record the erc-721 and erc-1155 transfers sent from or to the subscribed addresses.
+// NFT transfers are fetched with the same eth_getLogs request as token transfers if both are enabled.
+func WithNFTTransfers(enabled bool) Option {
+	return func(tp *transactionParser) {
+		tp.nftTransfers = enabled
+	}
+}
+
+func NewTransactionParser(repo repositories.Repository, bcClient blockchain.Client, logger *slog.Logger, opts ...Option) TransactionParser {
+	tp := &transactionParser{
+		logger:           logger,
+		bcClient:         bcClient,
+		repo:             repo,
+		maxReorgDepth:    defaultMaxReorgDepth,
+		blockTag:         blockchain.BlockTagLatest,
+		startMode:        StartModeResume,
+		fetchParallelism: defaultFetchParallelism,
+		backfillSignal:   make(chan struct{}, 1),
+	}
+	for _, opt := range opts {
+		opt(tp)
+	}
+	return tp
+}
+
 func (tp *transactionParser) GetCurrentBlock(ctx context.Context) (int, error) {
 	return tp.repo.GetBlockNumber(ctx)
 }
 
-func (tp *transactionParser) Subscribe(ctx context.Context, address string) error {
-	return tp.repo.AddAddress(ctx, address)
+func (tp *transactionParser) Subscribe(ctx context.Context, address string, opts SubscribeOptions) error {
+	addr, err := domain.ParseAddress(address)
+	if err != nil {
+		return err
+	}
+	if err := validateSubscribeOptions(opts); err != nil {
+		return err
+	}
+
+	endBlock, err := tp.commitSubscriptions(ctx, func(repoTx repositories.Transaction) error {
+		return addSubscription(ctx, repoTx, addr, opts)
+	})
+	if err != nil {
+		return err
+	}
+
+	tp.enqueueSubscriptionBackfill(addr, opts, endBlock)
+	return nil
+}
+
+func (tp *transactionParser) SubscribeBatch(ctx context.Context, requests []SubscribeRequest) ([]SubscribeResult, error) {
+	results := make([]SubscribeResult, len(requests))
+	endBlock, err := tp.commitSubscriptions(ctx, func(repoTx repositories.Transaction) error {
+		for i, request := range requests {
+			addr, err := domain.ParseAddress(request.Address)
+			if err == nil {
+				err = validateSubscribeOptions(request.Options)
+			}
+			if err != nil {
+				results[i] = SubscribeResult{Status: SubscribeStatusInvalid, Err: err}
+				continue
+			}
+
+			results[i].Address = addr
+			err = addSubscription(ctx, repoTx, addr, request.Options)
+			switch {
+			case err == nil:
+				results[i].Status = SubscribeStatusCreated
+			case errs.IsAlreadyExistErr(err):
+				results[i].Status = SubscribeStatusAlreadyExists
+			default:
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range results {
+		if results[i].Status == SubscribeStatusCreated {
+			tp.enqueueSubscriptionBackfill(results[i].Address, requests[i].Options, endBlock)
+		}
+	}
+	return results, nil
+}
+
+// commitSubscriptions adds subscriptions with addFn in a repository transaction, and returns the last block processed
+// before they were added. No ingestion cycle runs in between, so every later cycle includes the subscriptions.
+// ingestMtx is taken before the transaction is created, in the same order as ingestion cycles take them.
+func (tp *transactionParser) commitSubscriptions(ctx context.Context, addFn func(repoTx repositories.Transaction) error) (int, error) {
+	tp.ingestMtx.Lock()
+	defer tp.ingestMtx.Unlock()
+
+	repoTx, err := tp.repo.NewTransaction(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("could not create repository transaction: %w", err)
+	}
+	if err := addFn(repoTx); err != nil {
+		tp.rollback(ctx, repoTx)
+		return 0, err
+	}
+	if err := repoTx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("could not commit repository transaction: %w", err)
+	}
+	endBlock, err := tp.repo.GetBlockNumber(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("could not get block number: %w", err)
+	}
+	return endBlock, nil
+}
+
+// validateSubscribeOptions checks the options given by the user
+func validateSubscribeOptions(opts SubscribeOptions) error {
+	if len(opts.Label) > MaxLabelLength {
+		return fmt.Errorf("%w: label is longer than %d bytes", errs.InvalidLabelErr(), MaxLabelLength)
+	}
+	if opts.StartBlock != nil && *opts.StartBlock < 0 {
+		return errors.New("start block must not be negative")
+	}
+	return nil
+}
+
+// addSubscription adds the address with its label to the repository
+func addSubscription(ctx context.Context, repo repositories.Repository, addr domain.Address, opts SubscribeOptions) error {
+	if err := repo.AddAddress(ctx, addr.String()); err != nil {
+		return err
+	}
+	if opts.Label != "" {
+		if err := repo.SetAddressLabel(ctx, addr.String(), opts.Label); err != nil {
+			return fmt.Errorf("could not set label: %w", err)
+		}
+	}
+	return nil
+}
+
+// enqueueSubscriptionBackfill enqueues the backfill of a new subscription up to the given block
+// if a start block or time is given
+func (tp *transactionParser) enqueueSubscriptionBackfill(addr domain.Address, opts SubscribeOptions, endBlock int) {
+	if opts.StartBlock != nil || opts.StartTime != nil {
+		tp.enqueueBackfill(backfillJob{
+			address:    addr.String(),
+			startBlock: opts.StartBlock,
+			startTime:  opts.StartTime,
+			endBlock:   endBlock,
+		})
+	}
+}
+
+func (tp *transactionParser) Unsubscribe(ctx context.Context, address string, opts UnsubscribeOptions) error {
+	addr, err := domain.ParseAddress(address)
+	if err != nil {
+		return err
+	}
+
+	if err := tp.repo.RemoveAddress(ctx, addr.String(), opts.PurgeHistory); err != nil {
+		return err
+	}
+
+	// drop pending backfills of the address, a running one stops on its next write
+	tp.backfillMtx.Lock()
+	tp.backfillQueue = slices.DeleteFunc(tp.backfillQueue, func(job backfillJob) bool {
+		return job.address == addr.String()
+	})
+	tp.backfillMtx.Unlock()
+	return nil
+}
+
+func (tp *transactionParser) ListSubscriptions(ctx context.Context) ([]domain.Subscription, error) {
+	return tp.repo.GetSubscriptions(ctx)
 }
 
 func (tp *transactionParser) GetTransactions(ctx context.Context, address string) ([]domain.Transaction, error) {
-	return tp.repo.GetTransactions(ctx, address)
+	addr, err := domain.ParseAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	return tp.repo.GetTransactions(ctx, addr.String())
+}
+
+func (tp *transactionParser) QueryTransactions(ctx context.Context, query domain.TransactionQuery) (domain.TransactionPage, error) {
+	addr, err := domain.ParseAddress(query.Address)
+	if err != nil {
+		return domain.TransactionPage{}, err
+	}
+	query.Address = addr.String()
+	if query.Counterparty != "" {
+		counterparty, err := domain.ParseAddress(query.Counterparty)
+		if err != nil {
+			return domain.TransactionPage{}, fmt.Errorf("counterparty: %w", err)
+		}
+		query.Counterparty = counterparty.String()
+	}
+	if err := normalizePage(&query.Order, query.Direction, &query.Limit); err != nil {
+		return domain.TransactionPage{}, err
+	}
+
+	return tp.repo.QueryTransactions(ctx, query)
+}
+
+func (tp *transactionParser) QueryTokenTransfers(ctx context.Context, query domain.TokenTransferQuery) (domain.TokenTransferPage, error) {
+	addr, err := domain.ParseAddress(query.Address)
+	if err != nil {
+		return domain.TokenTransferPage{}, err
+	}
+	query.Address = addr.String()
+	if query.Token != "" {
+		token, err := domain.ParseAddress(query.Token)
+		if err != nil {
+			return domain.TokenTransferPage{}, fmt.Errorf("token: %w", err)
+		}
+		query.Token = token.String()
+	}
+	if err := normalizePage(&query.Order, query.Direction, &query.Limit); err != nil {
+		return domain.TokenTransferPage{}, err
+	}
+
+	return tp.repo.QueryTokenTransfers(ctx, query)
+}
+
+func (tp *transactionParser) QueryNFTTransfers(ctx context.Context, query domain.NFTTransferQuery) (domain.NFTTransferPage, error) {
+	addr, err := domain.ParseAddress(query.Address)
+	if err != nil {
+		return domain.NFTTransferPage{}, err
+	}
+	query.Address = addr.String()
+	if query.Contract != "" {
+		contract, err := domain.ParseAddress(query.Contract)
+		if err != nil {
+			return domain.NFTTransferPage{}, fmt.Errorf("contract: %w", err)
+		}
+		query.Contract = contract.String()
+	}
+	if err := normalizePage(&query.Order, query.Direction, &query.Limit); err != nil {
+		return domain.NFTTransferPage{}, err
+	}
+
+	return tp.repo.QueryNFTTransfers(ctx, query)
+}
+
+// normalizePage validates the order and direction of a query, and applies the default order and limit
+func normalizePage(order *domain.SortOrder, direction domain.Direction, limit *int) error {
+	if *order == "" {
+		*order = domain.SortAscending
+	}
+	if !order.Valid() {
+		return fmt.Errorf("invalid sort order %q", *order)
+	}
+	if !direction.Valid() {
+		return fmt.Errorf("invalid direction %q", direction)
+	}
+	if *limit <= 0 {
+		*limit = DefaultQueryLimit
+	}
+	*limit = min(*limit, MaxQueryLimit)
+	return nil
+}
+
+func (tp *transactionParser) GetRpcHealth(ctx context.Context) []blockchain.EndpointHealth {
+	return tp.bcClient.EndpointHealth()
 }
 
 // ProcessNewBlocks is a blocking function that continuously searches for newly mined blocks on the blockchain network
 // that have not been processed.
 //
-// 'interval' argument determines the duration between each process cycle
+// 'interval' argument determines the duration between each process cycle. If a head subscriber is configured,
+// blocks are processed as soon as they are announced and polling is only used while the subscription is down.
 func (tp *transactionParser) ProcessNewBlocks(ctx context.Context, interval time.Duration) error {
 	if err := tp.updateBlockNumber(ctx); err != nil {
 		return err
 	}
+
+	go tp.runBackfills(ctx)
+
+	var heads <-chan int
+	if tp.headSubscriber != nil {
+		heads = tp.headSubscriber.SubscribeNewHeads(ctx)
+	}
+
 	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 	for {
 		select {
 		case <-ctx.Done():
 			return nil
+		case head, ok := <-heads:
+			if !ok {
+				heads = nil
+				continue
+			}
+			// heads are announced at the latest tag, other finality levels have to be polled
+			if tp.blockTag != blockchain.BlockTagLatest {
+				tp.processNewBlocks(ctx)
+				continue
+			}
+			tp.processBlocksUntil(ctx, head-tp.confirmations)
 		case <-ticker.C:
+			if tp.headSubscriber != nil && tp.headSubscriber.Connected() {
+				continue
+			}
 			tp.processNewBlocks(ctx)
 		}
 	}
 }
 
 func (tp *transactionParser) processNewBlocks(ctx context.Context) {
-	// fetch most recently mined block number from blockchain
-	lastMinedBlock, err := tp.bcClient.FetchCurrentBlock(ctx)
+	// fetch most recently mined block number that meets the finality level from blockchain
+	lastMinedBlock, err := tp.fetchConfirmedBlock(ctx)
 	if err != nil {
 		tp.logger.Error("could not fetch current block number", slog.Any("error", err))
 		return
 	}
 
+	tp.processBlocksUntil(ctx, lastMinedBlock)
+}
+
+// fetchConfirmedBlock returns the number of the most recent block at the configured block tag,
+// minus the configured number of confirmations
+func (tp *transactionParser) fetchConfirmedBlock(ctx context.Context) (int, error) {
+	var (
+		blockNumber int
+		err         error
+	)
+	if tp.blockTag == blockchain.BlockTagLatest {
+		blockNumber, err = tp.bcClient.FetchCurrentBlock(ctx)
+	} else {
+		blockNumber, err = tp.bcClient.FetchBlockNumberByTag(ctx, tp.blockTag)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return max(blockNumber-tp.confirmations, 0), nil
+}
+
+// processBlocksUntil processes the blocks after the last processed block up to and including lastMinedBlock.
+// If a chain reorganization is detected, the orphaned blocks are rolled back and the canonical chain is re-ingested.
+func (tp *transactionParser) processBlocksUntil(ctx context.Context, lastMinedBlock int) {
+	tp.ingestMtx.Lock()
+	defer tp.ingestMtx.Unlock()
+
+	for i := 0; i < maxReorgRetries; i++ {
+		reorgBlock, reorged := tp.ingestBlocks(ctx, lastMinedBlock)
+		if !reorged {
+			return
+		}
+		if err := tp.rollbackReorg(ctx, reorgBlock); err != nil {
+			tp.logger.Error("could not roll back chain reorganization", slog.Any("error", err), slog.Int("block number", reorgBlock))
+			return
+		}
+	}
+}
+
+// ingestBlocks stores the transactions of the blocks after the last processed block up to and including lastMinedBlock.
+// If the parent hash of a block does not match the stored hash of the previous block, the blocks before it are committed
+// and the number of the mismatching block is returned along with true.
+func (tp *transactionParser) ingestBlocks(ctx context.Context, lastMinedBlock int) (reorgBlock int, reorged bool) {
 	// get last fetched block number
 	lastProcessedBlock, err := tp.GetCurrentBlock(ctx)
 	if err != nil {
 		tp.logger.Error("could not get current block number from repository", slog.Any("error", err))
-		return
+		return 0, false
 	}
 
 	// compare last fetched block number to latest mined block
 	// if there is no difference, do nothing
 	if lastMinedBlock <= lastProcessedBlock {
-		return
+		return 0, false
 	}
 
 	// get subscribed addresses
 	subscribedAddresses, err := tp.repo.GetAddresses(ctx)
 	if err != nil {
 		tp.logger.Error("could not get subscribed addresses from repository", slog.Any("error", err))
-		return
+		return 0, false
 	}
+	subscriptions := newAddressIndex(subscribedAddresses)
 
 	// create new repository transaction
 	repoTx, err := tp.repo.NewTransaction(ctx)
 	if err != nil {
 		tp.logger.Error("could not create repository transaction", slog.Any("error", err))
-		return
+		return 0, false
 	}
 
-	// catch up to the last fetched block number
-	for block := lastProcessedBlock + 1; block <= lastMinedBlock; block++ {
-		blockData, err := tp.bcClient.FetchBlockByNumber(ctx, block)
-		if err != nil {
-			tp.logger.Error("could not fetch block", slog.Any("error", err), slog.Int("block number", block))
-			return
+	// stop fetching when returning early
+	fetchCtx, cancelFetch := context.WithCancel(ctx)
+	defer cancelFetch()
+
+	// catch up to the last fetched block number. blocks are fetched concurrently but applied in block order,
+	// so the block number in the repository only moves forward
+	var prevHash string
+	for fetched := range tp.fetchBlocks(fetchCtx, lastProcessedBlock+1, lastMinedBlock, subscriptions) {
+		if fetched.err != nil {
+			if errs.IsBlockNotFoundErr(fetched.err) {
+				// node is lagging behind the reported head, blocks will be fetched in the next cycle
+				tp.logger.Warn("blocks are not available yet", slog.Any("error", fetched.err), slog.Int("from", fetched.from), slog.Int("to", fetched.to))
+			} else {
+				tp.logger.Error("could not fetch blocks", slog.Any("error", fetched.err), slog.Int("from", fetched.from), slog.Int("to", fetched.to))
+			}
+			break
 		}
 
-		// process transactions
-		for i := range blockData.Transactions {
-			// if any transaction is outgoing or incoming to the one of the addresses in
-			// the subscription list, add it to the repository
-			for _, addr := range subscribedAddresses {
-				if blockData.Transactions[i].From == addr || blockData.Transactions[i].To == addr {
-					if err := repoTx.AddTransaction(ctx, addr, blockData.Transactions[i]); err != nil {
-						tp.logger.Error("could not add transaction to the repository", slog.Any("error", err))
-						if err := repoTx.Rollback(ctx); err != nil {
-							tp.logger.Error("could not rollback repository transaction", slog.Any("error", err))
-						}
-						return
-					}
+		for i, blockData := range fetched.blocks {
+			block := fetched.from + i
+
+			// detect chain reorganization by comparing the parent hash to the hash of the previous block
+			expectedParentHash := prevHash
+			if expectedParentHash == "" {
+				expectedParentHash, err = repoTx.GetBlockHash(ctx, block-1)
+				if err != nil && !errs.IsNotFoundErr(err) {
+					tp.logger.Error("could not get block hash from repository", slog.Any("error", err), slog.Int("block number", block-1))
+					tp.rollback(ctx, repoTx)
+					return 0, false
 				}
 			}
-		}
+			if expectedParentHash != "" && blockData.ParentHash != expectedParentHash {
+				tp.logger.Warn("chain reorganization detected", slog.Int("block number", block),
+					slog.String("parent hash", blockData.ParentHash), slog.String("stored hash", expectedParentHash))
+				if err := repoTx.Commit(ctx); err != nil {
+					tp.logger.Error("could not commit repository transaction", slog.Any("error", err))
+					return 0, false
+				}
+				return block, true
+			}
 
-		// set the processed block number in repository
-		if err := repoTx.SetBlockNumber(ctx, block); err != nil {
-			tp.logger.Error("could not set block number in repository", slog.Any("error", err), slog.Int("block number", block))
-			if err := repoTx.Rollback(ctx); err != nil {
-				tp.logger.Error("could not rollback repository transaction", slog.Any("error", err))
+			// add the transactions, token and nft transfers of the subscribed addresses to the repository,
+			// skipping the addresses that were unsubscribed during the cycle
+			if err := addBlockRecords(ctx, repoTx, blockData, subscriptions, true); err != nil {
+				tp.logger.Error("could not add block records to the repository", slog.Any("error", err), slog.Int("block number", block))
+				tp.rollback(ctx, repoTx)
+				return 0, false
+			}
+
+			// store the block hash to detect reorganizations of the following blocks
+			if err := repoTx.SetBlockHash(ctx, block, blockData.Hash); err != nil {
+				tp.logger.Error("could not set block hash in repository", slog.Any("error", err), slog.Int("block number", block))
+				tp.rollback(ctx, repoTx)
+				return 0, false
+			}
+			prevHash = blockData.Hash
+
+			// set the processed block number in repository
+			if err := repoTx.SetBlockNumber(ctx, block); err != nil {
+				tp.logger.Error("could not set block number in repository", slog.Any("error", err), slog.Int("block number", block))
+				tp.rollback(ctx, repoTx)
+				return 0, false
 			}
-			return
 		}
 	}
 
+	// hashes of blocks deeper than the maximum reorg depth are not needed anymore
+	if err := repoTx.PruneBlockHashes(ctx, lastMinedBlock-tp.maxReorgDepth); err != nil {
+		tp.logger.Error("could not prune block hashes", slog.Any("error", err))
+		tp.rollback(ctx, repoTx)
+		return 0, false
+	}
+
 	// commit transaction
 	if err := repoTx.Commit(ctx); err != nil {
 		tp.logger.Error("could not commit repository transaction", slog.Any("error", err))
 	}
+
+	return 0, false
 }
 
-func (tp *transactionParser) updateBlockNumber(ctx context.Context) error {
-	// set current block number
-	blockNumber, err := tp.bcClient.FetchCurrentBlock(ctx)
+// rollbackReorg walks back from the block before reorgBlock to the common ancestor of the stored and the canonical chain,
+// and removes the blocks after the ancestor from the repository so that they are re-ingested.
+func (tp *transactionParser) rollbackReorg(ctx context.Context, reorgBlock int) error {
+	ancestor := -1
+	for block := reorgBlock - 1; block >= max(reorgBlock-tp.maxReorgDepth, 0); block-- {
+		storedHash, err := tp.repo.GetBlockHash(ctx, block)
+		if errs.IsNotFoundErr(err) {
+			// blocks before are not tracked, assume they are canonical
+			ancestor = block
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("could not get block hash: %w", err)
+		}
+
+		canonicalBlock, err := tp.bcClient.FetchBlockByNumber(ctx, block)
+		if err != nil {
+			return fmt.Errorf("could not fetch block %d: %w", block, err)
+		}
+		if canonicalBlock.Hash == storedHash {
+			ancestor = block
+			break
+		}
+	}
+	if ancestor < 0 {
+		ancestor = max(reorgBlock-tp.maxReorgDepth-1, 0)
+		tp.logger.Error("chain reorganization is deeper than the maximum reorg depth, older transactions may be orphaned",
+			slog.Int("block number", reorgBlock), slog.Int("max reorg depth", tp.maxReorgDepth))
+	}
+
+	repoTx, err := tp.repo.NewTransaction(ctx)
 	if err != nil {
-		return fmt.Errorf("could not fetch current block number: %w", err)
+		return fmt.Errorf("could not create repository transaction: %w", err)
+	}
+	if err := repoTx.RemoveBlocks(ctx, ancestor+1); err != nil {
+		tp.rollback(ctx, repoTx)
+		return fmt.Errorf("could not remove orphaned blocks: %w", err)
 	}
-	if err = tp.repo.SetBlockNumber(ctx, blockNumber); err != nil {
+	if err := repoTx.SetBlockNumber(ctx, ancestor); err != nil {
+		tp.rollback(ctx, repoTx)
 		return fmt.Errorf("could not set block number: %w", err)
 	}
+	if err := repoTx.Commit(ctx); err != nil {
+		return fmt.Errorf("could not commit repository transaction: %w", err)
+	}
+
+	tp.logger.Warn("rolled back orphaned blocks", slog.Int("from", ancestor+1), slog.Int("to", reorgBlock-1))
+	return nil
+}
+
+func (tp *transactionParser) rollback(ctx context.Context, repoTx repositories.Transaction) {
+	if err := repoTx.Rollback(ctx); err != nil {
+		tp.logger.Error("could not rollback repository transaction", slog.Any("error", err))
+	}
+}
+
+// addBlockRecords adds the transactions, token and nft transfers of the block to the matching addresses of
+// subscriptions. The records of unsubscribed addresses are skipped if skipUnsubscribed is set, otherwise a not found
+// error is returned.
+func addBlockRecords(ctx context.Context, repoTx repositories.Transaction, block *domain.Block, subscriptions addressIndex,
+	skipUnsubscribed bool) error {
+	// check treats an unsubscribed address like any other error unless it is skipped
+	check := func(err error) error {
+		if skipUnsubscribed && errs.IsNotFoundErr(err) {
+			return nil
+		}
+		return err
+	}
+
+	for i := range block.Transactions {
+		for _, addr := range subscriptions.match(&block.Transactions[i]) {
+			if err := check(repoTx.AddTransaction(ctx, addr, block.Transactions[i])); err != nil {
+				return fmt.Errorf("could not add transaction: %w", err)
+			}
+		}
+	}
+	for i := range block.TokenTransfers {
+		for _, addr := range subscriptions.matchTokenTransfer(&block.TokenTransfers[i]) {
+			if err := check(repoTx.AddTokenTransfer(ctx, addr, block.TokenTransfers[i])); err != nil {
+				return fmt.Errorf("could not add token transfer: %w", err)
+			}
+		}
+	}
+	for i := range block.NFTTransfers {
+		for _, addr := range subscriptions.matchNFTTransfer(&block.NFTTransfers[i]) {
+			if err := check(repoTx.AddNFTTransfer(ctx, addr, block.NFTTransfers[i])); err != nil {
+				return fmt.Errorf("could not add nft transfer: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// updateBlockNumber sets the last processed block in the repository according to the start mode
+func (tp *transactionParser) updateBlockNumber(ctx context.Context) error {
+	if tp.startMode == StartModeResume {
+		lastProcessedBlock, err := tp.repo.GetBlockNumber(ctx)
+		if err != nil {
+			return fmt.Errorf("could not get block number: %w", err)
+		}
+		// start from the head if nothing was processed before
+		if lastProcessedBlock > 0 {
+			tp.logger.Info("resuming from the last processed block", slog.Int("block number", lastProcessedBlock))
+			return nil
+		}
+	}
+
+	// the start block is the first block to be processed
+	blockNumber := max(tp.startBlock-1, 0)
+	if tp.startMode != StartModeBlock {
+		// set current block number
+		currentBlock, err := tp.fetchConfirmedBlock(ctx)
+		if err != nil {
+			return fmt.Errorf("could not fetch current block number: %w", err)
+		}
+		blockNumber = currentBlock
+	}
+
+	if err := tp.repo.SetBlockNumber(ctx, blockNumber); err != nil {
+		return fmt.Errorf("could not set block number: %w", err)
+	}
+	return nil
+}
+
+func (tp *transactionParser) enqueueBackfill(job backfillJob) {
+	tp.backfillMtx.Lock()
+	tp.backfillQueue = append(tp.backfillQueue, job)
+	tp.backfillMtx.Unlock()
+
+	select {
+	case tp.backfillSignal <- struct{}{}:
+	default:
+	}
+}
+
+// runBackfills is a blocking function that processes backfill jobs one at a time until ctx is done
+func (tp *transactionParser) runBackfills(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tp.backfillSignal:
+		}
+
+		for {
+			tp.backfillMtx.Lock()
+			if len(tp.backfillQueue) == 0 {
+				tp.backfillMtx.Unlock()
+				break
+			}
+			job := tp.backfillQueue[0]
+			tp.backfillQueue = tp.backfillQueue[1:]
+			tp.backfillMtx.Unlock()
+
+			if err := tp.backfill(ctx, job); err != nil {
+				tp.logger.Error("could not backfill transactions", slog.Any("error", err), slog.String("address", job.address))
+			}
+			if ctx.Err() != nil {
+				return
+			}
+		}
+	}
+}
+
+// backfill scans the blocks from the start of the job up to its end block, which is the last block that was processed
+// without the address being subscribed, and adds the matching transactions to the repository.
+func (tp *transactionParser) backfill(ctx context.Context, job backfillJob) error {
+	var startBlock int
+	if job.startBlock != nil {
+		startBlock = *job.startBlock
+	} else {
+		blockNumber, err := tp.bcClient.FetchBlockNumberByTime(ctx, *job.startTime)
+		if err != nil {
+			return fmt.Errorf("could not find block by time: %w", err)
+		}
+		startBlock = blockNumber
+	}
+
+	endBlock := job.endBlock
+
+	tp.logger.Info("backfilling transactions", slog.String("address", job.address), slog.Int("from", startBlock), slog.Int("to", endBlock))
+
+	// stop fetching when returning early
+	fetchCtx, cancelFetch := context.WithCancel(ctx)
+	defer cancelFetch()
+
+	subscription := newAddressIndex([]string{job.address})
+	for fetched := range tp.fetchBlocks(fetchCtx, max(startBlock, 0), endBlock, subscription) {
+		if fetched.err != nil {
+			return fmt.Errorf("could not fetch blocks %d-%d: %w", fetched.from, fetched.to, fetched.err)
+		}
+
+		repoTx, err := tp.repo.NewTransaction(ctx)
+		if err != nil {
+			return fmt.Errorf("could not create repository transaction: %w", err)
+		}
+		for _, blockData := range fetched.blocks {
+			err := addBlockRecords(ctx, repoTx, blockData, subscription, false)
+			if errs.IsNotFoundErr(err) {
+				tp.rollback(ctx, repoTx)
+				tp.logger.Info("backfill cancelled, address is unsubscribed", slog.String("address", job.address))
+				return nil
+			}
+			if err != nil {
+				tp.rollback(ctx, repoTx)
+				return err
+			}
+		}
+		if err := repoTx.Commit(ctx); err != nil {
+			return fmt.Errorf("could not commit repository transaction: %w", err)
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	tp.logger.Info("backfill completed", slog.String("address", job.address))
 	return nil
 }
@@ -0,0 +1,724 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aniladanir/ethereum-blockchain-parser/internal/adapters/blockchain"
+	"github.com/aniladanir/ethereum-blockchain-parser/internal/adapters/repositories"
+	"github.com/aniladanir/ethereum-blockchain-parser/internal/core/domain"
+	"github.com/aniladanir/ethereum-blockchain-parser/pkg/errs"
+)
+
+// mockChain is an in-memory blockchain client for testing
+type mockChain struct {
+	head   int
+	blocks map[int]*domain.Block
+	// receipts are the receipts by transaction hash, belonging to the current block of the transaction unless BlockHash is set
+	receipts map[string]domain.Receipt
+	// blockReceiptsUnsupported makes eth_getBlockReceipts fail as unsupported method
+	blockReceiptsUnsupported bool
+	// receiptRequests counts the receipt requests by method
+	receiptRequests map[string]int
+	// logs are the logs by block number, positioned in the current block of the number when fetched
+	logs map[int][]domain.Log
+	// maxLogs makes eth_getLogs fail for ranges with more logs, unlimited if zero
+	maxLogs int
+	// logRequests are the requested log ranges
+	logRequests []string
+	// logsErr makes eth_getLogs fail with the error if set
+	logsErr error
+}
+
+func (m *mockChain) FetchCurrentBlock(ctx context.Context) (int, error) {
+	return m.head, nil
+}
+
+func (m *mockChain) FetchBlockNumberByTag(ctx context.Context, tag blockchain.BlockTag) (int, error) {
+	switch tag {
+	case blockchain.BlockTagSafe:
+		return m.head - 32, nil
+	case blockchain.BlockTagFinalized:
+		return m.head - 64, nil
+	}
+	return m.head, nil
+}
+
+func (m *mockChain) FetchBlockNumberByTime(ctx context.Context, t time.Time) (int, error) {
+	// blocks are mined every 12 seconds starting from the unix epoch
+	return int((t.Unix() + 11) / 12), nil
+}
+
+func (m *mockChain) FetchBlockByNumber(ctx context.Context, blockNumber int) (*domain.Block, error) {
+	block, ok := m.blocks[blockNumber]
+	if !ok {
+		return nil, errs.BlockNotFoundErr()
+	}
+	return block, nil
+}
+
+func (m *mockChain) FetchBlocksByRange(ctx context.Context, from, to int) ([]*domain.Block, error) {
+	blocks := make([]*domain.Block, 0)
+	for blockNumber := from; blockNumber <= to; blockNumber++ {
+		block, err := m.FetchBlockByNumber(ctx, blockNumber)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks, nil
+}
+
+func (m *mockChain) FetchTransactionReceipt(ctx context.Context, hash string) (*domain.Receipt, error) {
+	m.receiptRequests["eth_getTransactionReceipt"]++
+	receipt, ok := m.receipts[hash]
+	if !ok {
+		return nil, errs.NotFoundErr()
+	}
+	for _, block := range m.blocks {
+		for i := range block.Transactions {
+			if block.Transactions[i].Hash == hash && receipt.BlockHash == "" {
+				receipt.BlockHash = block.Hash
+			}
+		}
+	}
+	return &receipt, nil
+}
+
+func (m *mockChain) FetchBlockReceipts(ctx context.Context, blockNumber int) ([]domain.Receipt, error) {
+	m.receiptRequests["eth_getBlockReceipts"]++
+	if m.blockReceiptsUnsupported {
+		return nil, &errs.ErrorRpc{Code: -32601, Message: "the method eth_getBlockReceipts does not exist"}
+	}
+	block, ok := m.blocks[blockNumber]
+	if !ok {
+		return nil, errs.BlockNotFoundErr()
+	}
+	receipts := make([]domain.Receipt, 0)
+	for i := range block.Transactions {
+		if receipt, ok := m.receipts[block.Transactions[i].Hash]; ok {
+			if receipt.BlockHash == "" {
+				receipt.BlockHash = block.Hash
+			}
+			receipts = append(receipts, receipt)
+		}
+	}
+	return receipts, nil
+}
+
+func (m *mockChain) FetchLogs(ctx context.Context, filter blockchain.LogFilter) ([]domain.Log, error) {
+	m.logRequests = append(m.logRequests, fmt.Sprintf("%d-%d", filter.FromBlock, filter.ToBlock))
+	if m.logsErr != nil {
+		return nil, m.logsErr
+	}
+	logs := make([]domain.Log, 0)
+	for blockNumber := filter.FromBlock; blockNumber <= filter.ToBlock; blockNumber++ {
+		block, ok := m.blocks[blockNumber]
+		if !ok {
+			continue
+		}
+		for _, log := range m.logs[blockNumber] {
+			if !matchesTopics(log.Topics, filter.Topics) {
+				continue
+			}
+			log.BlockNumber = block.Number
+			log.BlockHash = block.Hash
+			if log.TransactionHash == "" {
+				log.TransactionHash = "tx-" + block.Hash
+			}
+			logs = append(logs, log)
+		}
+	}
+	if m.maxLogs > 0 && len(logs) > m.maxLogs {
+		return nil, &errs.ErrorRpc{Code: -32005, Message: "query returned more than 10000 results"}
+	}
+	return logs, nil
+}
+
+// matchesTopics reports whether the topics match the filter, where each position matches any of its topics
+func matchesTopics(topics []string, filter [][]string) bool {
+	for i, alternatives := range filter {
+		if len(alternatives) == 0 {
+			continue
+		}
+		if i >= len(topics) || !slices.Contains(alternatives, topics[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *mockChain) EndpointHealth() []blockchain.EndpointHealth {
+	return nil
+}
+
+// addBlock adds a block on top of the given parent block, replacing any block with the same number
+func (m *mockChain) addBlock(number int, fork string, parentHash string, transactions ...domain.Transaction) string {
+	hash := fmt.Sprintf("%s%d", fork, number)
+	for i := range transactions {
+		transactions[i].BlockNumber = uint64(number)
+	}
+	m.blocks[number] = &domain.Block{
+		Number:       uint64(number),
+		Hash:         hash,
+		ParentHash:   parentHash,
+		Transactions: transactions,
+	}
+	m.head = number
+	return hash
+}
+
+func setupTest(t testing.TB, startBlock int, addresses ...string) (*transactionParser, *mockChain, repositories.Repository) {
+	t.Helper()
+	ctx := context.Background()
+
+	repo := repositories.NewInmemTransactionRepository()
+	if err := repo.SetBlockNumber(ctx, startBlock); err != nil {
+		t.Fatal(err)
+	}
+	for _, addr := range addresses {
+		if err := repo.AddAddress(ctx, addr); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	chain := &mockChain{
+		head:            startBlock,
+		blocks:          make(map[int]*domain.Block),
+		receipts:        make(map[string]domain.Receipt),
+		receiptRequests: make(map[string]int),
+		logs:            make(map[int][]domain.Log),
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	tp := NewTransactionParser(repo, chain, logger).(*transactionParser)
+	return tp, chain, repo
+}
+
+func TestProcessBlocksReorg(t *testing.T) {
+	ctx := context.Background()
+	tp, chain, repo := setupTest(t, 100, "0xa")
+
+	// canonical chain a101 <- a102 <- a103, with a transaction to 0xa in a103
+	a101 := chain.addBlock(101, "a", "a100")
+	a102 := chain.addBlock(102, "a", a101)
+	chain.addBlock(103, "a", a102, domain.Transaction{Hash: "tx-a103", From: "0xb", To: "0xa"})
+	tp.processNewBlocks(ctx)
+
+	transactions, _ := repo.GetTransactions(ctx, "0xa")
+	if len(transactions) != 1 || transactions[0].Hash != "tx-a103" {
+		t.Fatalf("expected transaction tx-a103 to be ingested, got %v", transactions)
+	}
+
+	// fork from a101: b102 <- b103 <- b104, with a transaction to 0xa in b104
+	b102 := chain.addBlock(102, "b", a101)
+	b103 := chain.addBlock(103, "b", b102)
+	chain.addBlock(104, "b", b103, domain.Transaction{Hash: "tx-b104", From: "0xa", To: "0xc"})
+	tp.processNewBlocks(ctx)
+
+	transactions, _ = repo.GetTransactions(ctx, "0xa")
+	if len(transactions) != 1 || transactions[0].Hash != "tx-b104" {
+		t.Errorf("expected only transaction tx-b104 after reorg, got %v", transactions)
+	}
+	if blockNumber, _ := repo.GetBlockNumber(ctx); blockNumber != 104 {
+		t.Errorf("expected block number 104, got %d", blockNumber)
+	}
+	for block, expectedHash := range map[int]string{101: a101, 102: b102, 103: b103} {
+		if hash, _ := repo.GetBlockHash(ctx, block); hash != expectedHash {
+			t.Errorf("expected hash %s for block %d, got %s", expectedHash, block, hash)
+		}
+	}
+}
+
+func TestProcessBlocksReorgDeeperThanMaxDepth(t *testing.T) {
+	ctx := context.Background()
+	tp, chain, repo := setupTest(t, 100, "0xa")
+	tp.maxReorgDepth = 2
+
+	parent := "a100"
+	for block := 101; block <= 105; block++ {
+		parent = chain.addBlock(block, "a", parent, domain.Transaction{Hash: fmt.Sprintf("tx-a%d", block), To: "0xa"})
+	}
+	tp.processNewBlocks(ctx)
+
+	// replace every block after 101
+	parent = "a101"
+	for block := 102; block <= 106; block++ {
+		parent = chain.addBlock(block, "b", parent)
+	}
+	tp.processNewBlocks(ctx)
+
+	// hashes older than the max reorg depth are pruned, so the walk back stops at the untracked block 102
+	transactions, _ := repo.GetTransactions(ctx, "0xa")
+	expected := []string{"tx-a101", "tx-a102"}
+	if len(transactions) != len(expected) {
+		t.Fatalf("expected %d transactions, got %v", len(expected), transactions)
+	}
+	for i := range expected {
+		if transactions[i].Hash != expected[i] {
+			t.Errorf("expected transaction %s, got %s", expected[i], transactions[i].Hash)
+		}
+	}
+	if blockNumber, _ := repo.GetBlockNumber(ctx); blockNumber != 106 {
+		t.Errorf("expected block number 106, got %d", blockNumber)
+	}
+}
+
+func TestProcessBlocksFinality(t *testing.T) {
+	tests := []struct {
+		name          string
+		opts          []Option
+		expectedBlock int
+	}{
+		{
+			name:          "Latest",
+			expectedBlock: 200,
+		},
+		{
+			name:          "Confirmations",
+			opts:          []Option{WithConfirmations(10)},
+			expectedBlock: 190,
+		},
+		{
+			name:          "Finalized",
+			opts:          []Option{WithBlockTag(blockchain.BlockTagFinalized)},
+			expectedBlock: 136,
+		},
+		{
+			name:          "SafeWithConfirmations",
+			opts:          []Option{WithBlockTag(blockchain.BlockTagSafe), WithConfirmations(2)},
+			expectedBlock: 166,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			tp, chain, repo := setupTest(t, 100)
+			for _, opt := range tt.opts {
+				opt(tp)
+			}
+
+			parent := "a100"
+			for block := 101; block <= 200; block++ {
+				parent = chain.addBlock(block, "a", parent)
+			}
+			tp.processNewBlocks(ctx)
+
+			if blockNumber, _ := repo.GetBlockNumber(ctx); blockNumber != tt.expectedBlock {
+				t.Errorf("expected block number %d, got %d", tt.expectedBlock, blockNumber)
+			}
+		})
+	}
+}
+
+const (
+	backfillAddrA = "0x00000000000000000000000000000000000000aa"
+	backfillAddrB = "0x00000000000000000000000000000000000000bb"
+	backfillAddrC = "0x00000000000000000000000000000000000000cc"
+)
+
+func TestBackfill(t *testing.T) {
+	ctx := context.Background()
+	tp, chain, repo := setupTest(t, 0)
+
+	parent := "a0"
+	for block := 1; block <= 10; block++ {
+		parent = chain.addBlock(block, "a", parent, domain.Transaction{Hash: fmt.Sprintf("tx-%d", block), From: backfillAddrA, To: backfillAddrB})
+	}
+	tp.processNewBlocks(ctx)
+
+	startBlock := 8
+	startTime := time.Unix(12*5, 0)
+	tests := []struct {
+		name     string
+		address  string
+		opts     SubscribeOptions
+		expected []string
+	}{
+		{
+			name:     "NoBackfill",
+			address:  backfillAddrA,
+			opts:     SubscribeOptions{},
+			expected: []string{},
+		},
+		{
+			name:     "StartBlock",
+			address:  strings.ToUpper(backfillAddrB),
+			opts:     SubscribeOptions{StartBlock: &startBlock},
+			expected: []string{"tx-8", "tx-9", "tx-10"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tp.Subscribe(ctx, tt.address, tt.opts); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			for _, job := range tp.backfillQueue {
+				if err := tp.backfill(ctx, job); err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+			}
+			tp.backfillQueue = nil
+
+			transactions, _ := tp.GetTransactions(ctx, tt.address)
+			if len(transactions) != len(tt.expected) {
+				t.Fatalf("expected %d transactions, got %v", len(tt.expected), transactions)
+			}
+			for i := range tt.expected {
+				if transactions[i].Hash != tt.expected[i] {
+					t.Errorf("expected transaction %s, got %s", tt.expected[i], transactions[i].Hash)
+				}
+			}
+		})
+	}
+
+	t.Run("StartTime", func(t *testing.T) {
+		job := backfillJob{address: backfillAddrB, startTime: &startTime, endBlock: 10}
+		if err := repo.RemoveBlocks(ctx, 0); err != nil {
+			t.Fatal(err)
+		}
+		if err := tp.backfill(ctx, job); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		transactions, _ := repo.GetTransactions(ctx, backfillAddrB)
+		if len(transactions) != 6 || transactions[0].Hash != "tx-5" {
+			t.Errorf("expected transactions from block 5, got %v", transactions)
+		}
+	})
+}
+
+func TestBackfillAfterIngestion(t *testing.T) {
+	ctx := context.Background()
+	tp, chain, _ := setupTest(t, 0)
+
+	parent := "a0"
+	for block := 1; block <= 10; block++ {
+		parent = chain.addBlock(block, "a", parent, domain.Transaction{Hash: fmt.Sprintf("tx-%d", block), From: backfillAddrA, To: backfillAddrB})
+	}
+	tp.processNewBlocks(ctx)
+
+	startBlock := 8
+	if err := tp.Subscribe(ctx, backfillAddrB, SubscribeOptions{StartBlock: &startBlock}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// blocks ingested before the backfill runs already include the address
+	for block := 11; block <= 12; block++ {
+		parent = chain.addBlock(block, "a", parent, domain.Transaction{Hash: fmt.Sprintf("tx-%d", block), From: backfillAddrA, To: backfillAddrB})
+	}
+	tp.processNewBlocks(ctx)
+	for _, job := range tp.backfillQueue {
+		if err := tp.backfill(ctx, job); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+
+	page, err := tp.QueryTransactions(ctx, domain.TransactionQuery{Address: backfillAddrB})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashes := make([]string, 0)
+	for _, tx := range page.Transactions {
+		hashes = append(hashes, tx.Hash)
+	}
+	expected := []string{"tx-8", "tx-9", "tx-10", "tx-11", "tx-12"}
+	if !reflect.DeepEqual(hashes, expected) {
+		t.Errorf("expected transactions %v, got %v", expected, hashes)
+	}
+}
+
+// lockOrderRepository records whether the ingestion mutex is held whenever a repository transaction is created
+type lockOrderRepository struct {
+	repositories.Repository
+	tp       *transactionParser
+	unlocked int
+}
+
+func (r *lockOrderRepository) NewTransaction(ctx context.Context) (repositories.Transaction, error) {
+	if r.tp.ingestMtx.TryLock() {
+		r.unlocked++
+		r.tp.ingestMtx.Unlock()
+	}
+	return r.Repository.NewTransaction(ctx)
+}
+
+func TestSubscribeLockOrder(t *testing.T) {
+	ctx := context.Background()
+	tp, _, repo := setupTest(t, 0)
+	lockOrderRepo := &lockOrderRepository{Repository: repo, tp: tp}
+	tp.repo = lockOrderRepo
+
+	// subscriptions take the ingestion mutex before their transaction, like ingestion cycles do
+	if err := tp.Subscribe(ctx, backfillAddrA, SubscribeOptions{}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if _, err := tp.SubscribeBatch(ctx, []SubscribeRequest{{Address: backfillAddrB}}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if lockOrderRepo.unlocked != 0 {
+		t.Errorf("expected ingestion mutex to be held by every transaction, %d were created without it", lockOrderRepo.unlocked)
+	}
+}
+
+func TestBackfillKeptHistory(t *testing.T) {
+	ctx := context.Background()
+	tp, chain, _ := setupTest(t, 0, backfillAddrB)
+
+	parent := "a0"
+	for block := 1; block <= 10; block++ {
+		parent = chain.addBlock(block, "a", parent, domain.Transaction{Hash: fmt.Sprintf("tx-%d", block), From: backfillAddrA, To: backfillAddrB})
+	}
+	tp.processNewBlocks(ctx)
+
+	// subscribing again from a block covered by the kept transactions does not duplicate them
+	if err := tp.Unsubscribe(ctx, backfillAddrB, UnsubscribeOptions{}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	startBlock := 5
+	if err := tp.Subscribe(ctx, backfillAddrB, SubscribeOptions{StartBlock: &startBlock}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	for _, job := range tp.backfillQueue {
+		if err := tp.backfill(ctx, job); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+
+	page, err := tp.QueryTransactions(ctx, domain.TransactionQuery{Address: backfillAddrB})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashes := make([]string, 0)
+	for _, tx := range page.Transactions {
+		hashes = append(hashes, tx.Hash)
+	}
+	expected := make([]string, 0)
+	for block := 1; block <= 10; block++ {
+		expected = append(expected, fmt.Sprintf("tx-%d", block))
+	}
+	if !reflect.DeepEqual(hashes, expected) {
+		t.Errorf("expected transactions %v, got %v", expected, hashes)
+	}
+}
+
+func TestUpdateBlockNumber(t *testing.T) {
+	tests := []struct {
+		name          string
+		storedBlock   int
+		opts          []Option
+		expectedBlock int
+	}{
+		{
+			name:          "ResumeFromStoredBlock",
+			storedBlock:   150,
+			expectedBlock: 150,
+		},
+		{
+			name:          "ResumeWithoutStoredBlock",
+			storedBlock:   0,
+			expectedBlock: 200,
+		},
+		{
+			name:          "Head",
+			storedBlock:   150,
+			opts:          []Option{WithStartMode(StartModeHead, 0)},
+			expectedBlock: 200,
+		},
+		{
+			name:          "ExplicitBlock",
+			storedBlock:   150,
+			opts:          []Option{WithStartMode(StartModeBlock, 120)},
+			expectedBlock: 119,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			tp, chain, repo := setupTest(t, tt.storedBlock)
+			chain.head = 200
+			for _, opt := range tt.opts {
+				opt(tp)
+			}
+
+			if err := tp.updateBlockNumber(ctx); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if blockNumber, _ := repo.GetBlockNumber(ctx); blockNumber != tt.expectedBlock {
+				t.Errorf("expected block number %d, got %d", tt.expectedBlock, blockNumber)
+			}
+		})
+	}
+}
+
+func TestQueryTransactions(t *testing.T) {
+	ctx := context.Background()
+	tp, _, repo := setupTest(t, 0)
+
+	_ = repo.AddAddress(ctx, backfillAddrA)
+	for i := 1; i <= MaxQueryLimit+1; i++ {
+		tx := domain.Transaction{Hash: fmt.Sprintf("tx-%d", i), From: backfillAddrA, To: backfillAddrB, Value: big.NewInt(1), BlockNumber: uint64(i)}
+		_ = repo.AddTransaction(ctx, backfillAddrA, tx)
+	}
+
+	tests := []struct {
+		name          string
+		query         domain.TransactionQuery
+		expectedCount int
+		expectedErr   func(err error) bool
+	}{
+		{
+			name:          "DefaultLimit",
+			query:         domain.TransactionQuery{Address: strings.ToUpper(backfillAddrA)},
+			expectedCount: DefaultQueryLimit,
+		},
+		{
+			name:          "MaxLimit",
+			query:         domain.TransactionQuery{Address: backfillAddrA, Limit: MaxQueryLimit + 1},
+			expectedCount: MaxQueryLimit,
+		},
+		{
+			name:          "Counterparty",
+			query:         domain.TransactionQuery{Address: backfillAddrA, Counterparty: strings.ToUpper(backfillAddrB), Limit: 10},
+			expectedCount: 10,
+		},
+		{
+			name:        "InvalidAddress",
+			query:       domain.TransactionQuery{Address: "0xa"},
+			expectedErr: errs.IsInvalidAddressErr,
+		},
+		{
+			name:        "InvalidCounterparty",
+			query:       domain.TransactionQuery{Address: backfillAddrA, Counterparty: "0xb"},
+			expectedErr: errs.IsInvalidAddressErr,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := tp.QueryTransactions(ctx, tt.query)
+			if tt.expectedErr != nil {
+				if !tt.expectedErr(err) {
+					t.Errorf("expected error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if len(page.Transactions) != tt.expectedCount {
+				t.Errorf("expected %d transactions, got %d", tt.expectedCount, len(page.Transactions))
+			}
+			if page.NextCursor == "" {
+				t.Errorf("expected next cursor")
+			}
+		})
+	}
+}
+
+func TestUnsubscribe(t *testing.T) {
+	ctx := context.Background()
+	tp, _, repo := setupTest(t, 0)
+
+	startBlock := 1
+	for _, address := range []string{backfillAddrA, backfillAddrB} {
+		if err := tp.Subscribe(ctx, address, SubscribeOptions{StartBlock: &startBlock}); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		_ = repo.AddTransaction(ctx, address, domain.Transaction{Hash: "tx-" + address})
+	}
+
+	if err := tp.Unsubscribe(ctx, strings.ToUpper(backfillAddrA), UnsubscribeOptions{}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := tp.Unsubscribe(ctx, backfillAddrA, UnsubscribeOptions{}); !errs.IsNotFoundErr(err) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+	if err := tp.Unsubscribe(ctx, "0xa", UnsubscribeOptions{}); !errs.IsInvalidAddressErr(err) {
+		t.Errorf("expected invalid address error, got %v", err)
+	}
+
+	subscriptions, err := tp.ListSubscriptions(ctx)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(subscriptions) != 1 || subscriptions[0].Address != backfillAddrB {
+		t.Errorf("expected subscription of %s, got %v", backfillAddrB, subscriptions)
+	}
+	if len(tp.backfillQueue) != 1 || tp.backfillQueue[0].address != backfillAddrB {
+		t.Errorf("expected pending backfill of the unsubscribed address to be dropped, got %v", tp.backfillQueue)
+	}
+	if transactions, _ := tp.GetTransactions(ctx, backfillAddrA); len(transactions) != 1 {
+		t.Errorf("expected kept transaction, got %v", transactions)
+	}
+
+	if err := tp.Unsubscribe(ctx, backfillAddrB, UnsubscribeOptions{PurgeHistory: true}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if _, err := tp.GetTransactions(ctx, backfillAddrB); !errs.IsNotFoundErr(err) {
+		t.Errorf("expected not found error for purged address, got %v", err)
+	}
+}
+
+func TestSubscribeBatch(t *testing.T) {
+	ctx := context.Background()
+	tp, _, _ := setupTest(t, 0)
+
+	if err := tp.Subscribe(ctx, backfillAddrB, SubscribeOptions{}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	startBlock, negativeBlock := 1, -1
+	requests := []SubscribeRequest{
+		{Address: strings.ToUpper(backfillAddrA), Options: SubscribeOptions{Label: "exchange", StartBlock: &startBlock}},
+		{Address: backfillAddrB},
+		{Address: "0xa"},
+		{Address: backfillAddrA},
+		{Address: backfillAddrC, Options: SubscribeOptions{Label: strings.Repeat("a", MaxLabelLength+1)}},
+		{Address: backfillAddrC, Options: SubscribeOptions{StartBlock: &negativeBlock}},
+	}
+	expected := []SubscribeStatus{
+		SubscribeStatusCreated,
+		SubscribeStatusAlreadyExists,
+		SubscribeStatusInvalid,
+		SubscribeStatusAlreadyExists,
+		SubscribeStatusInvalid,
+		SubscribeStatusInvalid,
+	}
+
+	results, err := tp.SubscribeBatch(ctx, requests)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(results) != len(expected) {
+		t.Fatalf("expected %d results, got %d", len(expected), len(results))
+	}
+	for i := range expected {
+		if results[i].Status != expected[i] {
+			t.Errorf("expected status %s of request %d, got %s (%v)", expected[i], i, results[i].Status, results[i].Err)
+		}
+		if (results[i].Status == SubscribeStatusInvalid) != (results[i].Err != nil) {
+			t.Errorf("expected error only for invalid request %d, got %v", i, results[i].Err)
+		}
+	}
+	if !errs.IsInvalidAddressErr(results[2].Err) || !errs.IsInvalidLabelErr(results[4].Err) {
+		t.Errorf("expected invalid address and label errors, got %v and %v", results[2].Err, results[4].Err)
+	}
+	if results[0].Address != backfillAddrA {
+		t.Errorf("expected canonical address %s, got %s", backfillAddrA, results[0].Address)
+	}
+
+	subscriptions, _ := tp.ListSubscriptions(ctx)
+	expectedSubscriptions := []domain.Subscription{{Address: backfillAddrA, Label: "exchange"}, {Address: backfillAddrB}}
+	if !reflect.DeepEqual(subscriptions, expectedSubscriptions) {
+		t.Errorf("expected subscriptions %v, got %v", expectedSubscriptions, subscriptions)
+	}
+	if len(tp.backfillQueue) != 1 || tp.backfillQueue[0].address != backfillAddrA {
+		t.Errorf("expected backfill of the created subscription, got %v", tp.backfillQueue)
+	}
+}
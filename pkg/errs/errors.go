@@ -1,12 +1,23 @@
 package errs
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
 )
 
 var (
-	errAlreadyExist = &ErrorAlreadyExist{}
-	errNotFound     = &ErrorNotFound{}
+	errAlreadyExist    = &ErrorAlreadyExist{}
+	errNotFound        = &ErrorNotFound{}
+	errBlockNotFound   = &ErrorBlockNotFound{}
+	errInvalidAddress  = &ErrorInvalidAddress{}
+	errTransactionDone = &ErrorTransactionDone{}
+	errInvalidCursor   = &ErrorInvalidCursor{}
+	errInvalidLabel    = &ErrorInvalidLabel{}
+	errInvalidQuantity = &ErrorInvalidQuantity{}
 )
 
 type ErrorNotFound struct {
@@ -22,6 +33,137 @@ type ErrorAlreadyExist struct {
 func (err ErrorAlreadyExist) Error() string {
 	return "already exist"
 }
+
+// ErrorBlockNotFound is returned when the node does not know the requested block yet
+type ErrorBlockNotFound struct {
+}
+
+func (err ErrorBlockNotFound) Error() string {
+	return "block not found"
+}
+
+// ErrorInvalidAddress is returned when an ethereum address is malformed
+type ErrorInvalidAddress struct {
+}
+
+func (err ErrorInvalidAddress) Error() string {
+	return "invalid address"
+}
+
+// ErrorTransactionDone is returned when a repository transaction is used after it was committed or rolled back
+type ErrorTransactionDone struct {
+}
+
+func (err ErrorTransactionDone) Error() string {
+	return "transaction has already been committed or rolled back"
+}
+
+// ErrorInvalidCursor is returned when a pagination cursor is malformed
+type ErrorInvalidCursor struct {
+}
+
+func (err ErrorInvalidCursor) Error() string {
+	return "invalid cursor"
+}
+
+// ErrorInvalidLabel is returned when a subscription label is too long
+type ErrorInvalidLabel struct {
+}
+
+func (err ErrorInvalidLabel) Error() string {
+	return "invalid label"
+}
+
+// ErrorInvalidQuantity is returned when a json-rpc quantity is malformed
+type ErrorInvalidQuantity struct {
+}
+
+func (err ErrorInvalidQuantity) Error() string {
+	return "invalid quantity"
+}
+
+// ErrorRpc represents a json-rpc error object returned by a blockchain node
+type ErrorRpc struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+func (err *ErrorRpc) Error() string {
+	if err.Data != nil {
+		return fmt.Sprintf("json-rpc error %d: %s (%v)", err.Code, err.Message, err.Data)
+	}
+	return fmt.Sprintf("json-rpc error %d: %s", err.Code, err.Message)
+}
+
+// resultLimitHints are parts of the messages of nodes rejecting a request whose result is too large
+var resultLimitHints = []string{
+	"query returned more than", "response size exceeded", "too many results", "exceed maximum block range", "block range is too",
+}
+
+// ResultLimitExceeded reports whether the node rejected the request because its result is too large, e.g. a log
+// query over too many blocks. Such requests fail again unless they are narrowed down.
+func (err *ErrorRpc) ResultLimitExceeded() bool {
+	msg := strings.ToLower(err.Message)
+	for _, hint := range resultLimitHints {
+		if strings.Contains(msg, hint) {
+			return true
+		}
+	}
+	return false
+}
+
+// Retryable reports whether the request may succeed if it is sent again, e.g. after being rate limited
+func (err *ErrorRpc) Retryable() bool {
+	// nodes also use -32005 for results that are too large, which are rejected again when retried
+	if err.ResultLimitExceeded() {
+		return false
+	}
+	// -32005 is the 'limit exceeded' code defined in EIP-1474
+	if err.Code == -32005 {
+		return true
+	}
+	msg := strings.ToLower(err.Message)
+	for _, hint := range []string{"rate limit", "too many requests", "timeout", "timed out"} {
+		if strings.Contains(msg, hint) {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrorHttpStatus is returned when a node responds with a non-200 status code
+type ErrorHttpStatus struct {
+	StatusCode int
+	// RetryAfter is the delay requested by the node via the Retry-After header, zero if not present
+	RetryAfter time.Duration
+}
+
+func (err *ErrorHttpStatus) Error() string {
+	return fmt.Sprintf("received non-200 status code: %d", err.StatusCode)
+}
+
+// Retryable reports whether the status code indicates a transient failure
+func (err *ErrorHttpStatus) Retryable() bool {
+	return err.StatusCode == 429 || err.StatusCode == 408 || err.StatusCode >= 500
+}
+
+type errorRetryable struct {
+	err error
+}
+
+func (err *errorRetryable) Error() string {
+	return err.err.Error()
+}
+
+func (err *errorRetryable) Unwrap() error {
+	return err.err
+}
+
+func (err *errorRetryable) Retryable() bool {
+	return true
+}
+
 func AlreadyExistErr() error {
 	return errAlreadyExist
 }
@@ -30,6 +172,30 @@ func NotFoundErr() error {
 	return errNotFound
 }
 
+func BlockNotFoundErr() error {
+	return errBlockNotFound
+}
+
+func InvalidAddressErr() error {
+	return errInvalidAddress
+}
+
+func TransactionDoneErr() error {
+	return errTransactionDone
+}
+
+func InvalidCursorErr() error {
+	return errInvalidCursor
+}
+
+// RetryableErr marks the given error as retryable
+func RetryableErr(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &errorRetryable{err: err}
+}
+
 func IsAlreadyExistErr(err error) bool {
 	return errors.Is(err, errAlreadyExist)
 }
@@ -37,3 +203,80 @@ func IsAlreadyExistErr(err error) bool {
 func IsNotFoundErr(err error) bool {
 	return errors.Is(err, errNotFound)
 }
+
+func IsBlockNotFoundErr(err error) bool {
+	return errors.Is(err, errBlockNotFound)
+}
+
+func IsInvalidAddressErr(err error) bool {
+	return errors.Is(err, errInvalidAddress)
+}
+
+func IsTransactionDoneErr(err error) bool {
+	return errors.Is(err, errTransactionDone)
+}
+
+func IsInvalidCursorErr(err error) bool {
+	return errors.Is(err, errInvalidCursor)
+}
+
+func InvalidLabelErr() error {
+	return errInvalidLabel
+}
+
+func IsInvalidLabelErr(err error) bool {
+	return errors.Is(err, errInvalidLabel)
+}
+
+func InvalidQuantityErr() error {
+	return errInvalidQuantity
+}
+
+func IsInvalidQuantityErr(err error) bool {
+	return errors.Is(err, errInvalidQuantity)
+}
+
+// AsRpcErr returns the json-rpc error object in the error chain, if any
+func AsRpcErr(err error) (*ErrorRpc, bool) {
+	var rpcErr *ErrorRpc
+	if errors.As(err, &rpcErr) {
+		return rpcErr, true
+	}
+	return nil, false
+}
+
+// IsResultLimitErr reports whether the error chain contains a json-rpc error rejecting a request whose result is
+// too large
+func IsResultLimitErr(err error) bool {
+	rpcErr, ok := AsRpcErr(err)
+	return ok && rpcErr.ResultLimitExceeded()
+}
+
+// IsRetryableErr reports whether the failed operation may succeed if it is attempted again.
+// Rate limits, timeouts and transient server errors are retryable, everything else is fatal.
+func IsRetryableErr(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var retryable interface{ Retryable() bool }
+	if errors.As(err, &retryable) {
+		return retryable.Retryable()
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return errors.Is(err, context.DeadlineExceeded)
+}
+
+// RetryAfter returns the delay requested by the server in the error chain, zero if there is none
+func RetryAfter(err error) time.Duration {
+	var statusErr *ErrorHttpStatus
+	if errors.As(err, &statusErr) {
+		return statusErr.RetryAfter
+	}
+	return 0
+}
@@ -0,0 +1,79 @@
+package errs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestIsRetryableErr(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{
+			name:     "Nil",
+			err:      nil,
+			expected: false,
+		},
+		{
+			name:     "RpcLimitExceeded",
+			err:      fmt.Errorf("wrapped: %w", &ErrorRpc{Code: -32005, Message: "limit exceeded"}),
+			expected: true,
+		},
+		{
+			name:     "RpcResultLimitExceeded",
+			err:      fmt.Errorf("wrapped: %w", &ErrorRpc{Code: -32005, Message: "query returned more than 10000 results"}),
+			expected: false,
+		},
+		{
+			name:     "RpcRateLimitMessage",
+			err:      &ErrorRpc{Code: -32000, Message: "Rate limit reached"},
+			expected: true,
+		},
+		{
+			name:     "RpcInvalidParams",
+			err:      &ErrorRpc{Code: -32602, Message: "invalid params"},
+			expected: false,
+		},
+		{
+			name:     "HttpTooManyRequests",
+			err:      &ErrorHttpStatus{StatusCode: 429},
+			expected: true,
+		},
+		{
+			name:     "HttpUnauthorized",
+			err:      &ErrorHttpStatus{StatusCode: 401},
+			expected: false,
+		},
+		{
+			name:     "MarkedRetryable",
+			err:      RetryableErr(errors.New("connection refused")),
+			expected: true,
+		},
+		{
+			name:     "DeadlineExceeded",
+			err:      fmt.Errorf("wrapped: %w", context.DeadlineExceeded),
+			expected: true,
+		},
+		{
+			name:     "Canceled",
+			err:      context.Canceled,
+			expected: false,
+		},
+		{
+			name:     "BlockNotFound",
+			err:      BlockNotFoundErr(),
+			expected: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if actual := IsRetryableErr(tt.err); actual != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, actual)
+			}
+		})
+	}
+}